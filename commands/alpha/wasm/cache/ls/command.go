@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ls
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/wasmdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/spf13/cobra"
+)
+
+const (
+	command = "cmdwasmcachels"
+)
+
+func newRunner(ctx context.Context) *runner {
+	r := &runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "ls",
+		Args:    cobra.NoArgs,
+		Short:   wasmdocs.LsShort,
+		Long:    wasmdocs.LsShort + "\n" + wasmdocs.LsLong,
+		Example: wasmdocs.LsExamples,
+		RunE:    r.runE,
+	}
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context) *cobra.Command {
+	return newRunner(ctx).Command
+}
+
+type runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+
+	// cacheDir overrides the default cache directory.
+	cacheDir string
+}
+
+func (r *runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = command + ".runE"
+	dir := r.cacheDir
+	if dir == "" {
+		var err error
+		dir, err = fnruntime.DefaultWasmCacheDir()
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+	entries, err := (&fnruntime.WasmCache{Dir: dir}).List()
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	tw := tabwriter.NewWriter(printer.FromContextOrDie(r.ctx).OutStream(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIGEST\tWASMTIME\tSIZE\tLAST USED")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", e.Digest, e.WasmtimeVersion, e.Size, e.LastUsed.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
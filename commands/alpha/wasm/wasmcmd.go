@@ -17,6 +17,7 @@ package wasm
 import (
 	"context"
 
+	"github.com/GoogleContainerTools/kpt/commands/alpha/wasm/cache"
 	"github.com/GoogleContainerTools/kpt/commands/alpha/wasm/pull"
 	"github.com/GoogleContainerTools/kpt/commands/alpha/wasm/push"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/wasmdocs"
@@ -31,6 +32,7 @@ func NewCommand(ctx context.Context, version string) *cobra.Command {
 	}
 
 	wasmcmd.AddCommand(
+		cache.NewCommand(ctx),
 		pull.NewCommand(ctx),
 		push.NewCommand(ctx),
 	)
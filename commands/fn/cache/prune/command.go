@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prune
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+const (
+	command = "cmdfncacheprune"
+)
+
+func newRunner(ctx context.Context, parent string) *runner {
+	r := &runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "prune [flags]",
+		Args:    cobra.NoArgs,
+		Short:   fndocs.PruneShort,
+		Long:    fndocs.PruneShort + "\n" + fndocs.PruneLong,
+		Example: fndocs.PruneExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().DurationVar(&r.olderThan, "older-than", 0,
+		"only remove results that haven't been used for longer than this duration.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return newRunner(ctx, parent).Command
+}
+
+type runner struct {
+	ctx       context.Context
+	Command   *cobra.Command
+	olderThan time.Duration
+
+	// cacheDir overrides the default cache directory.
+	cacheDir string
+}
+
+func (r *runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = command + ".runE"
+	if r.olderThan < 0 {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("--older-than must not be negative"))
+	}
+	dir := r.cacheDir
+	if dir == "" {
+		var err error
+		dir, err = fnruntime.DefaultFnCacheDir()
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+	cache := &fnruntime.FnCache{Dir: dir}
+	removed, err := cache.Prune(r.olderThan)
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	printer.FromContextOrDie(r.ctx).Printf("Removed %d cached function result(s).\n", removed)
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prune
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmd_prune(t *testing.T) {
+	testCases := map[string]struct {
+		args            []string
+		expectedOutput  string
+		expectedEntries []string
+		expectErr       string
+	}{
+		"all results": {
+			expectedOutput: "Removed 2 cached function result(s).\n",
+		},
+		"results older than": {
+			args:            []string{"--older-than", "1h"},
+			expectedOutput:  "Removed 1 cached function result(s).\n",
+			expectedEntries: []string{filepath.Join("ab", "abcd")},
+		},
+		"negative duration": {
+			args:            []string{"--older-than", "-1h"},
+			expectedEntries: []string{filepath.Join("ab", "abcd"), filepath.Join("ef", "efgh")},
+			expectErr:       "--older-than must not be negative",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			writeEntry(t, dir, "abcd", time.Now())
+			writeEntry(t, dir, "efgh", time.Now().Add(-2*time.Hour))
+
+			out := &bytes.Buffer{}
+			r := newRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.cacheDir = dir
+			r.Command.SetArgs(tc.args)
+			err := r.Command.Execute()
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+			} else {
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				assert.Equal(t, tc.expectedOutput, out.String())
+			}
+			assert.ElementsMatch(t, tc.expectedEntries, entries(t, dir))
+		})
+	}
+}
+
+func TestCmd_prune_missingDir(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := newRunner(fake.CtxWithPrinter(out, out), "kpt")
+	r.cacheDir = filepath.Join(t.TempDir(), "missing")
+	r.Command.SetArgs([]string{})
+	if assert.NoError(t, r.Command.Execute()) {
+		assert.Equal(t, "Removed 0 cached function result(s).\n", out.String())
+	}
+}
+
+// writeEntry writes a cached result with the given key, last used at the
+// given time.
+func writeEntry(t *testing.T, dir, key string, used time.Time) {
+	path := filepath.Join(dir, key[:2], key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"output":""}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, used, used); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// entries returns the paths of the cached results relative to dir.
+func entries(t *testing.T, dir string) []string {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		paths = append(paths, rel)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
@@ -17,8 +17,12 @@ package fn
 import (
 	"context"
 
+	"github.com/GoogleContainerTools/kpt/commands/fn/cache"
 	"github.com/GoogleContainerTools/kpt/commands/fn/doc"
+	"github.com/GoogleContainerTools/kpt/commands/fn/lock"
 	"github.com/GoogleContainerTools/kpt/commands/fn/render"
+	"github.com/GoogleContainerTools/kpt/commands/fn/serve"
+	"github.com/GoogleContainerTools/kpt/commands/fn/trace"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/cmdeval"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/cmdsink"
@@ -50,6 +54,10 @@ func GetCommand(ctx context.Context, name string) *cobra.Command {
 		doc.NewCommand(ctx, name),
 		cmdsource.NewCommand(ctx, name),
 		cmdsink.NewCommand(ctx, name),
+		cache.NewCommand(ctx, name),
+		trace.NewCommand(ctx, name),
+		lock.NewCommand(ctx, name),
+		serve.NewCommand(ctx, name),
 	)
 	return functions
 }
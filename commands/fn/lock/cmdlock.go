@@ -0,0 +1,167 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	command = "cmdfnlock"
+)
+
+func newRunner(ctx context.Context, parent string) *runner {
+	r := &runner{
+		ctx:            ctx,
+		resolveImage:   fnruntime.ResolveToImageForCLI,
+		resolveDigest:  fnruntime.ResolveImageDigest,
+		resolvedDigest: map[string]string{},
+	}
+	c := &cobra.Command{
+		Use:     "lock [PKG_PATH] [flags]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   fndocs.LockShort,
+		Long:    fndocs.LockShort + "\n" + fndocs.LockLong,
+		Example: fndocs.LockExamples,
+		RunE:    r.runE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return newRunner(ctx, parent).Command
+}
+
+type runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+
+	resolveImage  fnruntime.ImageResolveFunc
+	resolveDigest func(ctx context.Context, image string) (string, error)
+
+	// resolvedDigest caches the digests of the images resolved so far, so
+	// images used by multiple packages are only resolved once.
+	resolvedDigest map[string]string
+}
+
+func (r *runner) runE(_ *cobra.Command, args []string) error {
+	const op errors.Op = command + ".runE"
+	pkgPath := "."
+	if len(args) > 0 {
+		pkgPath = args[0]
+	}
+	pkgPath, err := argutil.ResolveSymlink(r.ctx, pkgPath)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	fsys := filesys.MakeFsOnDisk()
+	if _, err := os.Stat(filepath.Join(pkgPath, kptfilev1.KptFileName)); err != nil {
+		return errors.E(op, types.UniquePath(pkgPath), err)
+	}
+	subpkgs, err := pkg.Subpackages(fsys, pkgPath, pkg.All, true)
+	if err != nil {
+		return errors.E(op, types.UniquePath(pkgPath), err)
+	}
+
+	locked, pkgs := 0, 0
+	for _, p := range append([]string{"."}, subpkgs...) {
+		n, err := r.lockPackage(fsys, filepath.Join(pkgPath, p))
+		if err != nil {
+			return errors.E(op, types.UniquePath(filepath.Join(pkgPath, p)), err)
+		}
+		if n > 0 {
+			locked += n
+			pkgs++
+		}
+	}
+	printer.FromContextOrDie(r.ctx).Printf("Locked %d function image(s) in %d package(s).\n", locked, pkgs)
+	return nil
+}
+
+// lockPackage locks the function images of the pipeline of the package to
+// their digests, and returns the number of locked images.
+func (r *runner) lockPackage(fsys filesys.FileSystem, path string) (int, error) {
+	kf, err := pkg.ReadKptfile(fsys, path)
+	if err != nil {
+		return 0, err
+	}
+	var fns []kptfilev1.Function
+	if kf.Pipeline != nil {
+		fns = append(fns, kf.Pipeline.Mutators...)
+		fns = append(fns, kf.Pipeline.Validators...)
+	}
+
+	var locks []kptfilev1.ImageLock
+	seen := map[string]bool{}
+	for _, fn := range fns {
+		if fn.Image == "" {
+			continue
+		}
+		image, err := r.resolveImage(r.ctx, fn.Image)
+		if err != nil {
+			return 0, err
+		}
+		if seen[image] {
+			continue
+		}
+		seen[image] = true
+		digest, found := r.resolvedDigest[image]
+		if !found {
+			if digest, err = r.resolveDigest(r.ctx, image); err != nil {
+				return 0, err
+			}
+			r.resolvedDigest[image] = digest
+		}
+		locks = append(locks, kptfilev1.ImageLock{Image: image, Digest: digest})
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Image < locks[j].Image
+	})
+
+	var current []kptfilev1.ImageLock
+	if kf.Status != nil {
+		current = kf.Status.ImageLocks
+	}
+	if len(current) == 0 && len(locks) == 0 || reflect.DeepEqual(current, locks) {
+		return len(locks), nil
+	}
+	if kf.Status == nil {
+		kf.Status = &kptfilev1.Status{}
+	}
+	kf.Status.ImageLocks = locks
+	if len(kf.Status.Conditions) == 0 && len(locks) == 0 {
+		kf.Status = nil
+	}
+	return len(locks), kptfileutil.WriteFile(path, kf)
+}
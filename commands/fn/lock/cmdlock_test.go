@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestCmd_lock(t *testing.T) {
+	dir := t.TempDir()
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+  - image: set-labels:v0.1
+  - exec: sed
+  validators:
+  - image: gcr.io/my-org/validate:v1
+`)
+	writeKptfile(t, filepath.Join(dir, "sub"), `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: sub
+pipeline:
+  mutators:
+  - image: set-labels:v0.1
+`)
+	writeKptfile(t, filepath.Join(dir, "nopipeline"), `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: nopipeline
+`)
+
+	digests := map[string]string{
+		"gcr.io/kpt-fn/set-labels:v0.1": "sha256:1111",
+		"gcr.io/my-org/validate:v1":     "sha256:2222",
+	}
+	var resolved []string
+	lock := func() {
+		r := newRunner(fake.CtxWithDefaultPrinter(), "kpt")
+		r.resolveDigest = func(_ context.Context, image string) (string, error) {
+			resolved = append(resolved, image)
+			return digests[image], nil
+		}
+		r.Command.SetArgs([]string{dir})
+		assert.NoError(t, r.Command.Execute())
+	}
+	lock()
+	// images used by multiple packages are resolved once.
+	assert.ElementsMatch(t, []string{"gcr.io/kpt-fn/set-labels:v0.1", "gcr.io/my-org/validate:v1"}, resolved)
+
+	assert.Equal(t, []kptfilev1.ImageLock{
+		{Image: "gcr.io/kpt-fn/set-labels:v0.1", Digest: "sha256:1111"},
+		{Image: "gcr.io/my-org/validate:v1", Digest: "sha256:2222"},
+	}, imageLocks(t, dir))
+	assert.Equal(t, []kptfilev1.ImageLock{
+		{Image: "gcr.io/kpt-fn/set-labels:v0.1", Digest: "sha256:1111"},
+	}, imageLocks(t, filepath.Join(dir, "sub")))
+	assert.Empty(t, imageLocks(t, filepath.Join(dir, "nopipeline")))
+
+	// locking again updates the digests of moved tags.
+	digests["gcr.io/my-org/validate:v1"] = "sha256:3333"
+	lock()
+	assert.Equal(t, []kptfilev1.ImageLock{
+		{Image: "gcr.io/kpt-fn/set-labels:v0.1", Digest: "sha256:1111"},
+		{Image: "gcr.io/my-org/validate:v1", Digest: "sha256:3333"},
+	}, imageLocks(t, dir))
+}
+
+func writeKptfile(t *testing.T, dir, content string) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, kptfilev1.KptFileName), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func imageLocks(t *testing.T, dir string) []kptfilev1.ImageLock {
+	kf, err := pkg.ReadKptfile(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kf.Status == nil {
+		return nil
+	}
+	return kf.Status.ImageLocks
+}
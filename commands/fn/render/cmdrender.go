@@ -29,6 +29,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/render"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -48,6 +49,8 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	}
 	c.Flags().StringVar(&r.resultsDirPath, "results-dir", "",
 		"path to a directory to save function results")
+	c.Flags().StringVar(&r.traceDir, "trace-dir", "",
+		"path to a directory to record the input and output of every function run")
 	c.Flags().StringVarP(&r.dest, "output", "o", "",
 		fmt.Sprintf("output resources are written to provided location. Allowed values: %s|%s|<OUT_DIR_PATH>", cmdutil.Stdout, cmdutil.Unwrap))
 
@@ -57,10 +60,39 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		return r.RunnerOptions.ImagePullPolicy.AllStrings(), cobra.ShellCompDirectiveDefault
 	})
 
+	c.Flags().Var(&r.RunnerOptions.ImageLockMode, "image-lock",
+		"how the function images locked by `kpt fn lock` are used "+r.RunnerOptions.ImageLockMode.HelpAllowedValues())
+	_ = c.RegisterFlagCompletionFunc("image-lock", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return r.RunnerOptions.ImageLockMode.AllStrings(), cobra.ShellCompDirectiveDefault
+	})
+	c.Flags().BoolVar(&r.checkUpstream, "image-lock-check-upstream", false,
+		"check that the locked function images still resolve to their locked digests in their registries.")
+
 	c.Flags().BoolVar(&r.RunnerOptions.AllowExec, "allow-exec", r.RunnerOptions.AllowExec,
 		"allow binary executable to be run during pipeline execution.")
+	c.Flags().BoolVar(&r.RunnerOptions.SandboxExec, "sandbox-exec", false,
+		"run binary executables in a sandbox with a scrubbed environment, a temporary working directory and a timeout.")
 	c.Flags().BoolVar(&r.RunnerOptions.AllowWasm, "allow-alpha-wasm", r.RunnerOptions.AllowWasm,
 		"allow wasm to be used during pipeline execution.")
+	c.Flags().StringSliceVar(&r.RunnerOptions.AllowNetworkFor, "allow-network-for", nil,
+		"glob patterns of the images of the functions that are allowed network access if they request it in the pipeline.")
+	c.Flags().StringSliceVar(&r.RunnerOptions.AllowMountFor, "allow-mount-for", nil,
+		"glob patterns of the images of the functions that are allowed to mount package directories if they request it in the pipeline.")
+	c.Flags().BoolVar(&r.RunnerOptions.EnableBuiltins, "enable-builtin-fns", false,
+		"run the functions that have a builtin implementation in-process instead of running their image.")
+	c.Flags().StringVar(&r.fnRuntime, "fn-runtime", "",
+		"run the functions with the given runtime: grpc://HOST:PORT to use a function evaluator, record://DIR to record the function runs in DIR, replay://DIR to replay them.")
+	c.Flags().BoolVar(&r.fnCache, "fn-cache", false,
+		"cache the results of the functions, and reuse the cached results of previous runs with the same input.")
+	c.Flags().IntVar(&r.concurrency, "concurrency", 1,
+		"maximum number of independent subpackages to render concurrently.")
+	c.Flags().BoolVar(&r.dryRun, "dry-run", false,
+		"print the changes rendering would make to the package instead of writing them.")
+	c.Flags().StringVar(&r.diffFormat, "diff-format", render.DiffFormatUnified,
+		fmt.Sprintf("format of the changes printed by --dry-run. Allowed values: %s|%s", render.DiffFormatUnified, render.DiffFormatJSON))
+	_ = c.RegisterFlagCompletionFunc("diff-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{render.DiffFormatUnified, render.DiffFormatJSON}, cobra.ShellCompDirectiveDefault
+	})
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -74,15 +106,24 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 type Runner struct {
 	pkgPath        string
 	resultsDirPath string
+	traceDir       string
 	dest           string
+	fnRuntime      string
+	concurrency    int
+	fnCache        bool
+	checkUpstream  bool
+	dryRun         bool
+	diffFormat     string
 	Command        *cobra.Command
 	ctx            context.Context
+	runtime        fn.FunctionRuntime
 
 	RunnerOptions fnruntime.RunnerOptions
 }
 
 func (r *Runner) InitDefaults() {
 	r.RunnerOptions.InitDefaults()
+	r.RunnerOptions.ImageLockMode = fnruntime.ImageLockEnforce
 }
 
 func (r *Runner) preRunE(c *cobra.Command, args []string) error {
@@ -107,6 +148,33 @@ func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 			return err
 		}
 	}
+	if r.fnCache {
+		r.RunnerOptions.FnCacheDir, err = fnruntime.DefaultFnCacheDir()
+		if err != nil {
+			return err
+		}
+	}
+	if r.dryRun && r.dest != "" {
+		return fmt.Errorf("--dry-run and --output can not be used together")
+	}
+	if r.diffFormat != render.DiffFormatUnified && r.diffFormat != render.DiffFormatJSON {
+		return fmt.Errorf("unknown diff format %q, must be one of %s, %s", r.diffFormat, render.DiffFormatUnified, render.DiffFormatJSON)
+	}
+	if r.fnRuntime == "" {
+		r.fnRuntime = os.Getenv(fnruntime.FnRuntimeEnv)
+	}
+	if r.fnRuntime != "" {
+		scheme, _, err := fnruntime.ParseFnRuntime(r.fnRuntime)
+		if err != nil {
+			return err
+		}
+		if scheme == fnruntime.ReplayScheme && r.checkUpstream {
+			return fmt.Errorf("--image-lock-check-upstream can not be used with a %s function runtime", fnruntime.ReplayScheme)
+		}
+	}
+	if r.concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", r.concurrency)
+	}
 	if r.resultsDirPath != "" {
 		err := os.MkdirAll(r.resultsDirPath, 0755)
 		if err != nil {
@@ -128,16 +196,45 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	if r.RunnerOptions.FnPolicies, err = fnruntime.LoadFnPolicies(absPkgPath); err != nil {
+		return err
+	}
+	if r.checkUpstream && r.RunnerOptions.ImageLockMode != fnruntime.ImageLockIgnore {
+		r.RunnerOptions.ResolveDigest = fnruntime.NewDigestResolver()
+	}
+	if r.fnRuntime != "" {
+		runtime, closeRuntime, err := fnruntime.OpenFnRuntime(r.fnRuntime, &r.RunnerOptions)
+		if err != nil {
+			return err
+		}
+		defer closeRuntime()
+		r.runtime = runtime
+	}
 	executor := render.Renderer{
 		PkgPath:        absPkgPath,
 		ResultsDirPath: r.resultsDirPath,
 		Output:         output,
 		RunnerOptions:  r.RunnerOptions,
 		FileSystem:     filesys.FileSystemOrOnDisk{},
+		Concurrency:    r.concurrency,
+		DryRun:         r.dryRun,
+		TraceDir:       r.traceDir,
+		Runtime:        r.runtime,
 	}
 	if _, err := executor.Execute(r.ctx); err != nil {
 		return err
 	}
+	if r.dryRun {
+		pr := printer.FromContextOrDie(r.ctx)
+		if err := render.WriteChanges(pr.OutStream(), executor.Changes, r.diffFormat); err != nil {
+			return err
+		}
+		if len(executor.Changes) > 0 {
+			// a non-zero exit code lets CI verify that the package is rendered.
+			return fmt.Errorf("rendering would change %d file(s) in package %q", len(executor.Changes), r.pkgPath)
+		}
+		return nil
+	}
 
 	return cmdutil.WriteFnOutput(r.dest, outContent.String(), false, printer.FromContextOrDie(r.ctx).OutStream())
 }
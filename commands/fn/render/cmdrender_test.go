@@ -45,3 +45,84 @@ func TestCmd_flagAndArgParsing_Symlink(t *testing.T) {
 
 // NoOpRunE is a noop function to replace the run function of a command.  Useful for testing argument parsing.
 var NoOpRunE = func(cmd *cobra.Command, args []string) error { return nil }
+
+func TestCmd_dryRunFlags(t *testing.T) {
+	testCases := map[string]struct {
+		args      []string
+		expectErr string
+	}{
+		"dry run": {
+			args: []string{"--dry-run", "--diff-format", "json"},
+		},
+		"dry run with output": {
+			args:      []string{"--dry-run", "-o", "stdout"},
+			expectErr: "--dry-run and --output can not be used together",
+		},
+		"unknown diff format": {
+			args:      []string{"--dry-run", "--diff-format", "side-by-side"},
+			expectErr: `unknown diff format "side-by-side"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			defer testutil.Chdir(t, dir)()
+
+			r := NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+			r.Command.RunE = NoOpRunE
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			r.Command.SetArgs(tc.args)
+			err := r.Command.Execute()
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, r.dryRun)
+		})
+	}
+}
+
+func TestCmd_imageLockCheckUpstream(t *testing.T) {
+	testCases := map[string]struct {
+		args      []string
+		expectErr string
+	}{
+		"check upstream": {
+			args: []string{"--image-lock-check-upstream"},
+		},
+		"check upstream with recorded runs": {
+			args: []string{"--image-lock-check-upstream", "--fn-runtime", "record://fixtures"},
+		},
+		"check upstream with replayed runs": {
+			args:      []string{"--image-lock-check-upstream", "--fn-runtime", "replay://fixtures"},
+			expectErr: "--image-lock-check-upstream can not be used with a replay:// function runtime",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			defer testutil.Chdir(t, dir)()
+
+			r := NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+			r.Command.RunE = NoOpRunE
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			r.Command.SetArgs(tc.args)
+			err := r.Command.Execute()
+			if tc.expectErr != "" {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, r.checkUpstream)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serve
+
+import (
+	"context"
+	"net"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+const (
+	command = "cmdfnserve"
+)
+
+func newRunner(ctx context.Context, parent string) *runner {
+	r := &runner{
+		ctx:             ctx,
+		imagePullPolicy: fnruntime.IfNotPresentPull,
+	}
+	c := &cobra.Command{
+		Use:     "serve [flags]",
+		Args:    cobra.NoArgs,
+		Short:   fndocs.ServeShort,
+		Long:    fndocs.ServeShort + "\n" + fndocs.ServeLong,
+		Example: fndocs.ServeExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVar(&r.address, "address", "localhost:9445",
+		"the address the function evaluator listens on.")
+	c.Flags().StringVar(&r.config, "config", "",
+		"path to a configuration file mapping function images to executables.")
+	c.Flags().StringVar(&r.functions, "functions", ".",
+		"path to the directory containing the executables of the configuration file.")
+	c.Flags().Var(&r.imagePullPolicy, "image-pull-policy",
+		"pull image before running the container "+r.imagePullPolicy.HelpAllowedValues())
+	_ = c.RegisterFlagCompletionFunc("image-pull-policy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return r.imagePullPolicy.AllStrings(), cobra.ShellCompDirectiveDefault
+	})
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return newRunner(ctx, parent).Command
+}
+
+type runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+
+	address         string
+	config          string
+	functions       string
+	imagePullPolicy fnruntime.ImagePullPolicy
+}
+
+func (r *runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = command + ".runE"
+	evaluator := &fnruntime.FnEvaluator{ImagePullPolicy: r.imagePullPolicy}
+	if r.config != "" {
+		executables, err := fnruntime.LoadFnExecutables(r.functions, r.config)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		evaluator.Executables = executables
+	}
+	lis, err := net.Listen("tcp", r.address)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	server := fnruntime.NewFnEvaluatorServer(evaluator)
+	go func() {
+		<-r.ctx.Done()
+		server.GracefulStop()
+	}()
+	printer.FromContextOrDie(r.ctx).Printf("Serving the function evaluator on %s\n", lis.Addr())
+	if err := server.Serve(lis); err != nil {
+		return errors.E(op, err)
+	}
+	return nil
+}
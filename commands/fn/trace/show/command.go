@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package show
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+)
+
+const (
+	command = "cmdfntraceshow"
+)
+
+func newRunner(ctx context.Context, parent string) *runner {
+	r := &runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "show TRACE_DIR [STEP] [flags]",
+		Args:    cobra.RangeArgs(1, 2),
+		Short:   fndocs.ShowShort,
+		Long:    fndocs.ShowShort + "\n" + fndocs.ShowLong,
+		Example: fndocs.ShowExamples,
+		RunE:    r.runE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return newRunner(ctx, parent).Command
+}
+
+type runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+}
+
+func (r *runner) runE(_ *cobra.Command, args []string) error {
+	const op errors.Op = command + ".runE"
+	fsys := filesys.MakeFsOnDisk()
+	steps, err := fnruntime.ReadTrace(fsys, args[0])
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+	if len(args) == 1 {
+		return listSteps(out, steps)
+	}
+
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("step must be a number, got %q", args[1]))
+	}
+	for i, step := range steps {
+		if step.Step == n {
+			if err := diffStep(fsys, out, previousStep(steps[:i]), step); err != nil {
+				return errors.E(op, err)
+			}
+			return nil
+		}
+	}
+	return errors.E(op, errors.InvalidParam, fmt.Errorf("trace %q has no step %d", args[0], n))
+}
+
+// listSteps writes a table of the steps of a trace.
+func listSteps(w io.Writer, steps []fnruntime.TraceStep) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tPACKAGE\tFUNCTION\tEXIT CODE\tDURATION")
+	for _, step := range steps {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%v\n", step.Step, step.Pkg, step.Function, step.ExitCode, step.Duration)
+	}
+	return tw.Flush()
+}
+
+// previousStep returns the last step that succeeded, and so recorded its
+// output, or nil if there is none.
+func previousStep(steps []fnruntime.TraceStep) *fnruntime.TraceStep {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].ExitCode == 0 {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+// diffStep writes the unified diff between the resources returned by the
+// previous step, or the resources the first step was run on, and the
+// resources returned by the function of the step.
+func diffStep(fsys filesys.FileSystem, w io.Writer, prev *fnruntime.TraceStep, step fnruntime.TraceStep) error {
+	if step.ExitCode != 0 {
+		stderr, _ := fsys.ReadFile(filepath.Join(step.Dir, fnruntime.TraceStderrFile))
+		_, err := fmt.Fprintf(w, "Step %d (%s) failed with exit code %d.\n%s", step.Step, step.Function, step.ExitCode, stderr)
+		return err
+	}
+	from, fromFile, fromName := step, fnruntime.TraceInputFile, "input"
+	if prev != nil {
+		from, fromFile, fromName = *prev, fnruntime.TraceOutputFile, "output"
+	}
+	before, err := readResources(fsys, from, fromFile)
+	if err != nil {
+		return err
+	}
+	after, err := readResources(fsys, step, fnruntime.TraceOutputFile)
+	if err != nil {
+		return err
+	}
+	if before == after {
+		_, err := fmt.Fprintf(w, "Step %d (%s) made no changes.\n", step.Step, step.Function)
+		return err
+	}
+	return difflib.WriteUnifiedDiff(w, difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: fmt.Sprintf("step %d (%s) %s", from.Step, from.Function, fromName),
+		ToFile:   fmt.Sprintf("step %d (%s) output", step.Step, step.Function),
+		Context:  3,
+	})
+}
+
+// readResources returns the resources recorded in the given file of the
+// step, without the annotations that only matter to kpt.
+func readResources(fsys filesys.FileSystem, step fnruntime.TraceStep, file string) (string, error) {
+	nodes, err := fnruntime.ReadTraceResources(fsys, step, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s of step %d: %w", file, step.Step, err)
+	}
+	if len(nodes) == 0 {
+		return "", nil
+	}
+	out := &bytes.Buffer{}
+	err = kio.ByteWriter{
+		Writer: out,
+		Sort:   true,
+		ClearAnnotations: []string{
+			kioutil.IdAnnotation,
+			kioutil.LegacyIdAnnotation,
+			kioutil.InternalAnnotationsMigrationResourceIDAnnotation,
+			fnruntime.ResourceIDAnnotation,
+		},
+	}.Write(nodes)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// splitLines splits s into lines that each end with a newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return difflib.SplitLines(strings.TrimSuffix(s, "\n"))
+}
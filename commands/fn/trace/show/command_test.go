@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package show
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+func TestCmd_show(t *testing.T) {
+	traceDir := t.TempDir()
+	writeTrace(t, traceDir)
+
+	testCases := map[string]struct {
+		args        []string
+		expected    []string
+		notExpected []string
+		expectErr   string
+	}{
+		"list steps": {
+			args:     []string{traceDir},
+			expected: []string{"STEP  PACKAGE  FUNCTION  EXIT CODE", "1     .        scale     0", "3     .        validate  2"},
+		},
+		"first step is diffed against its input": {
+			args: []string{traceDir, "1"},
+			expected: []string{
+				"--- step 1 (scale) input\n+++ step 1 (scale) output\n",
+				"-  replicas: 1\n+  replicas: 3\n",
+			},
+		},
+		"step is diffed against the previous step": {
+			args: []string{traceDir, "2"},
+			expected: []string{
+				"--- step 1 (scale) output\n+++ step 2 (label) output\n",
+				"+    app: nginx\n",
+			},
+			notExpected: []string{"replicas"},
+		},
+		"failed step": {
+			args:     []string{traceDir, "3"},
+			expected: []string{"Step 3 (validate) failed with exit code 2.\nreplicas must be 1"},
+		},
+		"missing step": {
+			args:      []string{traceDir, "4"},
+			expectErr: "has no step 4",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			r := newRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			r.Command.SetArgs(tc.args)
+			err := r.Command.Execute()
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, e := range tc.expected {
+				assert.Contains(t, out.String(), e)
+			}
+			for _, e := range tc.notExpected {
+				assert.NotContains(t, out.String(), e)
+			}
+		})
+	}
+}
+
+// writeTrace records a trace of a function scaling a deployment, a function
+// labeling it, and a function failing to validate it.
+func writeTrace(t *testing.T, dir string) {
+	tracer, err := fnruntime.NewTracer(filesys.MakeFsOnDisk(), dir, types.UniquePath("/pkg"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	replace := func(old, new string) func(io.Reader, io.Writer) error {
+		return func(r io.Reader, w io.Writer) error {
+			b, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write([]byte(strings.ReplaceAll(string(b), old, new)))
+			return err
+		}
+	}
+	fail := func(io.Reader, io.Writer) error {
+		return &fnruntime.ExecError{ExitCode: 2, Stderr: "replicas must be 1"}
+	}
+
+	resources, err := kio.FromBytes([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  labels:
+    tier: web
+spec:
+  replicas: 1
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, f := range []struct {
+		name string
+		run  func(io.Reader, io.Writer) error
+	}{
+		{name: "scale", run: replace("replicas: 1", "replicas: 3")},
+		{name: "label", run: replace("tier: web", "tier: web\n      app: nginx")},
+		{name: "validate", run: fail},
+	} {
+		fr, err := fnruntime.NewFunctionRunner(fake.CtxWithDefaultPrinter(), &runtimeutil.FunctionFilter{Run: f.run},
+			types.UniquePath("/pkg"), &fnresult.Result{Image: f.name}, fnresult.NewResultList(),
+			fnruntime.RunnerOptions{Tracer: tracer})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if output, err := fr.Filter(resources); err == nil {
+			resources = output
+		}
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"github.com/GoogleContainerTools/kpt/commands/pkg/diff"
 	"github.com/GoogleContainerTools/kpt/commands/pkg/get"
 	initialization "github.com/GoogleContainerTools/kpt/commands/pkg/init"
+	"github.com/GoogleContainerTools/kpt/commands/pkg/push"
+	"github.com/GoogleContainerTools/kpt/commands/pkg/resolve"
 	"github.com/GoogleContainerTools/kpt/commands/pkg/update"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/cmdtree"
@@ -47,7 +49,8 @@ func GetCommand(ctx context.Context, name string) *cobra.Command {
 	pkg.AddCommand(
 		get.NewCommand(ctx, name), initialization.NewCommand(ctx, name),
 		update.NewCommand(ctx, name), diff.NewCommand(ctx, name),
-		cmdtree.NewCommand(ctx, name),
+		push.NewCommand(ctx, name), cmdtree.NewCommand(ctx, name),
+		resolve.NewCommand(ctx, name),
 	)
 	return pkg
 }
@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/ociutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/push"
+	porchapi "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/spf13/cobra"
+)
+
+var lifecycles = []porchapi.PackageRevisionLifecycle{
+	porchapi.PackageRevisionLifecycleDraft,
+	porchapi.PackageRevisionLifecycleProposed,
+	porchapi.PackageRevisionLifecyclePublished,
+}
+
+// NewRunner returns a command runner.
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:          "push [PKG_PATH] oci://IMAGE[:TAG]",
+		Args:         cobra.RangeArgs(1, 2),
+		Short:        docs.PushShort,
+		Long:         docs.PushShort + "\n" + docs.PushLong,
+		Example:      docs.PushExamples,
+		PreRunE:      r.preRunE,
+		RunE:         r.runE,
+		SilenceUsage: true,
+	}
+	c.Flags().StringVar(&r.lifecycle, "lifecycle", string(porchapi.PackageRevisionLifecycleDraft),
+		"lifecycle of the package revision recorded on the image -- must be one of: "+
+			strings.Join(lifecycleStrings(), ","))
+	c.Flags().StringVar(&r.Push.Revision, "revision", "",
+		"revision of the package revision recorded on the image, e.g. v1. Requires --lifecycle=Published.")
+	_ = c.RegisterFlagCompletionFunc("lifecycle", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return lifecycleStrings(), cobra.ShellCompDirectiveDefault
+	})
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx       context.Context
+	Push      push.Command
+	Command   *cobra.Command
+	lifecycle string
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdpush.preRunE"
+	if len(args) == 1 {
+		args = append([]string{pkg.CurDir}, args...)
+	}
+	if !ociutil.HasOciScheme(args[1]) {
+		return errors.E(op, errors.InvalidParam,
+			fmt.Errorf("image %q must start with %q", args[1], ociutil.Scheme))
+	}
+
+	resolvedPath, err := argutil.ResolveSymlink(r.ctx, args[0])
+	if err != nil {
+		return errors.E(op, err)
+	}
+	absPath, _, err := pathutil.ResolveAbsAndRelPaths(resolvedPath)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	r.Push.Path = absPath
+	r.Push.Image = args[1]
+
+	lifecycle, err := toLifecycle(r.lifecycle)
+	if err != nil {
+		return errors.E(op, errors.InvalidParam, err)
+	}
+	r.Push.Lifecycle = lifecycle
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = "cmdpush.runE"
+	if err := r.Push.Run(r.ctx); err != nil {
+		return errors.E(op, types.UniquePath(r.Push.Path), err)
+	}
+	// The pushed image is written to stdout so it can be recorded by scripts.
+	image, err := ociutil.ImageWithRef(r.Push.Image, r.Push.Digest)
+	if err != nil {
+		return errors.E(op, errors.OCI, err)
+	}
+	fmt.Fprintln(printer.FromContextOrDie(r.ctx).OutStream(), image)
+	return nil
+}
+
+// toLifecycle returns the lifecycle matching s, ignoring case.
+func toLifecycle(s string) (porchapi.PackageRevisionLifecycle, error) {
+	for _, l := range lifecycles {
+		if strings.EqualFold(s, string(l)) {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown lifecycle %q, must be one of: %s",
+		s, strings.Join(lifecycleStrings(), ","))
+}
+
+func lifecycleStrings() []string {
+	var s []string
+	for _, l := range lifecycles {
+		s = append(s, string(l))
+	}
+	return s
+}
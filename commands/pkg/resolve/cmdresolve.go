@@ -0,0 +1,97 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"context"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "resolve [PKG_PATH]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   docs.ResolveShort,
+		Long:    docs.ResolveShort + "\n" + docs.ResolveLong,
+		Example: docs.ResolveExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().BoolVar(&r.list, "list", false,
+		"list the conflicts without resolving them.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx     context.Context
+	list    bool
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdresolve.runE"
+	if len(args) == 0 {
+		args = append(args, pkg.CurDir)
+	}
+	absPath, _, err := pathutil.ResolveAbsAndRelPaths(args[0])
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	var conflicts []merge.Conflict
+	if r.list {
+		conflicts, err = merge.PackageConflicts(absPath)
+	} else {
+		conflicts, err = merge.ResolvePackage(absPath)
+	}
+	if err != nil {
+		return errors.E(op, types.UniquePath(absPath), err)
+	}
+
+	pr := printer.FromContextOrDie(r.ctx)
+	for _, c := range conflicts {
+		if c.Resource == "" {
+			pr.Printf("%s: conflict markers\n", c.File)
+			continue
+		}
+		pr.Printf("%s (%s):\n", c.Resource, c.File)
+		for _, f := range c.Fields {
+			pr.Printf("  %s: local %q, upstream %q\n", f.Field, f.Local, f.Upstream)
+		}
+	}
+	if !r.list {
+		pr.Printf("Resolved the conflicts of %d resource(s).\n", len(conflicts))
+	}
+	return nil
+}
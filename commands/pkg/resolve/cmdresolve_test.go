@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/commands/pkg/resolve"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+const conflictingDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: /nginx
+  name: nginx
+  annotations:
+    kpt.dev/merge-conflicts: |
+      - field: spec.replicas
+        origin: "3"
+        local: "5"
+        upstream: "4"
+spec:
+  replicas: 4
+`
+
+func TestCmd(t *testing.T) {
+	testCases := map[string]struct {
+		args           []string
+		markers        bool
+		expectedOutput string
+		expectedFile   string
+		expectedErr    string
+	}{
+		"list": {
+			args: []string{"--list"},
+			expectedOutput: `apps/v1/Deployment/nginx (deployment.yaml):
+  spec.replicas: local "5", upstream "4"
+`,
+			expectedFile: conflictingDeployment,
+		},
+		"list with conflict markers": {
+			args:    []string{"--list"},
+			markers: true,
+			expectedOutput: `apps/v1/Deployment/nginx (deployment.yaml):
+  spec.replicas: local "5", upstream "4"
+README.md: conflict markers
+`,
+			expectedFile: conflictingDeployment,
+		},
+		"resolve with conflict markers": {
+			markers:      true,
+			expectedFile: conflictingDeployment,
+			expectedErr:  "the conflict markers of these files must be removed first: README.md",
+		},
+		"resolve": {
+			expectedOutput: `apps/v1/Deployment/nginx (deployment.yaml):
+  spec.replicas: local "5", upstream "4"
+Resolved the conflicts of 1 resource(s).
+`,
+			expectedFile: `apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: /nginx
+  name: nginx
+spec:
+  replicas: 4
+`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			d := t.TempDir()
+			file := filepath.Join(d, "deployment.yaml")
+			if err := os.WriteFile(file, []byte(conflictingDeployment), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if tc.markers {
+				readme := "<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\n"
+				if err := os.WriteFile(filepath.Join(d, "README.md"), []byte(readme), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			out := &bytes.Buffer{}
+			r := resolve.NewRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SetArgs(append([]string{d}, tc.args...))
+			err := r.Command.Execute()
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+			} else {
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				assert.Equal(t, tc.expectedOutput, out.String())
+			}
+
+			b, err := os.ReadFile(file)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFile, string(b))
+		})
+	}
+}
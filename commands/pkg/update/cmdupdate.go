@@ -23,10 +23,13 @@ import (
 
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
 	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/argutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
@@ -39,6 +42,7 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	r := &Runner{
 		ctx: ctx,
 	}
+	r.Update.FnRunnerOptions.InitDefaults()
 	c := &cobra.Command{
 		Use:        "update [PKG_PATH@VERSION] [flags]",
 		Short:      docs.UpdateShort,
@@ -57,11 +61,29 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	_ = c.RegisterFlagCompletionFunc("strategy", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return kptfilev1.UpdateStrategiesAsStrings(), cobra.ShellCompDirectiveDefault
 	})
+	c.Flags().StringVar(&r.onConflict, "on-conflict", string(merge.ConflictUseUpstream),
+		"how the resource-merge strategy handles fields changed both locally and in upstream -- must be one of: "+
+			strings.Join(merge.ConflictModesAsStrings(), ","))
+	_ = c.RegisterFlagCompletionFunc("on-conflict", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return merge.ConflictModesAsStrings(), cobra.ShellCompDirectiveDefault
+	})
+	c.Flags().BoolVar(&r.Update.FnRunnerOptions.AllowExec, "allow-exec", false,
+		"allow the merge function of the function-merge strategy to be a binary executable.")
+	c.Flags().BoolVar(&r.preview, "preview", false,
+		"print the changes the update would make to the package without modifying it.")
+	c.Flags().StringVar(&r.previewFormat, "preview-format", update.PreviewFormatText,
+		"the format of the changes printed with --preview -- must be one of: "+
+			strings.Join(previewFormats, ","))
+	_ = c.RegisterFlagCompletionFunc("preview-format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return previewFormats, cobra.ShellCompDirectiveDefault
+	})
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
 }
 
+var previewFormats = []string{update.PreviewFormatText, update.PreviewFormatJSON}
+
 func NewCommand(ctx context.Context, parent string) *cobra.Command {
 	return NewRunner(ctx, parent).Command
 }
@@ -69,10 +91,13 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 // Runner contains the run function.
 // TODO, support listing versions
 type Runner struct {
-	ctx      context.Context
-	strategy string
-	Update   update.Command
-	Command  *cobra.Command
+	ctx           context.Context
+	strategy      string
+	onConflict    string
+	preview       bool
+	previewFormat string
+	Update        update.Command
+	Command       *cobra.Command
 }
 
 func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
@@ -85,6 +110,23 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 	} else {
 		r.Update.Strategy = kptfilev1.UpdateStrategyType(r.strategy)
 	}
+	switch mode := merge.ConflictMode(r.onConflict); mode {
+	case "":
+		r.Update.ConflictMode = merge.ConflictUseUpstream
+	case merge.ConflictUseUpstream, merge.ConflictFail, merge.ConflictAnnotate:
+		r.Update.ConflictMode = mode
+	default:
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("--on-conflict must be one of %s",
+			strings.Join(merge.ConflictModesAsStrings(), ",")))
+	}
+	switch r.previewFormat {
+	case "":
+		r.previewFormat = update.PreviewFormatText
+	case update.PreviewFormatText, update.PreviewFormatJSON:
+	default:
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("--preview-format must be one of %s",
+			strings.Join(previewFormats, ",")))
+	}
 
 	parts := strings.Split(args[0], "@")
 	if len(parts) > 2 {
@@ -106,6 +148,12 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 
 	r.Update.Pkg = p
 
+	// the merge function of the function-merge strategy is restricted by
+	// the function policies like the functions of the pipeline.
+	if r.Update.FnRunnerOptions.FnPolicies, err = fnruntime.LoadFnPolicies(absResolvedPath); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+
 	// TODO: Make sure we handle this in a centralized library and do
 	// this consistently across all commands.
 	relPath, err := resolveRelPath(p.UniquePath)
@@ -124,6 +172,16 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 
 func (r *Runner) runE(c *cobra.Command, _ []string) error {
 	const op errors.Op = "cmdupdate.runE"
+	if r.preview {
+		p, err := r.Update.Preview(r.ctx)
+		if err != nil {
+			return errors.E(op, r.Update.Pkg.UniquePath, err)
+		}
+		if err := update.WritePreview(printer.FromContextOrDie(r.ctx).OutStream(), p, r.previewFormat); err != nil {
+			return errors.E(op, r.Update.Pkg.UniquePath, err)
+		}
+		return nil
+	}
 	if err := r.Update.Run(r.ctx); err != nil {
 		return errors.E(op, r.Update.Pkg.UniquePath, err)
 	}
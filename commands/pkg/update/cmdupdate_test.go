@@ -16,6 +16,7 @@ package update_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -26,13 +27,19 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/commands/pkg/get"
 	"github.com/GoogleContainerTools/kpt/commands/pkg/update"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	updatepkg "github.com/GoogleContainerTools/kpt/internal/util/update"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -132,6 +139,72 @@ func TestCmd_execute(t *testing.T) {
 	}
 }
 
+// TestCmd_preview verifies that the update preview reports the changes
+// without modifying the package.
+func TestCmd_preview(t *testing.T) {
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+
+	defer testutil.Chdir(t, w.WorkspaceDirectory)()
+
+	dest := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+
+	getCmd := get.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	getCmd.Command.SetArgs([]string{"file://" + g.RepoDirectory + ".git", w.WorkspaceDirectory})
+	if !assert.NoError(t, getCmd.Command.Execute()) {
+		return
+	}
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		return
+	}
+	if _, err := g.Commit("modify upstream package -- ds2"); !assert.NoError(t, err) {
+		return
+	}
+
+	out := &bytes.Buffer{}
+	updateCmd := update.NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	updateCmd.Command.SetArgs([]string{g.RepoName, "--preview", "--preview-format", "json"})
+	if !assert.NoError(t, updateCmd.Command.Execute()) {
+		return
+	}
+	// the package is not updated
+	if !g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1), dest, true) {
+		return
+	}
+
+	var preview updatepkg.Preview
+	if !assert.NoError(t, json.Unmarshal(out.Bytes(), &preview)) {
+		return
+	}
+	var mysql *updatepkg.ResourceChange
+	for i := range preview.Resources {
+		if preview.Resources[i].Kind == "StatefulSet" && preview.Resources[i].Name == "mysql" {
+			mysql = &preview.Resources[i]
+		}
+	}
+	if assert.NotNil(t, mysql) {
+		assert.Equal(t, updatepkg.Modified, mysql.Status)
+		assert.Contains(t, mysql.Fields, updatepkg.FieldChange{
+			Field:  "spec.template.spec.containers[name=mysql].image",
+			Status: updatepkg.Modified,
+			Before: "mysql:5.7",
+			After:  "mysql:8.0",
+		})
+	}
+
+	out.Reset()
+	updateCmd = update.NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	updateCmd.Command.SetArgs([]string{g.RepoName, "--preview"})
+	if !assert.NoError(t, updateCmd.Command.Execute()) {
+		return
+	}
+	assert.Contains(t, out.String(), "Modified apps/v1/StatefulSet/mysql (mysql/mysql-statefulset.resource.yaml)")
+	assert.Contains(t, out.String(), `Modified spec.template.spec.containers[name=mysql].image: "mysql:5.7" -> "mysql:8.0"`)
+}
+
 func TestCmd_successUnCommitted(t *testing.T) {
 	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
 		Data:   testutil.Dataset1,
@@ -178,6 +251,63 @@ func TestCmd_successUnCommitted(t *testing.T) {
 	}
 }
 
+// TestCmd_deniedMergeFunction verifies that the merge function of the
+// function-merge strategy is restricted by the function policies.
+func TestCmd_deniedMergeFunction(t *testing.T) {
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+
+	defer testutil.Chdir(t, w.WorkspaceDirectory)()
+
+	policy := filepath.Join(t.TempDir(), fnruntime.FnPolicyFile)
+	err := os.WriteFile(policy, []byte(`apiVersion: kpt.dev/v1alpha1
+kind: FunctionPolicy
+spec:
+  deniedImages:
+  - gcr.io/kpt-fn/merge-*
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Setenv(fnruntime.FnPolicyEnv, policy)
+
+	dest := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+
+	// clone the repo
+	getCmd := get.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	getCmd.Command.SetArgs([]string{"file://" + g.RepoDirectory + ".git", w.WorkspaceDirectory})
+	if !assert.NoError(t, getCmd.Command.Execute()) {
+		t.FailNow()
+	}
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, dest)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	kf.Upstream.MergeFunction = &kptfilev1.Function{Image: "gcr.io/kpt-fn/merge-replicas:v0.1"}
+	if !assert.NoError(t, kptfileutil.WriteFile(dest, kf)) {
+		t.FailNow()
+	}
+
+	// update the master branch
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		t.FailNow()
+	}
+	if _, err := g.Commit("new dataset"); !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// update the cloned package
+	updateCmd := update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	updateCmd.Command.SetArgs([]string{g.RepoName, "--strategy", string(updatepkg.FunctionMerge)})
+	err = updateCmd.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `image matches denied image "gcr.io/kpt-fn/merge-*"`)
+	}
+}
+
 func TestCmd_successNoGit(t *testing.T) {
 	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
 		Data:   testutil.Dataset1,
@@ -342,6 +472,22 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, kptfilev1.ResourceMerge, r.Update.Strategy)
 	assert.Equal(t, "", r.Update.Ref)
+	assert.Equal(t, merge.ConflictUseUpstream, r.Update.ConflictMode)
+
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.RunE = NoOpRunE
+	r.Command.SetArgs([]string{dir, "--on-conflict", "annotate"})
+	err = r.Command.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, merge.ConflictAnnotate, r.Update.ConflictMode)
+
+	r = update.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{dir, "--on-conflict", "keep-local"})
+	err = r.Command.Execute()
+	assert.EqualError(t, err, "cmdupdate.preRunE: invalid parameter value: --on-conflict must be one of use-upstream,fail,annotate")
 }
 
 func TestCmd_flagAndArgParsing_Symlink(t *testing.T) {
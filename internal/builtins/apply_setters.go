@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/GoogleContainerTools/kpt-functions-catalog/functions/go/apply-setters/applysetters"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+)
+
+// ApplySetters is a built-in implementation of the apply-setters function
+// of the catalog. It sets the fields tagged by setter comments to the
+// values of the setters in its ConfigMap functionConfig.
+type ApplySetters struct{}
+
+// Run implements the function signature defined in
+// sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil/FunctionFilter.Run.
+func (as *ApplySetters) Run(r io.Reader, w io.Writer) error {
+	return runProcessor(as, r, w)
+}
+
+// Process implements framework.ResourceListProcessor interface.
+func (as *ApplySetters) Process(resourceList *framework.ResourceList) error {
+	setters := applysetters.ApplySetters{}
+	if resourceList.FunctionConfig != nil {
+		applysetters.Decode(resourceList.FunctionConfig, &setters)
+	}
+	if _, err := setters.Filter(resourceList.Items); err != nil {
+		resourceList.Results = framework.Results{
+			&framework.Result{
+				Message:  fmt.Sprintf("failed to apply setters: %s", err.Error()),
+				Severity: framework.Error,
+			},
+		}
+		return resourceList.Results
+	}
+	if len(setters.Results) == 0 {
+		resourceList.Results = framework.Results{
+			&framework.Result{
+				Message:  "no matches for input setter(s)",
+				Severity: framework.Info,
+			},
+		}
+		return nil
+	}
+	for _, res := range setters.Results {
+		resourceList.Results = append(resourceList.Results, &framework.Result{
+			Message:  fmt.Sprintf("set field value to %q", res.Value),
+			Severity: framework.Info,
+			Field:    &framework.Field{Path: res.FieldPath},
+			File:     &framework.File{Path: res.FilePath},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"io"
+
+	"github.com/GoogleContainerTools/kpt-functions-catalog/functions/go/set-namespace/transformer"
+	fnsdk "github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+// The functions of the catalog that have a builtin implementation. When
+// updating the version of a function, make sure the builtin implements the
+// same behavior as the function image.
+func init() {
+	Register(&Builtin{
+		Repository: "gcr.io/kpt-fn/set-namespace",
+		Versions:   []string{"v0.4"},
+		Digests:    []string{"sha256:f930d9248001fa763799cc81cf2d89bbf83954fc65de0db20ab038a21784f323"},
+		Run:        sdkRunFunc(transformer.SetNamespace),
+	})
+	Register(&Builtin{
+		Repository: "gcr.io/kpt-fn/starlark",
+		Versions:   []string{"v0.4"},
+		Digests:    []string{"sha256:6ba3971c64abcd6c3d93039d45721bb5ab496c7fbbc9ac1e685b11577f368ce0"},
+		Run:        (&StarlarkRun{}).Run,
+	})
+	Register(&Builtin{
+		Repository: "gcr.io/kpt-fn/apply-setters",
+		Versions:   []string{"v0.2"},
+		Run:        (&ApplySetters{}).Run,
+	})
+	Register(&Builtin{
+		Repository: "gcr.io/kpt-fn/set-labels",
+		Versions:   []string{"v0.1"},
+		Run:        (&SetLabels{}).Run,
+	})
+	Register(&Builtin{
+		Repository: "gcr.io/kpt-fn/set-annotations",
+		Versions:   []string{"v0.1"},
+		Run:        (&SetAnnotations{}).Run,
+	})
+}
+
+// sdkRunFunc returns the RunFunc running a function written with the kpt
+// functions SDK.
+func sdkRunFunc(fn fnsdk.ResourceListProcessorFunc) RunFunc {
+	return func(r io.Reader, w io.Writer) error {
+		return fnsdk.Execute(fn, r, w)
+	}
+}
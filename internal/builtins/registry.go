@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+// RunFunc runs a KRM function. It reads the function input `resourceList`
+// from r and writes the function output to w, like
+// sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil/FunctionFilter.Run.
+type RunFunc func(r io.Reader, w io.Writer) error
+
+// Builtin is a native implementation of a function of the catalog that runs
+// in-process instead of in a container.
+type Builtin struct {
+	// Repository is the image repository of the function, e.g.
+	// gcr.io/kpt-fn/set-namespace.
+	Repository string
+
+	// Versions are the minor versions of the function image the builtin
+	// implements, e.g. v0.4. An image tagged with a minor version, or with
+	// a patch version of it such as v0.4.1, is run by the builtin.
+	Versions []string
+
+	// Digests are the digests of the function images the builtin
+	// implements.
+	Digests []string
+
+	// Run runs the function.
+	Run RunFunc
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Builtin{}
+)
+
+// Register adds a builtin function to the registry, replacing the builtin
+// previously registered for the same repository.
+func Register(b *Builtin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[b.Repository] = b
+}
+
+// Lookup returns the builtin function implementing the image, if any.
+func Lookup(image string) (*Builtin, bool) {
+	repo, tag, digest := splitImage(image)
+	registryMu.RLock()
+	b, found := registry[repo]
+	registryMu.RUnlock()
+	if !found {
+		return nil, false
+	}
+	if digest != "" {
+		for _, d := range b.Digests {
+			if d == digest {
+				return b, true
+			}
+		}
+		return nil, false
+	}
+	for _, v := range b.Versions {
+		if tag == v || strings.HasPrefix(tag, v+".") {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// splitImage splits an image into its repository, and its tag or digest.
+func splitImage(image string) (repo, tag, digest string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], "", image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:], ""
+	}
+	return image, "", ""
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+func TestLookup(t *testing.T) {
+	testCases := map[string]struct {
+		image string
+		found bool
+	}{
+		"minor version": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.4",
+			found: true,
+		},
+		"patch version": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.4.1",
+			found: true,
+		},
+		"known digest": {
+			image: "gcr.io/kpt-fn/set-namespace@sha256:f930d9248001fa763799cc81cf2d89bbf83954fc65de0db20ab038a21784f323",
+			found: true,
+		},
+		"unknown digest": {
+			image: "gcr.io/kpt-fn/set-namespace@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		"other minor version": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.3.4",
+		},
+		"version with same prefix": {
+			image: "gcr.io/kpt-fn/set-labels:v0.10",
+		},
+		"untagged image": {
+			image: "gcr.io/kpt-fn/set-labels",
+		},
+		"other function": {
+			image: "gcr.io/kpt-fn/kubeval:v0.3",
+		},
+		"fork of a function": {
+			image: "gcr.io/my-org/set-namespace:v0.4",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			_, found := Lookup(tc.image)
+			assert.Equal(t, tc.found, found)
+		})
+	}
+}
+
+func TestCatalogBuiltins(t *testing.T) {
+	const deployment = `
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+    namespace: default
+  spec:
+    replicas: 1 # kpt-set: ${replicas}
+    selector:
+      matchLabels:
+        app: nginx
+    template:
+      metadata:
+        labels:
+          app: nginx
+`
+	testCases := map[string]struct {
+		image          string
+		functionConfig string
+		expected       []string
+		expectErr      bool
+	}{
+		"set-namespace": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.4.1",
+			functionConfig: `
+  apiVersion: v1
+  kind: ConfigMap
+  data:
+    namespace: prod`,
+			expected: []string{"namespace: prod"},
+		},
+		"set-labels": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1.5",
+			functionConfig: `
+  apiVersion: v1
+  kind: ConfigMap
+  data:
+    tier: web`,
+			// the label is set on the resource, the selector and the template
+			expected: []string{"\n    tier: web\n", "\n      tier: web\n", "\n        tier: web\n"},
+		},
+		"set-annotations": {
+			image: "gcr.io/kpt-fn/set-annotations:v0.1",
+			functionConfig: `
+  apiVersion: fn.kpt.dev/v1alpha1
+  kind: SetAnnotations
+  annotations:
+    owner: team-a`,
+			expected: []string{"\n    owner: team-a\n", "\n        owner: team-a\n"},
+		},
+		"set-annotations without annotations": {
+			image: "gcr.io/kpt-fn/set-annotations:v0.1",
+			functionConfig: `
+  apiVersion: fn.kpt.dev/v1alpha1
+  kind: SetAnnotations`,
+			expectErr: true,
+		},
+		"apply-setters": {
+			image: "gcr.io/kpt-fn/apply-setters:v0.2.0",
+			functionConfig: `
+  apiVersion: v1
+  kind: ConfigMap
+  data:
+    replicas: "3"`,
+			expected: []string{"replicas: 3 # kpt-set: ${replicas}"},
+		},
+		"starlark": {
+			image: "gcr.io/kpt-fn/starlark:v0.4",
+			functionConfig: `
+  apiVersion: fn.kpt.dev/v1alpha1
+  kind: StarlarkRun
+  metadata:
+    name: scale
+  source: |
+    for r in ctx.resource_list["items"]:
+      r["spec"]["replicas"] = 5`,
+			expected: []string{"replicas: 5"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			b, found := Lookup(tc.image)
+			if !assert.True(t, found) {
+				t.FailNow()
+			}
+			in := "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\nitems:" + deployment +
+				"functionConfig:" + tc.functionConfig + "\n"
+			out := &bytes.Buffer{}
+			err := b.Run(strings.NewReader(in), out)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			nodes, err := (&kio.ByteReader{Reader: out}).Read()
+			if !assert.NoError(t, err) || !assert.Len(t, nodes, 1) {
+				t.FailNow()
+			}
+			s := nodes[0].MustString()
+			for _, e := range tc.expected {
+				assert.Contains(t, s, e)
+			}
+		})
+	}
+}
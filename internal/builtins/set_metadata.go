@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/kustomize/api/filters/annotations"
+	"sigs.k8s.io/kustomize/api/filters/labels"
+	"sigs.k8s.io/kustomize/api/konfig/builtinpluginconsts"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// SetLabels is a built-in implementation of the set-labels function of the
+// catalog. It sets the labels of its functionConfig on the resources, and on
+// the selectors and templates that must match them.
+type SetLabels struct{}
+
+// Run implements the function signature defined in
+// sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil/FunctionFilter.Run.
+func (sl *SetLabels) Run(r io.Reader, w io.Writer) error {
+	return runProcessor(sl, r, w)
+}
+
+// Process implements framework.ResourceListProcessor interface.
+func (sl *SetLabels) Process(resourceList *framework.ResourceList) error {
+	return setMetadata(resourceList, "labels", "commonlabels", func(m map[string]string, fs types.FsSlice) kio.Filter {
+		return labels.Filter{Labels: m, FsSlice: fs}
+	})
+}
+
+// SetAnnotations is a built-in implementation of the set-annotations
+// function of the catalog. It sets the annotations of its functionConfig on
+// the resources and their templates.
+type SetAnnotations struct{}
+
+// Run implements the function signature defined in
+// sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil/FunctionFilter.Run.
+func (sa *SetAnnotations) Run(r io.Reader, w io.Writer) error {
+	return runProcessor(sa, r, w)
+}
+
+// Process implements framework.ResourceListProcessor interface.
+func (sa *SetAnnotations) Process(resourceList *framework.ResourceList) error {
+	return setMetadata(resourceList, "annotations", "commonannotations", func(m map[string]string, fs types.FsSlice) kio.Filter {
+		return annotations.Filter{Annotations: m, FsSlice: fs}
+	})
+}
+
+func runProcessor(p framework.ResourceListProcessor, r io.Reader, w io.Writer) error {
+	rw := &kio.ByteReadWriter{
+		Reader:                r,
+		Writer:                w,
+		KeepReaderAnnotations: true,
+	}
+	return framework.Execute(p, rw)
+}
+
+// setMetadata sets the metadata map of the functionConfig, stored in the
+// data of a ConfigMap or in the field named after the metadata, on the
+// fields of the resources identified by the kustomize field specs.
+func setMetadata(resourceList *framework.ResourceList, field, fieldSpecs string, newFilter func(map[string]string, types.FsSlice) kio.Filter) error {
+	values, err := metadataConfig(resourceList.FunctionConfig, field)
+	if err == nil {
+		var fs types.FsSlice
+		if fs, err = defaultFieldSpecs(fieldSpecs); err == nil {
+			_, err = newFilter(values, fs).Filter(resourceList.Items)
+		}
+	}
+	if err != nil {
+		resourceList.Results = framework.Results{
+			&framework.Result{
+				Message:  fmt.Sprintf("failed to set %s: %s", field, err.Error()),
+				Severity: framework.Error,
+			},
+		}
+		return resourceList.Results
+	}
+	// like the function images, no result is reported on success.
+	return nil
+}
+
+func metadataConfig(fc *yaml.RNode, field string) (map[string]string, error) {
+	if fc == nil {
+		return nil, fmt.Errorf("functionConfig is missing")
+	}
+	if fc.GetKind() == "ConfigMap" {
+		return fc.GetDataMap(), nil
+	}
+	values := map[string]string{}
+	n, err := fc.Pipe(yaml.Lookup(field))
+	if err != nil || n == nil {
+		return nil, fmt.Errorf("functionConfig must be a ConfigMap or have a %q field", field)
+	}
+	if err := n.YNode().Decode(&values); err != nil {
+		return nil, fmt.Errorf("invalid %q field of functionConfig: %w", field, err)
+	}
+	return values, nil
+}
+
+// defaultFieldSpecs returns the default kustomize field specs with the
+// given name, e.g. commonlabels.
+func defaultFieldSpecs(name string) (types.FsSlice, error) {
+	var specs map[string]types.FsSlice
+	if err := k8syaml.Unmarshal([]byte(builtinpluginconsts.GetDefaultFieldSpecsAsMap()[name]), &specs); err != nil {
+		return nil, err
+	}
+	for _, fs := range specs {
+		return fs, nil
+	}
+	return nil, fmt.Errorf("no field specs named %q", name)
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/qri-io/starlib/util"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func init() {
+	// like the starlark function of the catalog, allow top-level for loops
+	// and if statements, global reassignment, while loops, recursion and
+	// the set built-in. The flags are global, so they are set once here
+	// rather than for each run, as programs may be run concurrently.
+	resolve.AllowGlobalReassign = true
+	resolve.AllowSet = true
+	resolve.AllowRecursion = true
+}
+
+// StarlarkRunner runs a Starlark program in-process. The program reads and
+// modifies the function input `resourceList` bound to ctx.resource_list,
+// and reports structured results by setting ctx.resource_list["results"].
+//
+// Unlike the starlark runtime of kyaml, the program can't load modules and
+// doesn't have access to the environment variables of kpt, since they may
+// contain secrets: ctx.environment is empty.
+type StarlarkRunner struct {
+	// Name is the name of the program used in errors.
+	Name string
+
+	// Program is the source of the program.
+	Program string
+
+	// Timeout is the maximum duration the program is allowed to run. The
+	// program isn't interrupted if it is 0.
+	Timeout time.Duration
+}
+
+// Run implements the function signature defined in
+// sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil/FunctionFilter.Run.
+func (sr *StarlarkRunner) Run(r io.Reader, w io.Writer) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var in map[string]interface{}
+	if err := yaml.Unmarshal(b, &in); err != nil {
+		return fmt.Errorf("failed to parse ResourceList: %w", err)
+	}
+	resourceList, err := util.Marshal(in)
+	if err != nil {
+		return err
+	}
+	environment, err := util.Marshal(map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	predeclared := starlark.StringDict{
+		"ctx": starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"resource_list": resourceList,
+			"environment":   environment,
+		}),
+	}
+
+	thread := &starlark.Thread{Name: sr.Name}
+	if sr.Timeout > 0 {
+		timer := time.AfterFunc(sr.Timeout, func() {
+			thread.Cancel(fmt.Sprintf("timed out after %v", sr.Timeout))
+		})
+		defer timer.Stop()
+	}
+	if _, err := starlark.ExecFile(thread, sr.Name, sr.Program, predeclared); err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return fmt.Errorf("%s", evalErr.Backtrace())
+		}
+		return err
+	}
+
+	out, err := util.Unmarshal(resourceList)
+	if err != nil {
+		return err
+	}
+	b, err = yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	rl, err := yaml.Parse(string(b))
+	if err != nil {
+		return err
+	}
+	// starlark serializes the resources with their fields sorted
+	// alphabetically, format them to have the conventional ordering.
+	if items := rl.Field("items"); items != nil {
+		if err := items.Value.VisitElements(func(node *yaml.RNode) error {
+			_, err := filters.FormatFilter{}.Filter([]*yaml.RNode{node})
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	s, err := rl.String()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(s))
+	return err
+}
+
+// StarlarkRun is a built-in implementation of the starlark function of the
+// catalog. It runs the program in the `source` of its functionConfig, which
+// is either a StarlarkRun resource or a ConfigMap.
+type StarlarkRun struct{}
+
+// Run implements the function signature defined in
+// sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil/FunctionFilter.Run.
+func (s *StarlarkRun) Run(r io.Reader, w io.Writer) error {
+	return runProcessor(s, r, w)
+}
+
+// Process implements framework.ResourceListProcessor interface.
+func (s *StarlarkRun) Process(resourceList *framework.ResourceList) error {
+	fc := resourceList.FunctionConfig
+	if fc == nil {
+		return starlarkError(resourceList, fmt.Errorf("functionConfig is missing, expect `ConfigMap` or `StarlarkRun`"))
+	}
+	var source string
+	switch fc.GetKind() {
+	case "ConfigMap":
+		source = fc.GetDataMap()["source"]
+	case "StarlarkRun":
+		if f := fc.Field("source"); f != nil {
+			source = f.Value.YNode().Value
+		}
+	default:
+		return starlarkError(resourceList, fmt.Errorf("functionConfig must be a `ConfigMap` or `StarlarkRun`, got %q", fc.GetKind()))
+	}
+	if source == "" {
+		return starlarkError(resourceList, fmt.Errorf("`source` must not be empty"))
+	}
+	name := fc.GetName()
+	if name == "" {
+		name = "starlark-function-run"
+	}
+
+	in, out := &bytes.Buffer{}, &bytes.Buffer{}
+	err := kio.ByteWriter{
+		Writer:                in,
+		KeepReaderAnnotations: true,
+		WrappingAPIVersion:    kio.ResourceListAPIVersion,
+		WrappingKind:          kio.ResourceListKind,
+		FunctionConfig:        fc,
+	}.Write(resourceList.Items)
+	if err != nil {
+		return starlarkError(resourceList, err)
+	}
+	if err := (&StarlarkRunner{Name: name, Program: source}).Run(in, out); err != nil {
+		return starlarkError(resourceList, err)
+	}
+	items, err := (&kio.ByteReader{Reader: out, OmitReaderAnnotations: true}).Read()
+	if err != nil {
+		return starlarkError(resourceList, err)
+	}
+	resourceList.Items = items
+	return nil
+}
+
+func starlarkError(resourceList *framework.ResourceList, err error) error {
+	resourceList.Results = framework.Results{
+		&framework.Result{
+			Message:  err.Error(),
+			Severity: framework.Error,
+		},
+	}
+	return resourceList.Results
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStarlarkRunner(t *testing.T) {
+	const in = `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+  spec:
+    replicas: 1
+`
+	testCases := map[string]struct {
+		program  string
+		expected string
+	}{
+		"top-level for loop": {
+			program: `
+for r in ctx.resource_list["items"]:
+  r["spec"]["replicas"] = 5
+`,
+			expected: "replicas: 5",
+		},
+		"global reassignment": {
+			program: `
+replicas = 2
+replicas = replicas * 3
+if replicas > 1:
+  ctx.resource_list["items"][0]["spec"]["replicas"] = replicas
+`,
+			expected: "replicas: 6",
+		},
+		"while loop and recursion": {
+			program: `
+def fib(n):
+  if n < 2:
+    return n
+  return fib(n - 1) + fib(n - 2)
+def count():
+  i = 0
+  while i < 7:
+    i += 1
+  return i
+ctx.resource_list["items"][0]["spec"]["replicas"] = fib(count())
+`,
+			expected: "replicas: 13",
+		},
+		"set built-in": {
+			program: `
+names = set([r["metadata"]["name"] for r in ctx.resource_list["items"]])
+ctx.resource_list["items"][0]["spec"]["replicas"] = len(names)
+`,
+			expected: "replicas: 1",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			sr := &StarlarkRunner{Name: tn, Program: tc.program}
+			out := &bytes.Buffer{}
+			if !assert.NoError(t, sr.Run(strings.NewReader(in), out)) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), tc.expected)
+		})
+	}
+}
@@ -7,6 +7,47 @@ The ` + "`" + `fn` + "`" + ` command group contains subcommands for transforming
 using containerized functions.
 `
 
+var CacheShort = `Manage the cache of function results.`
+var CacheLong = `
+The ` + "`" + `cache` + "`" + ` command group contains subcommands for managing the cache of
+function results.
+
+With the ` + "`" + `--fn-cache` + "`" + ` flag, ` + "`" + `render` + "`" + ` and ` + "`" + `eval` + "`" + ` cache the output of container
+and executable functions, keyed by the image digest or executable, the
+function config, the input resources and the environment of the function. A
+function that is run again with the same input returns the cached output
+instead of running. The digest of the image of a container function is looked
+up before each run, which requires the container runtime, or the registry if
+the image is always pulled.
+
+Results are cached in ` + "`" + `<HOME>/.kpt/fn-cache` + "`" + `, unless overridden by the
+` + "`" + `KPT_FN_CACHE_DIR` + "`" + ` environment variable.
+`
+
+var PruneShort = `Remove cached function results.`
+var PruneLong = `
+  kpt fn cache prune [flags]
+
+Flags:
+
+  --older-than:
+    Only remove the results that haven't been used for longer than the given
+    duration, e.g. '72h'. Defaults to 0, which removes all cached results.
+
+Environment Variables:
+
+  KPT_FN_CACHE_DIR:
+    Controls where function results are cached.
+    Defaults to <HOME>/.kpt/fn-cache/
+`
+var PruneExamples = `
+  # Remove all cached function results.
+  $ kpt fn cache prune
+
+  # Remove the function results that haven't been used for a week.
+  $ kpt fn cache prune --older-than 168h
+`
+
 var DocShort = `Display the documentation for a function`
 var DocLong = `
 ` + "`" + `kpt fn doc` + "`" + ` invokes the function container with ` + "`" + `--help` + "`" + ` flag.
@@ -76,9 +117,35 @@ Flags:
     during development. It enables faster dev iterations by avoiding the function to
     be published as container image.
   
+  --enable-builtin-fns:
+    Run the function in-process instead of running its image, without a
+    container runtime, if it has a builtin implementation. The following
+    catalog functions have a builtin implementation: set-namespace (v0.4),
+    starlark (v0.4), apply-setters (v0.2), set-labels (v0.1) and
+    set-annotations (v0.1).
+  
+  --fn-cache:
+    Cache the result of the function, and reuse the cached result of previous
+    runs with the same input instead of running the function. The results of
+    container and executable functions are cached, unless the function has
+    network access or storage mounts. See ` + "`" + `kpt fn cache` + "`" + ` for details.
+  
   --fn-config:
     Path to the file containing ` + "`" + `functionConfig` + "`" + ` for the function.
   
+  --fn-runtime:
+    Runtime to run the function with instead of running it locally. It defaults
+    to the value of the ` + "`" + `KPT_FN_RUNTIME_URI` + "`" + ` environment variable. It is one of:
+    - ` + "`" + `grpc://HOST:PORT` + "`" + `: the address of a function evaluator to run the function
+      image with, either the function runner of porch or ` + "`" + `kpt fn serve` + "`" + `. The
+      connection is neither authenticated nor encrypted, so only use a function
+      evaluator reachable through a trusted network.
+    - ` + "`" + `record://DIR` + "`" + `: run the function locally and record its run in the fixture
+      directory ` + "`" + `DIR` + "`" + `.
+    - ` + "`" + `replay://DIR` + "`" + `: replay the run recorded in ` + "`" + `DIR` + "`" + ` without running containers
+      or accessing the network. It fails if the function wasn't recorded with the
+      same input.
+  
   --image, i:
     Container image of the function to execute e.g. ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + `.
     For convenience, if full image path is not specified, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` is added as default prefix.
@@ -145,11 +212,34 @@ Flags:
   --save, s:
     Save the function image and fn-config to Kptfile. Require ` + "`" + ` + "` + "`" + `" + ` + "`" + `--image` + "`" + ` + "` + "`" + `" + ` + "`" + `.
     
+  --sandbox-exec:
+    Run the executable in a sandbox. Its environment is scrubbed, only
+    ` + "`" + `PATH` + "`" + `, ` + "`" + `HOME` + "`" + ` and ` + "`" + `TMPDIR` + "`" + ` are set, and its working directory and ` + "`" + `HOME` + "`" + `
+    are an empty temporary directory removed after the run. On Linux, it runs
+    in new user, PID, network, IPC and UTS namespaces when available, so it
+    has no network access. A warning is printed if the namespaces are not
+    available. The filesystem is not restricted, it can read and write the
+    files of the user running kpt. Its default timeout is 1 minute instead
+    of 5.
 
 Environment Variables:
 
   KPT_FN_RUNTIME:
     The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl".
+  
+  KPT_FN_RUNTIME_URI:
+    The default value of the --fn-runtime flag.
+  
+  KPT_FN_CACHE_DIR:
+    Controls where function results are cached.
+    Defaults to <HOME>/.kpt/fn-cache/
+  
+  KPT_FN_POLICY:
+    Path to the user-level function policy restricting the function images that
+    can be run. Defaults to <HOME>/.kpt/fn-policy.yaml. The repo-level policy
+    ` + "`" + `.kpt/fn-policy.yaml` + "`" + ` at the root of the git repository containing the
+    package, or the current directory if resources are read from stdin, is also
+    enforced.
 `
 var EvalExamples = `
   # execute container my-fn on the resources in DIR directory and
@@ -257,6 +347,24 @@ var ExportExamples = `
   kpt fn export DIR/ --fn-path FUNCTIONS_DIR/ --workflow cloud-build
 `
 
+var LockShort = `Lock the function images of a package to their digests.`
+var LockLong = `
+  kpt fn lock [PKG_PATH]
+
+Args:
+
+  PKG_PATH:
+    Local package path to lock the function images of. Directory must exist and
+    contain a Kptfile. Defaults to the current working directory.
+`
+var LockExamples = `
+  # Lock the function images of the package in the current directory.
+  $ kpt fn lock
+
+  # Lock the function images of my-package-dir and its subpackages.
+  $ kpt fn lock my-package-dir
+`
+
 var RenderShort = `Render a package.`
 var RenderLong = `
   kpt fn render [PKG_PATH] [flags]
@@ -274,11 +382,89 @@ Flags:
     can perform privileged operations on your system, so ensure that binaries
     referred in the pipeline are trusted and safe to execute.
   
+  --allow-mount-for:
+    Comma-separated glob patterns of the images of the functions that are
+    allowed to mount package directories or files, if they request it with
+    ` + "`" + `mounts` + "`" + ` in the pipeline. In the patterns, '*' matches any sequence of
+    characters, e.g. 'gcr.io/my-org/*'. Mounts are always read-only.
+  
+  --allow-network-for:
+    Comma-separated glob patterns of the images of the functions that are
+    allowed network access, if they request it with ` + "`" + `network: true` + "`" + ` in the
+    pipeline, e.g. 'gcr.io/my-org/schema-fetcher:*'. Rendering fails if a
+    function requests network access and its image doesn't match any pattern.
+  
+  --concurrency:
+    The maximum number of subpackages to render concurrently. Sibling
+    subpackages are rendered concurrently if they, and their subpackages, only
+    use pipeline sources located inside their own directory. The output and the
+    function results are the same as when rendering sequentially.
+    Defaults to 1.
+  
+  --diff-format:
+    The format of the changes printed by --dry-run. It can be set to one of
+    unified, json. Defaults to unified.
+    1. unified: a unified diff per changed resource. Files that don't contain
+       KRM resources are diffed as a whole.
+    2. json: a JSON object listing the changed files and, for each file, the
+       added, modified and deleted resources with their diffs.
+  
+  --dry-run:
+    If specified, the changes rendering would make to the package are printed
+    to stdout, and the package is left unchanged. The command exits with a
+    non-zero exit code if rendering would change the package, which can be used
+    to verify in CI that a package is rendered. Can not be used with --output.
+  
+  --enable-builtin-fns:
+    Run the functions that have a builtin implementation in-process instead of
+    running their image, without a container runtime. The following catalog
+    functions have a builtin implementation: set-namespace (v0.4), starlark
+    (v0.4), apply-setters (v0.2), set-labels (v0.1) and set-annotations (v0.1).
+  
+  --fn-cache:
+    Cache the results of the functions, and reuse the cached results of
+    previous runs with the same input instead of running the functions. The
+    results of container and executable functions are cached, see
+    ` + "`" + `kpt fn cache` + "`" + ` for details.
+  
+  --fn-runtime:
+    Runtime to run the functions of the pipeline with instead of running them
+    locally. It defaults to the value of the ` + "`" + `KPT_FN_RUNTIME_URI` + "`" + ` environment
+    variable. It is one of:
+    - ` + "`" + `grpc://HOST:PORT` + "`" + `: the address of a function evaluator to run the function
+      images with, either the function runner of porch or ` + "`" + `kpt fn serve` + "`" + `.
+      Executables and builtin functions still run locally. The connection is
+      neither authenticated nor encrypted, so only use a function evaluator
+      reachable through a trusted network.
+    - ` + "`" + `record://DIR` + "`" + `: run the functions locally and record their runs in the
+      fixture directory ` + "`" + `DIR` + "`" + `.
+    - ` + "`" + `replay://DIR` + "`" + `: replay the runs recorded in ` + "`" + `DIR` + "`" + ` without running
+      containers or accessing the network. It fails if a function wasn't
+      recorded with the same input.
+  
   --image-pull-policy:
     If the image should be pulled before rendering the package(s). It can be set
     to one of always, ifNotPresent, never. If unspecified, always will be the
     default.
   
+  --image-lock:
+    How the function images locked to their digests by ` + "`" + `kpt fn lock` + "`" + ` are used.
+    It can be set to one of enforce, warn, ignore. Defaults to enforce.
+    1. enforce: locked images are run by their digest. Rendering fails if a
+       function image of a package with locked images isn't locked, or if the
+       lock is out of date with ` + "`" + `--image-lock-check-upstream` + "`" + `.
+    2. warn: locked images are run by their digest. A warning is printed if a
+       function image of a package with locked images isn't locked, or if the
+       lock is out of date with ` + "`" + `--image-lock-check-upstream` + "`" + `.
+    3. ignore: images are run as specified in the pipeline.
+  
+  --image-lock-check-upstream:
+    Check that the tags of the locked function images still resolve to their
+    locked digests in their registries. A lock is out of date if its tag now
+    resolves to another digest, or if the tag can't be resolved. This needs
+    access to the registries, so it is off by default, and it can't be used with
+    a ` + "`" + `replay://` + "`" + ` function runtime.
+  
   --output, o:
     If specified, the output resources are written to provided location,
     if not specified, resources are modified in-place.
@@ -288,16 +474,46 @@ Flags:
     3. OUT_DIR_PATH: output resources are written to provided directory.
        The provided directory must not already exist.
   
+  --trace-dir:
+    Path to a directory to record the input ResourceList, the output resources,
+    stderr, exit code and duration of every function run in. Directory will be
+    created if it doesn't exist, and a trace previously recorded in it is
+    replaced. The function results are saved to ` + "`" + `results.yaml` + "`" + ` in the directory.
+    The trace can be inspected with ` + "`" + `kpt fn trace show` + "`" + `.
+  
   --results-dir:
     Path to a directory to write structured results. Directory will be created if
     it doesn't exist. Structured results emitted by the functions are aggregated and saved
     to ` + "`" + `results.yaml` + "`" + ` file in the specified directory.
     If not specified, no result files are written to the local filesystem.
+  
+  --sandbox-exec:
+    Run binary executables in a sandbox. Their environment is scrubbed, only
+    ` + "`" + `PATH` + "`" + `, ` + "`" + `HOME` + "`" + ` and ` + "`" + `TMPDIR` + "`" + ` are set, and their working directory and ` + "`" + `HOME` + "`" + `
+    are an empty temporary directory removed after the run. On Linux, they run
+    in new user, PID, network, IPC and UTS namespaces when available, so they
+    have no network access. A warning is printed if the namespaces are not
+    available. The filesystem is not restricted, they can read and write the
+    files of the user running kpt. Their default timeout is 1 minute instead
+    of 5.
 
 Environment Variables:
 
   KPT_FN_RUNTIME:
     The runtime to run kpt functions. It must be one of "docker", "podman" and "nerdctl".
+  
+  KPT_FN_RUNTIME_URI:
+    The default value of the --fn-runtime flag.
+  
+  KPT_FN_CACHE_DIR:
+    Controls where function results are cached.
+    Defaults to <HOME>/.kpt/fn-cache/
+  
+  KPT_FN_POLICY:
+    Path to the user-level function policy restricting the function images that
+    can be run. Defaults to <HOME>/.kpt/fn-policy.yaml. The repo-level policy
+    ` + "`" + `.kpt/fn-policy.yaml` + "`" + ` at the root of the git repository containing the
+    package is also enforced.
 `
 var RenderExamples = `
   # Render the package in current directory
@@ -322,10 +538,57 @@ var RenderExamples = `
   $ kpt fn render -o stdout \
   | kpt fn eval - -i gcr.io/kpt-fn/set-annotations:v0.1.3 -o path/to/dir  -- foo=bar
 
+  # Print the changes rendering would make to the package in current directory
+  $ kpt fn render --dry-run
+
+  # Print the changes rendering would make to my-package-dir as JSON
+  $ kpt fn render my-package-dir --dry-run --diff-format json
+
+  # Render my-package-dir, record every function run in my-trace-dir and
+  # show the changes made by the second function run
+  $ kpt fn render my-package-dir --trace-dir my-trace-dir
+  $ kpt fn trace show my-trace-dir 2
+
+  # Render my-package-dir, allowing the functions from gcr.io/my-org that
+  # request network access in the pipeline to access the network
+  $ kpt fn render my-package-dir --allow-network-for 'gcr.io/my-org/*'
+
   # Render my-package-dir with podman as runtime for functions
   $ KPT_FN_RUNTIME=podman kpt fn render my-package-dir
 `
 
+var ServeShort = `Start a function evaluator server.`
+var ServeLong = `
+  kpt fn serve [flags]
+
+Flags:
+
+  --address:
+    The address the server listens on. Defaults to ` + "`" + `localhost:9445` + "`" + `.
+  
+  --config:
+    Path to a configuration file mapping function images to executables in the
+    ` + "`" + `--functions` + "`" + ` directory, in the format of the function runner of porch.
+    The images that aren't in the file are run in containers.
+  
+  --functions:
+    Path to the directory containing the executables of the ` + "`" + `--config` + "`" + ` file.
+    Defaults to the current working directory.
+  
+  --image-pull-policy:
+    If the images should be pulled before running the functions. It can be set
+    to one of always, ifNotPresent, never. If unspecified, ifNotPresent will be
+    the default.
+`
+var ServeExamples = `
+  # Start a function evaluator and render a package with it.
+  $ kpt fn serve --address localhost:9445 &
+  $ kpt fn render my-package-dir --fn-runtime grpc://localhost:9445
+
+  # Run the images of config.yaml with executables in the functions directory.
+  $ kpt fn serve --config config.yaml --functions ./functions
+`
+
 var SinkShort = `Write resources to a local directory`
 var SinkLong = `
   kpt fn sink DIR [flags]
@@ -376,3 +639,36 @@ var SourceExamples = `
     kpt fn eval - --image gcr.io/example.com/my-fn - |
     kpt fn sink DIR
 `
+
+var TraceShort = `Inspect traces of function runs.`
+var TraceLong = `
+The ` + "`" + `trace` + "`" + ` command group contains subcommands for inspecting the traces
+recorded by ` + "`" + `kpt fn render --trace-dir` + "`" + `.
+
+A trace records, for every function run, the input ResourceList, the output
+resources, the content written to stderr, the exit code and how long the
+function took to run. Each function run is recorded as a step in the
+` + "`" + `steps/<STEP>` + "`" + ` directory of the trace, and the function results are saved to
+` + "`" + `results.yaml` + "`" + ` alongside the steps.
+`
+
+var ShowShort = `Show the steps of a trace, or the changes made by a step.`
+var ShowLong = `
+  kpt fn trace show TRACE_DIR [STEP]
+
+Args:
+
+  TRACE_DIR:
+    The directory the trace was recorded in.
+  
+  STEP:
+    The step to show the changes of. If not specified, the steps of the trace
+    are listed.
+`
+var ShowExamples = `
+  # List the steps recorded in my-trace-dir.
+  $ kpt fn trace show my-trace-dir
+
+  # Show the changes made by the third function run.
+  $ kpt fn trace show my-trace-dir 3
+`
@@ -4,6 +4,72 @@ package wasmdocs
 var WasmShort = `Manage WASM modules as OCI images.`
 var WasmLong = `
 The ` + "`" + `wasm` + "`" + ` command group contains subcommands for managing WASM modules as OCI images.
+
+kpt runs two kinds of WASM modules with ` + "`" + `--allow-alpha-wasm` + "`" + `:
+
+- Go modules compiled with ` + "`" + `GOOS=js GOARCH=wasm` + "`" + `, which are run with a JS glue
+  code by wasmtime, or by node.js if the ` + "`" + `KPT_FN_WASM_RUNTIME` + "`" + ` environment
+  variable is ` + "`" + `nodejs` + "`" + `.
+- WASI preview1 modules, e.g. compiled from Rust or TinyGo, which read the
+  ResourceList from stdin and write it to stdout like a container function.
+  They are always run by wasmtime.
+
+The kind of a module is detected from its imports, so each function uses the
+right one.
+`
+
+var CacheShort = `Manage the compiled wasm module cache.`
+var CacheLong = `
+The ` + "`" + `cache` + "`" + ` command group contains subcommands for managing the cache of
+compiled wasm modules.
+
+Compiling a wasm module dominates the time to run a wasm function, so kpt
+caches the compiled modules, keyed by the digest of the module and the version
+of wasmtime. A compiled module is only loaded by the version of wasmtime that
+compiled it, and is compiled again if wasmtime rejects it.
+
+The cache is stored in ` + "`" + `<HOME>/.kpt/wasm-cache` + "`" + `, unless overridden by the
+` + "`" + `KPT_WASM_CACHE_DIR` + "`" + ` environment variable.
+`
+
+var LsShort = `List the compiled wasm modules in the cache.`
+var LsLong = `
+  kpt alpha wasm cache ls
+
+Environment Variables:
+
+  KPT_WASM_CACHE_DIR:
+    Controls where compiled wasm modules are cached.
+    Defaults to <HOME>/.kpt/wasm-cache/
+`
+var LsExamples = `
+  # List the compiled wasm modules.
+  $ kpt alpha wasm cache ls
+`
+
+var PruneShort = `Remove compiled wasm modules from the cache.`
+var PruneLong = `
+  kpt alpha wasm cache prune [flags]
+
+Flags:
+
+  --older-than:
+    Only remove the compiled modules that haven't been used for longer than the
+    given duration, e.g. '72h'. Defaults to 0, which removes all compiled
+    modules.
+
+Environment Variables:
+
+  KPT_WASM_CACHE_DIR:
+    Controls where compiled wasm modules are cached.
+    Defaults to <HOME>/.kpt/wasm-cache/
+`
+var PruneExamples = `
+  # Remove all compiled wasm modules.
+  $ kpt alpha wasm cache prune
+
+  # Remove the compiled wasm modules that haven't been used for a week.
+  $ kpt alpha wasm cache prune --older-than 168h
 `
 
 var PullShort = `Fetch and decompress OCI image to WASM module.`
@@ -36,4 +102,9 @@ Args:
 var PushExamples = `
   # compress ./my-fn.wasm and push it to gcr.io/my-org/my-fn:v1.0.0
   $ kpt alpha wasm push ./my-fn.wasm gcr.io/my-org/my-fn:v1.0.0
+
+  # push a WASI module built from Rust and run it
+  $ cargo build --target wasm32-wasi --release
+  $ kpt alpha wasm push ./target/wasm32-wasi/release/my-fn.wasm gcr.io/my-org/my-fn:v1.0.0
+  $ kpt fn eval --allow-alpha-wasm -i gcr.io/my-org/my-fn:v1.0.0
 `
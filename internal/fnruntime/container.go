@@ -24,6 +24,7 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -90,6 +91,12 @@ type ContainerFn struct {
 	StorageMounts []runtimeutil.StorageMount
 	// Env is a slice of env string that will be exposed to container
 	Env []string
+	// Memory is the maximum amount of memory in bytes the container can
+	// use. The container is not limited if it is 0.
+	Memory int64
+	// CPUs is the maximum number of CPUs the container can use. The
+	// container is not limited if it is 0.
+	CPUs float64
 	// FnResult is used to store the information about the result from
 	// the function.
 	FnResult *fnresult.Result
@@ -189,6 +196,12 @@ func (f *ContainerFn) getCmd(binName string) (*exec.Cmd, context.CancelFunc) {
 	default:
 		args = append(args, "--pull", "missing")
 	}
+	if f.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(f.Memory, 10))
+	}
+	if f.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(f.CPUs, 'f', -1, 64))
+	}
 	for _, storageMount := range f.StorageMounts {
 		args = append(args, "--mount", storageMount.String())
 	}
@@ -41,6 +41,12 @@ type ExecFn struct {
 	// FnResult is used to store the information about the result from
 	// the function.
 	FnResult *fnresult.Result
+	// Sandbox runs the executable in a sandbox, see runSandboxed. The
+	// default timeout of sandboxed executables is 1 minute.
+	Sandbox bool
+	// Ctx is used to print a warning if the sandbox can't isolate the
+	// executable.
+	Ctx context.Context
 }
 
 // Run runs the executable file which reads the input from r and
@@ -48,30 +54,45 @@ type ExecFn struct {
 func (f *ExecFn) Run(r io.Reader, w io.Writer) error {
 	// setup exec run timeout
 	timeout := defaultLongTimeout
+	if f.Sandbox {
+		timeout = defaultSandboxTimeout
+	}
 	if f.Timeout != 0 {
 		timeout = f.Timeout
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, f.Path, f.Args...)
-
 	errSink := bytes.Buffer{}
-	cmd.Stdin = r
-	cmd.Stdout = w
-	cmd.Stderr = &errSink
+	newCmd := func() *exec.Cmd {
+		cmd := exec.CommandContext(ctx, f.Path, f.Args...)
+		cmd.Stdin = r
+		cmd.Stdout = w
+		cmd.Stderr = &errSink
 
-	for k, v := range f.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+		for k, v := range f.Env {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+		}
+		return cmd
 	}
 
-	if err := cmd.Run(); err != nil {
+	var err error
+	if f.Sandbox {
+		err = runSandboxed(f.Ctx, newCmd, sandboxSysProcAttr())
+	} else {
+		err = newCmd().Run()
+	}
+	if err != nil {
 		var exitErr *exec.ExitError
 		if goerrors.As(err, &exitErr) {
+			stderr := errSink.String()
+			if ctx.Err() == context.DeadlineExceeded {
+				stderr += fmt.Sprintf("function timed out after %s and was killed\n", timeout)
+			}
 			return &ExecError{
 				OriginalErr:    exitErr,
 				ExitCode:       exitErr.ExitCode(),
-				Stderr:         errSink.String(),
+				Stderr:         stderr,
 				TruncateOutput: printer.TruncateOutput,
 			}
 		}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+)
+
+const (
+	// defaultSandboxTimeout is the default timeout of sandboxed executables.
+	defaultSandboxTimeout = time.Minute
+
+	// sandboxPath is the PATH of sandboxed executables.
+	sandboxPath = "/usr/local/bin:/usr/bin:/bin"
+)
+
+// runSandboxed runs the command returned by newCmd in a sandbox:
+//   - the environment is scrubbed, only PATH, HOME and TMPDIR are set in
+//     addition to the environment of the function.
+//   - the working directory, HOME and TMPDIR are an empty temporary
+//     directory, which is removed after the run.
+//   - it runs with the process attributes attr, which on Linux run it in
+//     new user, PID, network, IPC and UTS namespaces, so it has no network
+//     access and can't see or signal the other processes.
+//
+// The filesystem is not restricted: the command can read and write every
+// file the user running kpt can.
+//
+// newCmd may be called several times, since the command is retried without
+// attr if the namespaces can't be created. A warning is printed with the
+// printer of ctx if it is retried, unless ctx is nil.
+func runSandboxed(ctx context.Context, newCmd func() *exec.Cmd, attr *syscall.SysProcAttr) error {
+	dir, err := os.MkdirTemp("", "kpt-fn-exec-")
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var path string
+	sandboxCmd := func() (*exec.Cmd, error) {
+		cmd := newCmd()
+		if path == "" {
+			// relative paths would be resolved from the sandbox directory.
+			if path, err = filepath.Abs(cmd.Path); err != nil {
+				return nil, err
+			}
+		}
+		cmd.Path = path
+		cmd.Dir = dir
+		cmd.Env = append([]string{
+			"PATH=" + sandboxPath,
+			"HOME=" + dir,
+			"TMPDIR=" + dir,
+		}, cmd.Env...)
+		return cmd, nil
+	}
+
+	cmd, err := sandboxCmd()
+	if err != nil {
+		return err
+	}
+	if cmd.SysProcAttr = attr; cmd.SysProcAttr != nil {
+		startErr := cmd.Start()
+		if startErr == nil {
+			return cmd.Wait()
+		}
+		// the namespaces are not available, e.g. unprivileged user
+		// namespaces are disabled. The input isn't consumed until the
+		// command starts, so it can be run again without them.
+		if ctx != nil {
+			printer.FromContextOrDie(ctx).Printf("[WARNING] %q is run without namespace isolation, "+
+				"it has network access and can see the other processes: %v\n", path, startErr)
+		}
+		if cmd, err = sandboxCmd(); err != nil {
+			return err
+		}
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunSandboxed_fallback(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "fn")
+	if err := os.WriteFile(fn, []byte("#!/bin/sh\ncat\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	stderr := &bytes.Buffer{}
+	ctx := printer.WithContext(context.Background(), printer.New(nil, stderr))
+	out := &bytes.Buffer{}
+	newCmd := func() *exec.Cmd {
+		cmd := exec.Command(fn)
+		cmd.Stdin = strings.NewReader("input")
+		cmd.Stdout = out
+		return cmd
+	}
+	// the command can't start in a root directory that doesn't exist, like
+	// if the namespaces can't be created.
+	attr := &syscall.SysProcAttr{Chroot: filepath.Join(t.TempDir(), "missing")}
+
+	if !assert.NoError(t, runSandboxed(ctx, newCmd, attr)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "input", out.String())
+	assert.Contains(t, stderr.String(), `[WARNING] "`+fn+`" is run without namespace isolation`)
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	goerrors "errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// sandboxScript writes its working directory, HOME and the value of
+// KPT_TEST_SECRET, then echoes its input.
+const sandboxScript = `#!/bin/sh
+echo "pwd=$(pwd)"
+echo "home=$HOME"
+echo "secret=$KPT_TEST_SECRET"
+echo "arg=$1"
+cat
+`
+
+func TestExecFn_sandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	t.Setenv("KPT_TEST_SECRET", "secret")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fn"), []byte(sandboxScript), 0700); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the relative path of the executable is resolved from the working
+	// directory of kpt, not the sandbox directory.
+	rel, err := filepath.Rel(wd, filepath.Join(dir, "fn"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(sandbox bool) map[string]string {
+		f := &ExecFn{
+			Path:     rel,
+			Args:     []string{"foo"},
+			FnResult: &fnresult.Result{},
+			Sandbox:  sandbox,
+		}
+		out := &bytes.Buffer{}
+		if !assert.NoError(t, f.Run(strings.NewReader("input"), out)) {
+			t.FailNow()
+		}
+		lines := strings.Split(out.String(), "\n")
+		values := map[string]string{"input": lines[len(lines)-1]}
+		for _, l := range lines[:len(lines)-1] {
+			kv := strings.SplitN(l, "=", 2)
+			values[kv[0]] = kv[1]
+		}
+		return values
+	}
+
+	values := run(false)
+	assert.Equal(t, "secret", values["secret"])
+	assert.Equal(t, wd, values["pwd"])
+
+	values = run(true)
+	assert.Equal(t, "", values["secret"])
+	assert.Equal(t, "foo", values["arg"])
+	assert.Equal(t, "input", values["input"])
+	assert.NotEqual(t, wd, values["pwd"])
+	assert.Equal(t, values["pwd"], values["home"])
+	// the sandbox directory is removed after the run
+	_, err = os.Stat(values["pwd"])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExecFn_timeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	fn := filepath.Join(t.TempDir(), "fn")
+	if err := os.WriteFile(fn, []byte("#!/bin/sh\nexec sleep 10\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, sandbox := range []bool{false, true} {
+		f := &ExecFn{
+			Path:     fn,
+			Timeout:  100 * time.Millisecond,
+			FnResult: &fnresult.Result{},
+			Sandbox:  sandbox,
+		}
+		err := f.Run(strings.NewReader(""), &bytes.Buffer{})
+		var execErr *ExecError
+		if assert.True(t, goerrors.As(err, &execErr)) {
+			assert.Contains(t, execErr.Stderr, "function timed out after 100ms")
+		}
+	}
+}
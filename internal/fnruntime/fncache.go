@@ -0,0 +1,300 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/google/go-containerregistry/pkg/gcrane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// FnCacheDirEnv is the name of the environment variable that controls the
+// directory function results are cached in.
+const FnCacheDirEnv = "KPT_FN_CACHE_DIR"
+
+// DefaultFnCacheDir returns the directory function results are cached in.
+// It defaults to <HOME>/.kpt/fn-cache, unless overridden by the
+// KPT_FN_CACHE_DIR environment variable.
+func DefaultFnCacheDir() (string, error) {
+	if dir := os.Getenv(FnCacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error looking up user home dir: %w", err)
+	}
+	return filepath.Join(dir, ".kpt", "fn-cache"), nil
+}
+
+// CacheableFn is a function whose results can be cached.
+type CacheableFn interface {
+	// Run runs the function, reading the input from r and writing the
+	// output to w.
+	Run(r io.Reader, w io.Writer) error
+
+	// CacheID returns a string that identifies the function and anything
+	// other than its input that its output depends on. It returns false if
+	// the results of the function can not be cached.
+	CacheID() (string, bool, error)
+}
+
+// FnCache is an on-disk cache of function results, keyed by the identity of
+// the function and its serialized input, which includes the function config.
+type FnCache struct {
+	// Dir is the directory the results are stored in.
+	Dir string
+}
+
+// fnCacheEntry is a cached function result.
+type fnCacheEntry struct {
+	// Output is the output of the function.
+	Output []byte `json:"output"`
+	// Stderr is the content written to stderr by the function.
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// Wrap returns a run function that returns the cached output of f if f was
+// run before with the same input, and runs f and caches its output
+// otherwise. Only successful runs are cached. fnResult is updated with the
+// stderr of the cached run.
+func (c *FnCache) Wrap(f CacheableFn, fnResult *fnresult.Result) func(r io.Reader, w io.Writer) error {
+	return func(r io.Reader, w io.Writer) error {
+		id, ok, err := f.CacheID()
+		if err != nil || !ok {
+			// failing to identify the function must not fail the
+			// function, so it runs without caching.
+			return f.Run(r, w)
+		}
+		input, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		key := fnCacheKey(id, input)
+		if entry, found := c.get(key); found {
+			fnResult.Stderr = entry.Stderr
+			_, err := w.Write(entry.Output)
+			return err
+		}
+
+		output := &bytes.Buffer{}
+		if err := f.Run(bytes.NewReader(input), io.MultiWriter(w, output)); err != nil {
+			return err
+		}
+		// failing to cache the result must not fail the function.
+		_ = c.put(key, &fnCacheEntry{Output: output.Bytes(), Stderr: fnResult.Stderr})
+		return nil
+	}
+}
+
+// Prune removes the cached results that haven't been used for longer than
+// maxAge, or all cached results if maxAge is 0. It returns the number of
+// removed results.
+func (c *FnCache) Prune(maxAge time.Duration) (int, error) {
+	if _, err := os.Stat(c.Dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+	now := time.Now()
+	removed := 0
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if maxAge > 0 && now.Sub(info.ModTime()) <= maxAge {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+func (c *FnCache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key)
+}
+
+func (c *FnCache) get(key string) (*fnCacheEntry, bool) {
+	path := c.path(key)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	entry := &fnCacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, false
+	}
+	// the modification time records when the result was last used, so
+	// results that are still used are not pruned.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return entry, true
+}
+
+func (c *FnCache) put(key string, entry *fnCacheEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first, so concurrent runs never read a
+	// partially written result.
+	f, err := os.CreateTemp(filepath.Dir(path), key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// fnCacheKey returns the cache key for the function with the given id and
+// serialized input.
+func fnCacheKey(id string, input []byte) string {
+	h := sha256.New()
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write(input)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// envCacheID returns the sorted environment variables as a string.
+func envCacheID(env []string) string {
+	sorted := append([]string{}, env...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "\x00")
+}
+
+// CacheID implements CacheableFn. Containers with network access or storage
+// mounts are not cached, since their output may depend on more than their
+// input.
+func (f *ContainerFn) CacheID() (string, bool, error) {
+	if f.Perm.AllowNetwork || len(f.StorageMounts) > 0 {
+		return "", false, nil
+	}
+	digest, err := f.imageDigest()
+	if err != nil || digest == "" {
+		return "", false, err
+	}
+	return fmt.Sprintf("image:%s\nuser:%s\nenv:%s", digest, f.UIDGID, envCacheID(f.Env)), true, nil
+}
+
+// imageDigest resolves the digest of the image the container is run with.
+// It returns an empty digest if the image isn't available locally and
+// wouldn't be pulled.
+func (f *ContainerFn) imageDigest() (string, error) {
+	ref, err := name.ParseReference(f.Image)
+	if err != nil {
+		return "", err
+	}
+	if d, ok := ref.(name.Digest); ok {
+		return d.DigestStr(), nil
+	}
+
+	ctx := f.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if f.ImagePullPolicy == AlwaysPull {
+		// the image is pulled before running, so the remote digest is the
+		// digest of the image that would be run.
+		desc, err := remote.Head(ref, remote.WithAuthFromKeychain(gcrane.Keychain), remote.WithContext(ctx))
+		if err != nil {
+			return "", err
+		}
+		return desc.Digest.String(), nil
+	}
+
+	runtime, err := StringToContainerRuntime(os.Getenv(ContainerRuntimeEnv))
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, versionCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, runtime.GetBin(), "image", "inspect", "--format", "{{.Id}}", f.Image).Output()
+	if err != nil {
+		// the image is not available locally.
+		return "", nil
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// CacheID implements CacheableFn. The executable is identified by the hash
+// of its content, and its arguments and environment are part of its
+// identity.
+func (f *ExecFn) CacheID() (string, bool, error) {
+	path, err := exec.LookPath(f.Path)
+	if err != nil {
+		return "", false, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", false, err
+	}
+	return fmt.Sprintf("exec:sha256:%s\nargs:%s\nenv:%s", hex.EncodeToString(h.Sum(nil)),
+		strings.Join(f.Args, "\x00"), envCacheID(f.effectiveEnv())), true, nil
+}
+
+// effectiveEnv returns the environment the executable is run with, except
+// for the temporary directories of the sandbox, which don't change its
+// output. An executable without environment variables that is not
+// sandboxed inherits the environment of kpt.
+func (f *ExecFn) effectiveEnv() []string {
+	var env []string
+	for k, v := range f.Env {
+		env = append(env, k+"="+v)
+	}
+	switch {
+	case f.Sandbox:
+		env = append(env, "PATH="+sandboxPath)
+	case len(env) == 0:
+		env = os.Environ()
+	}
+	return env
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingScript echoes its input and records each invocation in the file
+// passed as its first argument. It fails if the input contains "fail".
+const countingScript = `#!/bin/sh
+echo run >> "$1"
+input=$(cat)
+case "$input" in
+  *fail*) exit 1 ;;
+esac
+printf '%s' "$input"
+`
+
+func TestFnCache_Wrap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fn.sh")
+	if err := os.WriteFile(script, []byte(countingScript), 0700); err != nil {
+		t.Fatal(err)
+	}
+	counter := filepath.Join(dir, "count")
+	cache := &FnCache{Dir: filepath.Join(dir, "cache")}
+
+	runs := func() int {
+		b, err := os.ReadFile(counter)
+		if err != nil {
+			return 0
+		}
+		return strings.Count(string(b), "run")
+	}
+	run := func(fn *ExecFn, input string) (string, error) {
+		out := &bytes.Buffer{}
+		err := cache.Wrap(fn, &fnresult.Result{})(strings.NewReader(input), out)
+		return out.String(), err
+	}
+
+	fn := &ExecFn{Path: script, Args: []string{counter}}
+	out, err := run(fn, "kind: Foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "kind: Foo", out)
+	assert.Equal(t, 1, runs())
+
+	// the same input is served from the cache
+	out, err = run(fn, "kind: Foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "kind: Foo", out)
+	assert.Equal(t, 1, runs())
+
+	// a different input runs the function
+	out, err = run(fn, "kind: Bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "kind: Bar", out)
+	assert.Equal(t, 2, runs())
+
+	// different arguments run the function
+	_, err = run(&ExecFn{Path: script, Args: []string{counter, "extra"}}, "kind: Foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs())
+
+	// failed runs are not cached
+	_, err = run(fn, "fail")
+	assert.Error(t, err)
+	_, err = run(fn, "fail")
+	assert.Error(t, err)
+	assert.Equal(t, 5, runs())
+}
+
+func TestFnCache_Prune(t *testing.T) {
+	cache := &FnCache{Dir: filepath.Join(t.TempDir(), "cache")}
+
+	removed, err := cache.Prune(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	oldKey := fnCacheKey("fn", []byte("old"))
+	newKey := fnCacheKey("fn", []byte("new"))
+	for _, key := range []string{oldKey, newKey} {
+		if err := cache.put(key, &fnCacheEntry{Output: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(cache.path(oldKey), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err = cache.Prune(24 * time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, found := cache.get(oldKey)
+	assert.False(t, found)
+	entry, found := cache.get(newKey)
+	if assert.True(t, found) {
+		assert.Equal(t, []byte(newKey), entry.Output)
+	}
+
+	removed, err = cache.Prune(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, found = cache.get(newKey)
+	assert.False(t, found)
+}
+
+func TestExecFn_CacheID(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	cacheID := func(f *ExecFn) string {
+		id, ok, err := f.CacheID()
+		if !assert.NoError(t, err) || !assert.True(t, ok) {
+			t.FailNow()
+		}
+		return id
+	}
+	const envVar = "KPT_TEST_FN_CACHE_ENV"
+
+	t.Setenv(envVar, "a")
+	inherited := cacheID(&ExecFn{Path: "sh"})
+	sandboxed := cacheID(&ExecFn{Path: "sh", Sandbox: true})
+	withEnv := cacheID(&ExecFn{Path: "sh", Env: map[string]string{"FOO": "bar"}})
+
+	t.Setenv(envVar, "b")
+	// the executable inherits the environment of kpt, so its results are
+	// not reused if it changes.
+	assert.NotEqual(t, inherited, cacheID(&ExecFn{Path: "sh"}))
+	// the environment of sandboxed executables and executables with
+	// environment variables doesn't depend on the environment of kpt.
+	assert.Equal(t, sandboxed, cacheID(&ExecFn{Path: "sh", Sandbox: true}))
+	assert.Equal(t, withEnv, cacheID(&ExecFn{Path: "sh", Env: map[string]string{"FOO": "bar"}}))
+	assert.NotEqual(t, withEnv, cacheID(&ExecFn{Path: "sh", Env: map[string]string{"FOO": "baz"}}))
+	assert.NotEqual(t, cacheID(&ExecFn{Path: "sh"}), cacheID(&ExecFn{Path: "sh", Args: []string{"-c", "cat"}}))
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+)
+
+const (
+	// FnRuntimeEnv is the name of the environment variable that sets the
+	// --fn-runtime flag if it isn't set.
+	FnRuntimeEnv = "KPT_FN_RUNTIME_URI"
+
+	// GRPCScheme is the scheme of the --fn-runtime flag for a
+	// FunctionEvaluator service.
+	GRPCScheme = "grpc://"
+
+	// RecordScheme is the scheme of the --fn-runtime flag recording the
+	// function runs in a fixture directory.
+	RecordScheme = "record://"
+
+	// ReplayScheme is the scheme of the --fn-runtime flag replaying the
+	// function runs recorded in a fixture directory.
+	ReplayScheme = "replay://"
+)
+
+// ParseFnRuntime parses a --fn-runtime flag value, which is one of
+// grpc://host:port, record://DIR and replay://DIR. It returns its scheme
+// and the address or directory.
+func ParseFnRuntime(uri string) (string, string, error) {
+	for _, scheme := range []string{GRPCScheme, RecordScheme, ReplayScheme} {
+		if !strings.HasPrefix(uri, scheme) {
+			continue
+		}
+		location := strings.TrimPrefix(uri, scheme)
+		if location == "" {
+			return "", "", fmt.Errorf("function runtime %q must specify an address or a directory", uri)
+		}
+		return scheme, location, nil
+	}
+	return "", "", fmt.Errorf("function runtime %q is not supported, must be one of %shost:port, %sDIR, %sDIR",
+		uri, GRPCScheme, RecordScheme, ReplayScheme)
+}
+
+// OpenFnRuntime returns the function runtime of a --fn-runtime flag value,
+// which is nil for record://DIR since the functions are run locally and
+// recorded by opts.FnRecorder. The returned function releases the runtime.
+func OpenFnRuntime(uri string, opts *RunnerOptions) (fn.FunctionRuntime, func() error, error) {
+	scheme, location, err := ParseFnRuntime(uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	noop := func() error { return nil }
+	switch scheme {
+	case GRPCScheme:
+		runtime, err := NewGRPCRuntime(location)
+		if err != nil {
+			return nil, nil, err
+		}
+		return runtime, runtime.Close, nil
+	case RecordScheme:
+		opts.FnRecorder = &FnRecorder{Dir: location}
+		return nil, noop, nil
+	default:
+		return &ReplayRuntime{Dir: location}, noop, nil
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// The FunctionEvaluator gRPC service is defined by porch in
+// porch/func/evaluator/evaluator.proto. kpt can't depend on the generated
+// code of porch, as porch depends on kpt, and a copy of the generated code
+// would conflict with it in the protobuf registry of the porch binaries, so
+// its messages are encoded with protowire. porch/func/evaluator tests that
+// the encoding is compatible with the generated code.
+const (
+	evaluatorServiceName    = "evaluator.FunctionEvaluator"
+	evaluateFunctionMethod  = "EvaluateFunction"
+	evaluateFunctionFullRPC = "/" + evaluatorServiceName + "/" + evaluateFunctionMethod
+)
+
+// GRPCRuntime is a function runtime running the functions with a remote
+// FunctionEvaluator service, like the function runner of porch or
+// `kpt fn serve`.
+type GRPCRuntime struct {
+	cc *grpc.ClientConn
+}
+
+var _ fn.FunctionRuntime = &GRPCRuntime{}
+
+// NewGRPCRuntime returns a function runtime for the FunctionEvaluator service
+// listening on address. The connection is established lazily.
+//
+// The connection is not authenticated nor encrypted, like the connections of
+// porch to its function runner, so the resources of the packages are sent in
+// plain text. The FunctionEvaluator service should only be reachable from a
+// trusted network.
+func NewGRPCRuntime(address string) (*GRPCRuntime, error) {
+	if address == "" {
+		return nil, fmt.Errorf("address is required to instantiate gRPC function runtime")
+	}
+	cc, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial grpc function evaluator: %w", err)
+	}
+	return &GRPCRuntime{cc: cc}, nil
+}
+
+// GetRunner implements FunctionRuntime. Only functions identified by an image
+// are evaluated remotely; it returns a nil runner for the other functions so
+// they are run locally.
+func (gr *GRPCRuntime) GetRunner(ctx context.Context, f *kptfilev1.Function) (fn.FunctionRunner, error) {
+	if f.Image == "" || f.Image == FuncGenPkgContext {
+		return nil, nil
+	}
+	return &grpcRunner{
+		ctx:   ctx,
+		cc:    gr.cc,
+		image: f.Image,
+	}, nil
+}
+
+// Close closes the connection to the FunctionEvaluator service.
+func (gr *GRPCRuntime) Close() error {
+	if gr.cc == nil {
+		return nil
+	}
+	err := gr.cc.Close()
+	gr.cc = nil
+	return err
+}
+
+type grpcRunner struct {
+	ctx   context.Context
+	cc    *grpc.ClientConn
+	image string
+}
+
+var _ fn.FunctionRunner = &grpcRunner{}
+
+func (gr *grpcRunner) Run(r io.Reader, w io.Writer) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read function runner input: %w", err)
+	}
+	req := &evaluateFunctionRequest{resourceList: in, image: gr.image}
+	res := &evaluateFunctionResponse{}
+	if err := gr.cc.Invoke(gr.ctx, evaluateFunctionFullRPC, req, res, grpc.ForceCodec(evaluatorCodec{})); err != nil {
+		if execErr, ok := evaluatorExecError(err); ok {
+			// the output of the failed function has its results.
+			if _, err := w.Write(execErr.out); err != nil {
+				return fmt.Errorf("failed to write function runner output: %w", err)
+			}
+			return execErr.ExecError
+		}
+		return fmt.Errorf("func eval %q failed: %w", gr.image, err)
+	}
+	if _, err := w.Write(res.resourceList); err != nil {
+		return fmt.Errorf("failed to write function runner output: %w", err)
+	}
+	printFnStderr(gr.ctx, string(res.log))
+	return nil
+}
+
+// remoteExecError is the failure of a function run by the FunctionEvaluator
+// service, with the output of the function.
+type remoteExecError struct {
+	*ExecError
+	out []byte
+}
+
+// evaluatorExecError returns the failure of the function from the details of
+// the status of err, if the function was run and failed, see
+// evaluatorStatus.
+func evaluatorExecError(err error) (*remoteExecError, bool) {
+	st, ok := status.FromError(err)
+	if !ok || len(st.Details()) == 0 {
+		return nil, false
+	}
+	execErr := &remoteExecError{
+		ExecError: &ExecError{
+			OriginalErr:    err,
+			ExitCode:       1,
+			TruncateOutput: printer.TruncateOutput,
+		},
+	}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *wrapperspb.BytesValue:
+			execErr.out = d.Value
+		case *wrapperspb.StringValue:
+			execErr.Stderr = d.Value
+		case *wrapperspb.Int32Value:
+			execErr.ExitCode = int(d.Value)
+		}
+	}
+	return execErr, true
+}
+
+// evaluatorStatus returns the status of the failure of the function image.
+// If the function was run and failed, its output, stderr and exit code are
+// attached to the status as BytesValue, StringValue and Int32Value details,
+// so the client can report its results.
+func evaluatorStatus(image string, out []byte, err error) error {
+	st := status.Newf(codes.Internal, "failed to evaluate function %q: %s", image, err)
+	var execErr *ExecError
+	if !goerrors.As(err, &execErr) {
+		return st.Err()
+	}
+	withDetails, detailsErr := st.WithDetails(
+		wrapperspb.Bytes(out),
+		wrapperspb.String(execErr.Stderr),
+		wrapperspb.Int32(int32(execErr.ExitCode)),
+	)
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// FnEvaluator evaluates functions on behalf of the clients of the
+// FunctionEvaluator service. The functions are run with ContainerFn, unless
+// their image is implemented by an executable.
+type FnEvaluator struct {
+	// Executables maps function images to the path of the executable
+	// implementing them.
+	Executables map[string]string
+
+	// ImagePullPolicy controls the image pulling behavior of the container
+	// functions.
+	ImagePullPolicy ImagePullPolicy
+}
+
+// Evaluate runs the function image on resourceList and returns the output
+// ResourceList and the stderr of the function. The output and stderr are
+// also returned if the function fails, so its results can be reported.
+func (e *FnEvaluator) Evaluate(ctx context.Context, image string, resourceList []byte) ([]byte, []byte, error) {
+	fnResult := &fnresult.Result{}
+	var run func(io.Reader, io.Writer) error
+	if path, found := e.Executables[image]; found {
+		run = (&ExecFn{Path: path, FnResult: fnResult}).Run
+	} else {
+		run = (&ContainerFn{
+			Ctx:             ctx,
+			Image:           image,
+			ImagePullPolicy: e.ImagePullPolicy,
+			FnResult:        fnResult,
+		}).Run
+	}
+	out := &bytes.Buffer{}
+	if err := run(bytes.NewReader(resourceList), out); err != nil {
+		var execErr *ExecError
+		if goerrors.As(err, &execErr) {
+			return out.Bytes(), []byte(execErr.Stderr), err
+		}
+		return out.Bytes(), nil, err
+	}
+	return out.Bytes(), []byte(fnResult.Stderr), nil
+}
+
+// NewFnEvaluatorServer returns a gRPC server serving the FunctionEvaluator
+// service with e.
+func NewFnEvaluatorServer(e *FnEvaluator) *grpc.Server {
+	s := grpc.NewServer(grpc.ForceServerCodec(evaluatorCodec{}))
+	s.RegisterService(&evaluatorServiceDesc, e)
+	return s
+}
+
+func (e *FnEvaluator) evaluateFunction(ctx context.Context, req *evaluateFunctionRequest) (*evaluateFunctionResponse, error) {
+	if req.image == "" {
+		return nil, status.Error(codes.InvalidArgument, "function image is required")
+	}
+	out, log, err := e.Evaluate(ctx, req.image, req.resourceList)
+	if err != nil {
+		return nil, evaluatorStatus(req.image, out, err)
+	}
+	return &evaluateFunctionResponse{resourceList: out, log: log}, nil
+}
+
+type functionEvaluatorServer interface {
+	evaluateFunction(context.Context, *evaluateFunctionRequest) (*evaluateFunctionResponse, error)
+}
+
+var evaluatorServiceDesc = grpc.ServiceDesc{
+	ServiceName: evaluatorServiceName,
+	HandlerType: (*functionEvaluatorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: evaluateFunctionMethod,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &evaluateFunctionRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(functionEvaluatorServer).evaluateFunction(ctx, req.(*evaluateFunctionRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: evaluateFunctionFullRPC}
+				return interceptor(ctx, req, info, handler)
+			},
+		},
+	},
+	Metadata: "evaluator.proto",
+}
+
+// fnExecutablesConfig is the configuration of the executable functions of
+// the function runner of porch.
+type fnExecutablesConfig struct {
+	Functions []struct {
+		Function string   `yaml:"function"`
+		Images   []string `yaml:"images"`
+	} `yaml:"functions"`
+}
+
+// LoadFnExecutables reads a configuration file in the format of the function
+// runner of porch, mapping function images to executables in functionsDir.
+func LoadFnExecutables(functionsDir, config string) (map[string]string, error) {
+	b, err := os.ReadFile(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %q: %w", config, err)
+	}
+	var cfg fnExecutablesConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %q: %w", config, err)
+	}
+	executables := map[string]string{}
+	for _, f := range cfg.Functions {
+		path, err := filepath.Abs(filepath.Join(functionsDir, f.Function))
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range f.Images {
+			if _, found := executables[image]; !found {
+				executables[image] = path
+			}
+		}
+	}
+	return executables, nil
+}
+
+// evaluatorMessage is a message of the FunctionEvaluator service.
+type evaluatorMessage interface {
+	marshal() []byte
+	unmarshal([]byte) error
+}
+
+// evaluateFunctionRequest is the EvaluateFunctionRequest message.
+type evaluateFunctionRequest struct {
+	resourceList []byte // field 1
+	image        string // field 2
+}
+
+func (m *evaluateFunctionRequest) marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.resourceList)
+	return appendBytesField(b, 2, []byte(m.image))
+}
+
+func (m *evaluateFunctionRequest) unmarshal(b []byte) error {
+	return consumeBytesFields(b, map[protowire.Number]func([]byte){
+		1: func(v []byte) { m.resourceList = v },
+		2: func(v []byte) { m.image = string(v) },
+	})
+}
+
+// evaluateFunctionResponse is the EvaluateFunctionResponse message.
+type evaluateFunctionResponse struct {
+	resourceList []byte // field 1
+	log          []byte // field 2
+}
+
+func (m *evaluateFunctionResponse) marshal() []byte {
+	var b []byte
+	b = appendBytesField(b, 1, m.resourceList)
+	return appendBytesField(b, 2, m.log)
+}
+
+func (m *evaluateFunctionResponse) unmarshal(b []byte) error {
+	return consumeBytesFields(b, map[protowire.Number]func([]byte){
+		1: func(v []byte) { m.resourceList = v },
+		2: func(v []byte) { m.log = v },
+	})
+}
+
+func appendBytesField(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		// proto3 doesn't serialize fields with default values.
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// consumeBytesFields decodes the length-delimited fields of a message with
+// the setters of their numbers, unknown fields are skipped.
+func consumeBytesFields(b []byte, setters map[protowire.Number]func([]byte)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if set, found := setters[num]; found && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			set(append([]byte(nil), v...))
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// evaluatorCodec encodes the messages of the FunctionEvaluator service. It
+// is named "proto" since the messages are wire compatible with the ones
+// generated by protoc, so the content type of the requests is the one the
+// generated servers expect. It is only used by the FunctionEvaluator client
+// and server, it isn't registered as the default codec.
+type evaluatorCodec struct{}
+
+func (evaluatorCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(evaluatorMessage)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (evaluatorCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(evaluatorMessage)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (evaluatorCodec) Name() string {
+	return "proto"
+}
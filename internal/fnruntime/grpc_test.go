@@ -0,0 +1,165 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// upperScript writes its input in upper case. It fails with exit code 2 if
+// the input contains "fail", after writing its input unchanged.
+const upperScript = `#!/bin/sh
+input=$(cat)
+case "$input" in
+  *fail*) printf '%s' "$input"; echo "failed" >&2; exit 2 ;;
+esac
+printf '%s' "$input" | tr a-z A-Z
+echo "converted" >&2
+`
+
+func TestParseFnRuntime(t *testing.T) {
+	testCases := map[string]struct {
+		uri              string
+		expectedScheme   string
+		expectedLocation string
+		expectErr        bool
+	}{
+		"grpc": {
+			uri:              "grpc://localhost:9445",
+			expectedScheme:   GRPCScheme,
+			expectedLocation: "localhost:9445",
+		},
+		"record": {
+			uri:              "record://testdata/fixtures",
+			expectedScheme:   RecordScheme,
+			expectedLocation: "testdata/fixtures",
+		},
+		"replay": {
+			uri:              "replay:///tmp/fixtures",
+			expectedScheme:   ReplayScheme,
+			expectedLocation: "/tmp/fixtures",
+		},
+		"no directory": {
+			uri:       "replay://",
+			expectErr: true,
+		},
+		"no address": {
+			uri:       "grpc://",
+			expectErr: true,
+		},
+		"unsupported scheme": {
+			uri:       "http://localhost:9445",
+			expectErr: true,
+		},
+		"no scheme": {
+			uri:       "localhost:9445",
+			expectErr: true,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			scheme, location, err := ParseFnRuntime(tc.uri)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedScheme, scheme)
+			assert.Equal(t, tc.expectedLocation, location)
+		})
+	}
+}
+
+func TestGRPCRuntime(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "upper"), []byte(upperScript), 0700); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(config, []byte(`
+functions:
+- function: upper
+  images:
+  - example.com/upper:v1
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	executables, err := LoadFnExecutables(dir, config)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string]string{"example.com/upper:v1": filepath.Join(dir, "upper")}, executables)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewFnEvaluatorServer(&FnEvaluator{Executables: executables})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	rt, err := NewGRPCRuntime(lis.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer rt.Close()
+	stderr := &bytes.Buffer{}
+	ctx := printer.WithContext(context.Background(), printer.New(nil, stderr))
+
+	run := func(input string) (string, error) {
+		runner, err := rt.GetRunner(ctx, &kptfilev1.Function{Image: "example.com/upper:v1"})
+		if err != nil {
+			return "", err
+		}
+		out := &bytes.Buffer{}
+		err = runner.Run(strings.NewReader(input), out)
+		return out.String(), err
+	}
+
+	out, err := run("kind: foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "KIND: FOO", out)
+	assert.Contains(t, stderr.String(), "converted")
+
+	// the output and stderr of failed functions are returned
+	out, err = run("kind: fail")
+	var execErr *ExecError
+	if assert.ErrorAs(t, err, &execErr) {
+		assert.Equal(t, "failed\n", execErr.Stderr)
+		assert.Equal(t, 2, execErr.ExitCode)
+	}
+	assert.Equal(t, "kind: fail", out)
+
+	// functions without an image are run locally
+	runner, err := rt.GetRunner(ctx, &kptfilev1.Function{Exec: "upper"})
+	assert.NoError(t, err)
+	assert.Nil(t, runner)
+}
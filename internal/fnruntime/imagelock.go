@@ -0,0 +1,180 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/google/go-containerregistry/pkg/gcrane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/pflag"
+)
+
+// ImageLockMode controls how the function images locked in the Kptfile of
+// a package are used when running its pipeline.
+type ImageLockMode string
+
+const (
+	// ImageLockEnforce runs locked images by their digest, and fails if an
+	// image of a package with locked images isn't locked.
+	ImageLockEnforce ImageLockMode = "enforce"
+	// ImageLockWarn runs locked images by their digest, and prints a
+	// warning if an image of a package with locked images isn't locked.
+	ImageLockWarn ImageLockMode = "warn"
+	// ImageLockIgnore runs the images as specified in the pipeline.
+	ImageLockIgnore ImageLockMode = "ignore"
+)
+
+var allImageLockModes = []ImageLockMode{
+	ImageLockEnforce,
+	ImageLockWarn,
+	ImageLockIgnore,
+}
+
+// ImageLockMode can be used in pflag
+var _ pflag.Value = ((*ImageLockMode)(nil))
+
+// String implements pflag.Value and fmt.Stringer
+func (m *ImageLockMode) String() string {
+	return string(*m)
+}
+
+// Set implements pflag.Value
+func (m *ImageLockMode) Set(v string) error {
+	for _, c := range allImageLockModes {
+		if strings.EqualFold(v, string(c)) {
+			*m = c
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of " + strings.Join(m.AllStrings(), ", "))
+}
+
+func (m *ImageLockMode) AllStrings() []string {
+	var allStrings []string
+	for _, c := range allImageLockModes {
+		allStrings = append(allStrings, string(c))
+	}
+	return allStrings
+}
+
+// HelpAllowedValues builds help text for the allowed values
+func (m *ImageLockMode) HelpAllowedValues() string {
+	return "(one of " + strings.Join(m.AllStrings(), ", ") + ")"
+}
+
+// Type implements pflag.Value
+func (m *ImageLockMode) Type() string {
+	return "ImageLockMode"
+}
+
+// ImageLocks returns the digests of the function images locked in the
+// Kptfile, keyed by image.
+func ImageLocks(kf *kptfilev1.KptFile) map[string]string {
+	if kf.Status == nil || len(kf.Status.ImageLocks) == 0 {
+		return nil
+	}
+	locks := map[string]string{}
+	for _, l := range kf.Status.ImageLocks {
+		locks[l.Image] = l.Digest
+	}
+	return locks
+}
+
+// ResolveImageDigest returns the digest of the image in its registry.
+// Images that are referenced by digest are not looked up.
+func ResolveImageDigest(ctx context.Context, image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if d, ok := ref.(name.Digest); ok {
+		return d.DigestStr(), nil
+	}
+	desc, err := remote.Head(ref, remote.WithAuthFromKeychain(gcrane.Keychain), remote.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve the digest of image %q: %w", image, err)
+	}
+	return desc.Digest.String(), nil
+}
+
+// lockedImage returns the reference the image is run with. Images locked to
+// a digest are run by the digest, so a tag that is moved to another image
+// doesn't change the result of running the function. If the tag was moved,
+// the lock is out of date, which fails in enforce mode and prints a warning
+// in warn mode.
+func (o *RunnerOptions) lockedImage(ctx context.Context, image string) (string, error) {
+	if len(o.ImageLocks) == 0 || o.ImageLockMode == ImageLockIgnore {
+		// the package doesn't lock its images.
+		return image, nil
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return image, nil
+	}
+	digest, found := o.ImageLocks[image]
+	if !found {
+		if o.ImageLockMode == ImageLockWarn {
+			printer.FromContextOrDie(ctx).Printf("[WARNING] function image %q is not locked, run `kpt fn lock` to lock it\n", image)
+			return image, nil
+		}
+		return "", fmt.Errorf("function image %q is not locked, run `kpt fn lock` to lock it", image)
+	}
+	if o.ResolveDigest != nil {
+		// the lock is out of date if the image now resolves to another
+		// digest.
+		current, err := o.ResolveDigest(ctx, image)
+		if err == nil && current != digest {
+			err = fmt.Errorf("function image %q is locked to %s, but now resolves to %s, run `kpt fn lock` to update the lock",
+				image, digest, current)
+		}
+		if err != nil {
+			if o.ImageLockMode != ImageLockWarn {
+				return "", err
+			}
+			printer.FromContextOrDie(ctx).Printf("[WARNING] %s\n", err)
+		}
+	}
+	return ref.Context().Name() + "@" + digest, nil
+}
+
+// NewDigestResolver returns a function resolving the digests of images
+// like ResolveImageDigest, which resolves each image only once.
+func NewDigestResolver() func(ctx context.Context, image string) (string, error) {
+	var mu sync.Mutex
+	digests := map[string]string{}
+	return func(ctx context.Context, image string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if d, found := digests[image]; found {
+			return d, nil
+		}
+		d, err := ResolveImageDigest(ctx, image)
+		if err != nil {
+			return "", err
+		}
+		digests[image] = d
+		return d, nil
+	}
+}
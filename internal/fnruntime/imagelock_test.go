@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockedImage(t *testing.T) {
+	const digest = "sha256:4f4fb700ef54461cfa02571ae0db9a0dc1e0cdb5577484a6d75e68dc38e8acc1"
+	const newDigest = "sha256:5b5b700ef54461cfa02571ae0db9a0dc1e0cdb5577484a6d75e68dc38e8acc1"
+	locks := map[string]string{"gcr.io/kpt-fn/set-labels:v0.1": digest}
+	resolveDigest := func(d string, err error) func(context.Context, string) (string, error) {
+		return func(context.Context, string) (string, error) {
+			return d, err
+		}
+	}
+
+	testCases := map[string]struct {
+		image     string
+		opts      RunnerOptions
+		expected  string
+		expectErr string
+		warning   string
+	}{
+		"package without locks": {
+			image:    "gcr.io/kpt-fn/set-labels:v0.2",
+			opts:     RunnerOptions{ImageLockMode: ImageLockEnforce},
+			expected: "gcr.io/kpt-fn/set-labels:v0.2",
+		},
+		"locked image": {
+			image:    "gcr.io/kpt-fn/set-labels:v0.1",
+			opts:     RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockEnforce},
+			expected: "gcr.io/kpt-fn/set-labels@" + digest,
+		},
+		"image referenced by digest": {
+			image:    "gcr.io/kpt-fn/set-annotations@" + digest,
+			opts:     RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockEnforce},
+			expected: "gcr.io/kpt-fn/set-annotations@" + digest,
+		},
+		"unlocked image": {
+			image:     "gcr.io/kpt-fn/set-labels:v0.2",
+			opts:      RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockEnforce},
+			expectErr: "function image \"gcr.io/kpt-fn/set-labels:v0.2\" is not locked, run `kpt fn lock` to lock it",
+		},
+		"unlocked image with warning": {
+			image:    "gcr.io/kpt-fn/set-labels:v0.2",
+			opts:     RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockWarn},
+			expected: "gcr.io/kpt-fn/set-labels:v0.2",
+			warning:  "[WARNING] function image \"gcr.io/kpt-fn/set-labels:v0.2\" is not locked",
+		},
+		"lock up to date": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+			opts: RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockEnforce,
+				ResolveDigest: resolveDigest(digest, nil)},
+			expected: "gcr.io/kpt-fn/set-labels@" + digest,
+		},
+		"lock out of date": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+			opts: RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockEnforce,
+				ResolveDigest: resolveDigest(newDigest, nil)},
+			expectErr: "function image \"gcr.io/kpt-fn/set-labels:v0.1\" is locked to " + digest +
+				", but now resolves to " + newDigest + ", run `kpt fn lock` to update the lock",
+		},
+		"lock out of date with warning": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+			opts: RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockWarn,
+				ResolveDigest: resolveDigest(newDigest, nil)},
+			expected: "gcr.io/kpt-fn/set-labels@" + digest,
+			warning:  "[WARNING] function image \"gcr.io/kpt-fn/set-labels:v0.1\" is locked to " + digest,
+		},
+		"image can't be resolved": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+			opts: RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockEnforce,
+				ResolveDigest: resolveDigest("", fmt.Errorf("offline"))},
+			expectErr: "offline",
+		},
+		"image can't be resolved with warning": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+			opts: RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockWarn,
+				ResolveDigest: resolveDigest("", fmt.Errorf("offline"))},
+			expected: "gcr.io/kpt-fn/set-labels@" + digest,
+			warning:  "[WARNING] offline",
+		},
+		"locks ignored": {
+			image:    "gcr.io/kpt-fn/set-labels:v0.1",
+			opts:     RunnerOptions{ImageLocks: locks, ImageLockMode: ImageLockIgnore},
+			expected: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			errOut := &bytes.Buffer{}
+			ctx := printer.WithContext(context.Background(), printer.New(nil, errOut))
+			image, err := tc.opts.lockedImage(ctx, tc.image)
+			if tc.expectErr != "" {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, image)
+			if tc.warning != "" {
+				assert.Contains(t, errOut.String(), tc.warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	goerrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// FnPolicyEnv is the name of the environment variable that overrides
+	// the path of the user-level function policy.
+	FnPolicyEnv = "KPT_FN_POLICY"
+
+	// FnPolicyFile is the name of the function policy file in the .kpt
+	// directory of the user's home directory or of a git repository.
+	FnPolicyFile = "fn-policy.yaml"
+
+	// FnPolicyKind is the kind of the function policy resource.
+	FnPolicyKind = "FunctionPolicy"
+)
+
+// FnPolicy restricts the images of the functions that can be run.
+//
+//	apiVersion: kpt.dev/v1alpha1
+//	kind: FunctionPolicy
+//	spec:
+//	  allowedRegistries:
+//	  - gcr.io/kpt-fn
+//	  allowedImages:
+//	  - gcr.io/my-org/*
+//	  deniedImages:
+//	  - gcr.io/kpt-fn/starlark:*
+//	  requiredDigests:
+//	  - image: gcr.io/my-org/*
+//	    digest: sha256:4f4f...
+type FnPolicy struct {
+	yaml.ResourceMeta `yaml:",inline"`
+
+	Spec FnPolicySpec `yaml:"spec"`
+
+	// Path is the file the policy was read from.
+	Path string `yaml:"-"`
+}
+
+// FnPolicySpec lists the allowed and denied images.
+type FnPolicySpec struct {
+	// AllowedRegistries are the registries, optionally followed by a
+	// repository prefix, that images are allowed from, e.g. gcr.io/kpt-fn.
+	AllowedRegistries []string `yaml:"allowedRegistries,omitempty"`
+
+	// AllowedImages are glob patterns of allowed images, e.g. gcr.io/my-org/*.
+	// If neither registries nor images are allowed, all images are allowed
+	// unless they are denied.
+	AllowedImages []string `yaml:"allowedImages,omitempty"`
+
+	// DeniedImages are glob patterns of images that are not allowed, even if
+	// they are from an allowed registry or match an allowed image.
+	DeniedImages []string `yaml:"deniedImages,omitempty"`
+
+	// RequiredDigests are the digests the images matching a glob pattern
+	// must be run with, either because the image is referenced by digest,
+	// or because it is locked by `kpt fn lock`.
+	RequiredDigests []FnPolicyDigest `yaml:"requiredDigests,omitempty"`
+}
+
+// FnPolicyDigest is the digest the images matching a glob pattern must be
+// run with.
+type FnPolicyDigest struct {
+	Image  string `yaml:"image"`
+	Digest string `yaml:"digest"`
+}
+
+// UserFnPolicyPath returns the path of the user-level function policy. It
+// defaults to <HOME>/.kpt/fn-policy.yaml, unless overridden by the
+// KPT_FN_POLICY environment variable.
+func UserFnPolicyPath() (string, error) {
+	if p := os.Getenv(FnPolicyEnv); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error looking up user home dir: %w", err)
+	}
+	return filepath.Join(dir, ".kpt", FnPolicyFile), nil
+}
+
+// LoadFnPolicies reads the user-level function policy, and the repo-level
+// function policy in the .kpt directory of the root of the git repository
+// containing pkgPath. Policies that don't exist are skipped.
+func LoadFnPolicies(pkgPath string) ([]*FnPolicy, error) {
+	var paths []string
+	userPath, err := UserFnPolicyPath()
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, userPath)
+	if repoRoot, found := gitRepoRoot(pkgPath); found {
+		paths = append(paths, filepath.Join(repoRoot, ".kpt", FnPolicyFile))
+	}
+
+	var policies []*FnPolicy
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if goerrors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read function policy %q: %w", p, err)
+		}
+		policy, err := ParseFnPolicy(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid function policy %q: %w", p, err)
+		}
+		policy.Path = p
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// ParseFnPolicy parses a function policy.
+func ParseFnPolicy(b []byte) (*FnPolicy, error) {
+	policy := &FnPolicy{}
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	if err := d.Decode(policy); err != nil {
+		return nil, err
+	}
+	if policy.Kind != FnPolicyKind {
+		return nil, fmt.Errorf("kind must be %s, got %q", FnPolicyKind, policy.Kind)
+	}
+	return policy, nil
+}
+
+// gitRepoRoot returns the root directory of the git repository containing
+// path.
+func gitRepoRoot(path string) (string, bool) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// CheckFnPolicies returns an error if any of the policies doesn't allow
+// running image. runImage is the reference the image is run with, which
+// references the image by digest if it is locked.
+func CheckFnPolicies(policies []*FnPolicy, image, runImage string) error {
+	const op errors.Op = "fn.policy"
+	for _, p := range policies {
+		if err := p.check(image, runImage); err != nil {
+			return errors.E(op, errors.Fn(image), fmt.Errorf("not allowed by function policy %q: %w", p.Path, err))
+		}
+	}
+	return nil
+}
+
+func (p *FnPolicy) check(image, runImage string) error {
+	for _, pattern := range p.Spec.DeniedImages {
+		if globMatch(pattern, image) {
+			return fmt.Errorf("image matches denied image %q", pattern)
+		}
+	}
+
+	if len(p.Spec.AllowedRegistries) > 0 || len(p.Spec.AllowedImages) > 0 {
+		allowed := false
+		for _, registry := range p.Spec.AllowedRegistries {
+			if strings.HasPrefix(image, strings.TrimSuffix(registry, "/")+"/") {
+				allowed = true
+				break
+			}
+		}
+		if !allowed && !imageAllowed(image, p.Spec.AllowedImages) {
+			return fmt.Errorf("image is not from an allowed registry and doesn't match an allowed image")
+		}
+	}
+
+	for _, d := range p.Spec.RequiredDigests {
+		if !globMatch(d.Image, image) {
+			continue
+		}
+		ref, err := name.ParseReference(runImage)
+		if err != nil {
+			return err
+		}
+		digest, ok := ref.(name.Digest)
+		if !ok {
+			return fmt.Errorf("image must be run with digest %s, reference the image by digest or lock it with `kpt fn lock`", d.Digest)
+		}
+		if digest.DigestStr() != d.Digest {
+			return fmt.Errorf("image must be run with digest %s, got %s", d.Digest, digest.DigestStr())
+		}
+	}
+	return nil
+}
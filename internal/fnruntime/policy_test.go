@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFnPolicies(t *testing.T) {
+	const digest = "sha256:4f4fb700ef54461cfa02571ae0db9a0dc1e0cdb5577484a6d75e68dc38e8acc1"
+	const otherDigest = "sha256:5f4fb700ef54461cfa02571ae0db9a0dc1e0cdb5577484a6d75e68dc38e8acc1"
+
+	user, err := ParseFnPolicy([]byte(`
+apiVersion: kpt.dev/v1alpha1
+kind: FunctionPolicy
+spec:
+  allowedRegistries:
+  - gcr.io/kpt-fn/
+  allowedImages:
+  - gcr.io/my-org/*
+  deniedImages:
+  - gcr.io/kpt-fn/starlark:*
+  requiredDigests:
+  - image: gcr.io/my-org/fetcher:*
+    digest: ` + digest + `
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	user.Path = "user.yaml"
+	repo, err := ParseFnPolicy([]byte(`
+apiVersion: kpt.dev/v1alpha1
+kind: FunctionPolicy
+spec:
+  deniedImages:
+  - gcr.io/kpt-fn/apply-setters:*
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	repo.Path = "repo.yaml"
+	policies := []*FnPolicy{user, repo}
+
+	testCases := map[string]struct {
+		image     string
+		runImage  string
+		expectErr string
+	}{
+		"image from allowed registry": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"allowed image": {
+			image: "gcr.io/my-org/generator:v1",
+		},
+		"image from other registry": {
+			image:     "docker.io/evil/miner:latest",
+			expectErr: `fn.policy: fn docker.io/evil/miner:latest: not allowed by function policy "user.yaml": image is not from an allowed registry and doesn't match an allowed image`,
+		},
+		"registry prefix doesn't match partial path": {
+			image:     "gcr.io/kpt-fn-evil/set-labels:v0.1",
+			expectErr: `fn.policy: fn gcr.io/kpt-fn-evil/set-labels:v0.1: not allowed by function policy "user.yaml": image is not from an allowed registry and doesn't match an allowed image`,
+		},
+		"denied image": {
+			image:     "gcr.io/kpt-fn/starlark:v0.4",
+			expectErr: `fn.policy: fn gcr.io/kpt-fn/starlark:v0.4: not allowed by function policy "user.yaml": image matches denied image "gcr.io/kpt-fn/starlark:*"`,
+		},
+		"image denied by repo policy": {
+			image:     "gcr.io/kpt-fn/apply-setters:v0.2",
+			expectErr: `fn.policy: fn gcr.io/kpt-fn/apply-setters:v0.2: not allowed by function policy "repo.yaml": image matches denied image "gcr.io/kpt-fn/apply-setters:*"`,
+		},
+		"locked image with required digest": {
+			image:    "gcr.io/my-org/fetcher:v1",
+			runImage: "gcr.io/my-org/fetcher@" + digest,
+		},
+		"image without required digest": {
+			image:     "gcr.io/my-org/fetcher:v1",
+			expectErr: `fn.policy: fn gcr.io/my-org/fetcher:v1: not allowed by function policy "user.yaml": image must be run with digest ` + digest + ", reference the image by digest or lock it with `kpt fn lock`",
+		},
+		"image with other digest": {
+			image:     "gcr.io/my-org/fetcher:v1",
+			runImage:  "gcr.io/my-org/fetcher@" + otherDigest,
+			expectErr: `fn.policy: fn gcr.io/my-org/fetcher:v1: not allowed by function policy "user.yaml": image must be run with digest ` + digest + ", got " + otherDigest,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			runImage := tc.runImage
+			if runImage == "" {
+				runImage = tc.image
+			}
+			err := CheckFnPolicies(policies, tc.image, runImage)
+			if tc.expectErr != "" {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadFnPolicies(t *testing.T) {
+	dir := t.TempDir()
+	userPolicy := filepath.Join(dir, "user-policy.yaml")
+	t.Setenv(FnPolicyEnv, userPolicy)
+
+	write := func(path, content string) {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, os.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	pkgPath := filepath.Join(dir, "repo", "blueprints", "nginx")
+	write(filepath.Join(pkgPath, "Kptfile"), "")
+	write(filepath.Join(dir, "repo", ".git", "HEAD"), "")
+
+	policies, err := LoadFnPolicies(pkgPath)
+	assert.NoError(t, err)
+	assert.Empty(t, policies)
+
+	write(userPolicy, "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\n")
+	repoPolicy := filepath.Join(dir, "repo", ".kpt", FnPolicyFile)
+	write(repoPolicy, "apiVersion: kpt.dev/v1alpha1\nkind: FunctionPolicy\nspec:\n  deniedImages: ['*']\n")
+	policies, err = LoadFnPolicies(pkgPath)
+	if !assert.NoError(t, err) || !assert.Len(t, policies, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, userPolicy, policies[0].Path)
+	assert.Equal(t, repoPolicy, policies[1].Path)
+	assert.Equal(t, []string{"*"}, policies[1].Spec.DeniedImages)
+
+	write(repoPolicy, "kind: FunctionPolicy\nspec:\n  allowedImage: ['*']\n")
+	_, err = LoadFnPolicies(pkgPath)
+	assert.Error(t, err)
+}
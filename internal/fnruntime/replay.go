@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// fnFixture is a recorded function run, stored in a fixture directory as
+// <key>.yaml where key identifies the function and its input.
+type fnFixture struct {
+	// Function is the image or exec command of the function.
+	Function string `yaml:"function"`
+	// ExitCode is the exit code of the function.
+	ExitCode int `yaml:"exitCode"`
+	// Stderr is the content written to stderr by the function.
+	Stderr string `yaml:"stderr,omitempty"`
+	// Input is the input ResourceList, which includes the functionConfig.
+	Input string `yaml:"input"`
+	// Output is the output ResourceList, which includes the results.
+	Output string `yaml:"output"`
+}
+
+// fnFixtureID returns the identity of a function in the fixtures, or false
+// if its runs are not recorded. Starlark functions and the builtin package
+// context generator run in-process, so they don't need to be recorded.
+func fnFixtureID(f *kptfilev1.Function) (string, bool) {
+	switch {
+	case f.Starlark != nil || f.Image == FuncGenPkgContext:
+		return "", false
+	case f.Image != "":
+		return f.Image, true
+	case f.Exec != "":
+		return f.Exec, true
+	default:
+		return "", false
+	}
+}
+
+func fnFixturePath(dir, id string, input []byte) string {
+	return filepath.Join(dir, fnCacheKey(id, input)+".yaml")
+}
+
+// FnRecorder records every function run in a fixture directory, so they can
+// be replayed by a ReplayRuntime.
+type FnRecorder struct {
+	// Dir is the fixture directory.
+	Dir string
+}
+
+// Wrap returns a run function that runs the function identified by id with
+// run and records its run. The runs that fail without an exit code, e.g.
+// because the container runtime isn't available, are not recorded.
+func (fr *FnRecorder) Wrap(id string, run func(io.Reader, io.Writer) error, fnResult *fnresult.Result) func(io.Reader, io.Writer) error {
+	return func(r io.Reader, w io.Writer) error {
+		input, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		output := &bytes.Buffer{}
+		runErr := run(bytes.NewReader(input), io.MultiWriter(w, output))
+		fixture := &fnFixture{
+			Function: id,
+			Stderr:   fnResult.Stderr,
+			Input:    string(input),
+			Output:   output.String(),
+		}
+		if runErr != nil {
+			var execErr *ExecError
+			if !goerrors.As(runErr, &execErr) {
+				return runErr
+			}
+			fixture.ExitCode = execErr.ExitCode
+			fixture.Stderr = execErr.Stderr
+		}
+		b, err := yaml.Marshal(fixture)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(fnFixturePath(fr.Dir, id, input), b); err != nil {
+			return fmt.Errorf("failed to record the run of function %q: %w", id, err)
+		}
+		return runErr
+	}
+}
+
+// ReplayRuntime is a function runtime replaying the function runs recorded
+// by a FnRecorder, without running containers or accessing the network. A
+// function run that wasn't recorded with the same input fails.
+type ReplayRuntime struct {
+	// Dir is the fixture directory.
+	Dir string
+}
+
+var _ fn.FunctionRuntime = &ReplayRuntime{}
+
+// GetRunner implements FunctionRuntime. It returns a nil runner for the
+// functions that are not recorded, which run locally.
+func (rr *ReplayRuntime) GetRunner(ctx context.Context, f *kptfilev1.Function) (fn.FunctionRunner, error) {
+	id, ok := fnFixtureID(f)
+	if !ok {
+		return nil, nil
+	}
+	return &replayRunner{dir: rr.Dir, id: id}, nil
+}
+
+type replayRunner struct {
+	dir string
+	id  string
+}
+
+var _ fn.FunctionRunner = &replayRunner{}
+
+func (rr *replayRunner) Run(r io.Reader, w io.Writer) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	path := fnFixturePath(rr.dir, rr.id, input)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no recorded run of function %q for its input in %q, record it with --fn-runtime=%s%s",
+			rr.id, rr.dir, RecordScheme, rr.dir)
+	}
+	if err != nil {
+		return err
+	}
+	fixture := &fnFixture{}
+	if err := yaml.Unmarshal(b, fixture); err != nil {
+		return fmt.Errorf("failed to parse recorded run %q: %w", path, err)
+	}
+	if _, err := w.Write([]byte(fixture.Output)); err != nil {
+		return err
+	}
+	if fixture.ExitCode != 0 {
+		return &ExecError{
+			OriginalErr:    fmt.Errorf("recorded exit code %d", fixture.ExitCode),
+			ExitCode:       fixture.ExitCode,
+			Stderr:         fixture.Stderr,
+			TruncateOutput: printer.TruncateOutput,
+		}
+	}
+	return nil
+}
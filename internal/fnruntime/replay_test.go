@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	goerrors "errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	upper := filepath.Join(t.TempDir(), "upper")
+	if err := os.WriteFile(upper, []byte(upperScript), 0700); err != nil {
+		t.Fatal(err)
+	}
+	fixtures := t.TempDir()
+	const image = "example.com/upper:v1"
+
+	record := func(input string) (string, error) {
+		fnResult := &fnresult.Result{}
+		e := &ExecFn{Path: upper, FnResult: fnResult}
+		run := (&FnRecorder{Dir: fixtures}).Wrap(image, e.Run, fnResult)
+		out := &bytes.Buffer{}
+		err := run(strings.NewReader(input), out)
+		return out.String(), err
+	}
+
+	out, err := record("kind: foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "KIND: FOO", out)
+	_, err = record("fail")
+	assert.Error(t, err)
+
+	rt := &ReplayRuntime{Dir: fixtures}
+	replay := func(input string) (string, error) {
+		runner, err := rt.GetRunner(context.Background(), &kptfilev1.Function{Image: image})
+		if err != nil {
+			return "", err
+		}
+		out := &bytes.Buffer{}
+		err = runner.Run(strings.NewReader(input), out)
+		return out.String(), err
+	}
+
+	// the script is removed so the function can only be replayed
+	if err := os.Remove(upper); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = replay("kind: foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "KIND: FOO", out)
+
+	_, err = replay("fail")
+	var execErr *ExecError
+	if assert.True(t, goerrors.As(err, &execErr)) {
+		assert.Equal(t, 2, execErr.ExitCode)
+		assert.Equal(t, "failed\n", execErr.Stderr)
+	}
+
+	_, err = replay("kind: bar")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no recorded run")
+		assert.Contains(t, err.Error(), RecordScheme+fixtures)
+	}
+
+	// in-process functions are not replayed
+	runner, err := rt.GetRunner(context.Background(), &kptfilev1.Function{Image: FuncGenPkgContext})
+	assert.NoError(t, err)
+	assert.Nil(t, runner)
+}
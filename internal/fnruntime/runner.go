@@ -34,6 +34,7 @@ import (
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/google/shlex"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
@@ -68,6 +69,50 @@ type RunnerOptions struct {
 
 	// ResolveToImage will resolve a partial image to a fully-qualified one
 	ResolveToImage ImageResolveFunc
+
+	// FnCacheDir is the directory the results of container and exec
+	// functions are cached in. Results are not cached if it is empty.
+	FnCacheDir string
+
+	// Tracer records the input and output of every function run if set.
+	Tracer *Tracer
+
+	// AllowNetworkFor are the glob patterns of the images of the functions
+	// that are allowed network access if they declare it in the pipeline.
+	AllowNetworkFor []string
+
+	// AllowMountFor are the glob patterns of the images of the functions
+	// that are allowed to mount package directories if they declare them in
+	// the pipeline.
+	AllowMountFor []string
+
+	// ImageLocks are the digests the function images are locked to, keyed
+	// by image. Images are not locked if it is empty.
+	ImageLocks map[string]string
+
+	// ImageLockMode controls how locked images are run. It defaults to
+	// ImageLockEnforce.
+	ImageLockMode ImageLockMode
+
+	// ResolveDigest resolves the current digest of a locked image, to
+	// detect the locks that are out of date. An image that can't be resolved
+	// is handled like an out of date lock. Locks are not checked if it is nil.
+	ResolveDigest func(ctx context.Context, image string) (string, error)
+
+	// FnPolicies restrict the function images that can be run. An image
+	// must be allowed by all policies.
+	FnPolicies []*FnPolicy
+
+	// EnableBuiltins runs the functions that have a builtin implementation
+	// in-process instead of running their image.
+	EnableBuiltins bool
+
+	// FnRecorder records every function run if set.
+	FnRecorder *FnRecorder
+
+	// SandboxExec runs the function binary executables in a sandbox, see
+	// ExecFn.Sandbox.
+	SandboxExec bool
 }
 
 // ImageResolveFunc is the type for a function that can resolve a partial image to a (more) fully-qualified name
@@ -78,6 +123,16 @@ func (o *RunnerOptions) InitDefaults() {
 	o.ResolveToImage = ResolveToImageForCLI
 }
 
+// WithFnCache returns the run function for f, which returns cached results
+// if the function result cache is enabled.
+func (o *RunnerOptions) WithFnCache(f CacheableFn, fnResult *fnresult.Result) func(io.Reader, io.Writer) error {
+	if o.FnCacheDir == "" {
+		return f.Run
+	}
+	cache := &FnCache{Dir: o.FnCacheDir}
+	return cache.Wrap(f, fnResult)
+}
+
 // NewRunner returns a FunctionRunner given a specification of a function
 // and it's config.
 func NewRunner(
@@ -104,6 +159,7 @@ func NewRunner(
 	fnResult := &fnresult.Result{
 		Image:    f.Image,
 		ExecPath: f.Exec,
+		Starlark: starlarkName(f),
 		// TODO(droot): This is required for making structured results subpackage aware.
 		// Enable this once test harness supports filepath based assertions.
 		// Pkg: string(pkgPath),
@@ -118,10 +174,36 @@ func NewRunner(
 		GlobalScope: true,
 	}
 
+	if f.Starlark != nil {
+		// starlark functions run in-process, so they don't depend on the
+		// function runtime.
+		sr, err := newStarlarkRunner(fsys, f, pkgPath)
+		if err != nil {
+			return nil, err
+		}
+		warnUnenforcedSettings(ctx, f, "starlark", true)
+		fltr.Run = sr.Run
+		return NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, opts)
+	}
+
+	// runFn is the function as it is run, with its image locked to its
+	// digest if the package locks it.
+	runFn := *f
+	if f.Image != "" && f.Image != FuncGenPkgContext {
+		if runFn.Image, err = opts.lockedImage(ctx, f.Image); err != nil {
+			return nil, err
+		}
+		// the policies apply to the image whichever runtime runs it.
+		if err := CheckFnPolicies(opts.FnPolicies, f.Image, runFn.Image); err != nil {
+			return nil, err
+		}
+	}
+
 	if runtime != nil {
-		if runner, err := runtime.GetRunner(ctx, f); err != nil {
+		if runner, err := runtime.GetRunner(ctx, &runFn); err != nil {
 			return nil, fmt.Errorf("function runtime failed to evaluate function %q: %w", f.Image, err)
 		} else if runner != nil {
+			warnUnenforcedSettings(ctx, f, "function", false)
 			fltr.Run = runner.Run
 		}
 	}
@@ -134,19 +216,31 @@ func NewRunner(
 			case f.Image != "":
 				// If allowWasm is true, we will use wasm runtime for image field.
 				if opts.AllowWasm {
-					wFn, err := NewWasmFn(NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), f.Image))
+					wFn, err := NewWasmFn(NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), runFn.Image))
 					if err != nil {
 						return nil, err
 					}
+					warnUnenforcedSettings(ctx, f, "wasm", false)
 					fltr.Run = wFn.Run
 				} else {
+					if b, found := builtins.Lookup(runFn.Image); found && opts.EnableBuiltins {
+						// run the builtin implementation of the function in-process,
+						// which doesn't require a container runtime. A locked image
+						// only runs the builtin if it implements the locked digest.
+						warnUnenforcedSettings(ctx, f, "builtin", false)
+						fltr.Run = b.Run
+						break
+					}
 					cfn := &ContainerFn{
-						Image:           f.Image,
+						Image:           runFn.Image,
 						ImagePullPolicy: opts.ImagePullPolicy,
 						Ctx:             ctx,
 						FnResult:        fnResult,
 					}
-					fltr.Run = cfn.Run
+					if err := setContainerFnLimits(cfn, f, pkgPath, opts); err != nil {
+						return nil, err
+					}
+					fltr.Run = opts.WithFnCache(cfn, fnResult)
 				}
 			case f.Exec != "":
 				// If AllowWasm is true, we will use wasm runtime for exec field.
@@ -155,6 +249,7 @@ func NewRunner(
 					if err != nil {
 						return nil, err
 					}
+					warnUnenforcedSettings(ctx, f, "wasm", false)
 					fltr.Run = wFn.Run
 				} else {
 					var execArgs []string
@@ -174,17 +269,187 @@ func NewRunner(
 						Path:     execPath,
 						Args:     execArgs,
 						FnResult: fnResult,
+						Sandbox:  opts.SandboxExec,
+						Ctx:      ctx,
+					}
+					if eFn.Timeout, err = fnTimeout(f); err != nil {
+						return nil, err
 					}
-					fltr.Run = eFn.Run
+					warnUnenforcedSettings(ctx, f, "exec", true)
+					fltr.Run = opts.WithFnCache(eFn, fnResult)
 				}
 			default:
 				return nil, fmt.Errorf("must specify `exec` or `image` to execute a function")
 			}
 		}
 	}
+	if id, ok := fnFixtureID(&runFn); ok && opts.FnRecorder != nil {
+		fltr.Run = opts.FnRecorder.Wrap(id, fltr.Run, fnResult)
+	}
 	return NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, opts)
 }
 
+// starlarkName returns the name of the starlark function in the results.
+func starlarkName(f *kptfilev1.Function) string {
+	switch {
+	case f.Starlark == nil:
+		return ""
+	case f.Starlark.ScriptPath != "":
+		return f.Starlark.ScriptPath
+	default:
+		return "inline"
+	}
+}
+
+// newStarlarkRunner returns the runner of the script of a starlark
+// function, which is either inline or read from the package.
+func newStarlarkRunner(fsys filesys.FileSystem, f *kptfilev1.Function, pkgPath types.UniquePath) (*builtins.StarlarkRunner, error) {
+	sr := &builtins.StarlarkRunner{
+		Name:    starlarkName(f),
+		Program: f.Starlark.Source,
+		Timeout: defaultLongTimeout,
+	}
+	timeout, err := fnTimeout(f)
+	if err != nil {
+		return nil, err
+	}
+	if timeout != 0 {
+		sr.Timeout = timeout
+	}
+	if f.Starlark.ScriptPath != "" {
+		b, err := fsys.ReadFile(filepath.Join(string(pkgPath), filepath.FromSlash(f.Starlark.ScriptPath)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read starlark script %q: %w", f.Starlark.ScriptPath, err)
+		}
+		sr.Program = string(b)
+	}
+	return sr, nil
+}
+
+// fnTimeout returns the timeout declared by the function, or 0 if it uses
+// the default timeout.
+func fnTimeout(f *kptfilev1.Function) (time.Duration, error) {
+	if f.Timeout == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(f.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q of function %q: %w", f.Timeout, f.Image+f.Exec+starlarkName(f), err)
+	}
+	return timeout, nil
+}
+
+// setContainerFnLimits applies the timeout, limits, network access and
+// mounts declared by the function to the container function. Network access
+// and mounts are only granted to the images allowed by the runner options.
+func setContainerFnLimits(cfn *ContainerFn, f *kptfilev1.Function, pkgPath types.UniquePath, opts RunnerOptions) error {
+	var err error
+	if cfn.Timeout, err = fnTimeout(f); err != nil {
+		return err
+	}
+	if f.Limits != nil {
+		if f.Limits.Memory != "" {
+			q, err := resource.ParseQuantity(f.Limits.Memory)
+			if err != nil {
+				return fmt.Errorf("invalid memory limit %q of function %q: %w", f.Limits.Memory, f.Image, err)
+			}
+			cfn.Memory = q.Value()
+		}
+		if f.Limits.CPU != "" {
+			q, err := resource.ParseQuantity(f.Limits.CPU)
+			if err != nil {
+				return fmt.Errorf("invalid cpu limit %q of function %q: %w", f.Limits.CPU, f.Image, err)
+			}
+			cfn.CPUs = float64(q.MilliValue()) / 1000
+		}
+	}
+	if f.Network {
+		if !imageAllowed(f.Image, opts.AllowNetworkFor) {
+			return fmt.Errorf("function %q requires network access, which must be allowed with `--allow-network-for`", f.Image)
+		}
+		cfn.Perm.AllowNetwork = true
+	}
+	if len(f.Mounts) > 0 {
+		if !imageAllowed(f.Image, opts.AllowMountFor) {
+			return fmt.Errorf("function %q requires mounts, which must be allowed with `--allow-mount-for`", f.Image)
+		}
+		cfn.Perm.AllowMount = true
+		for _, m := range f.Mounts {
+			src, err := mountSrc(pkgPath, m.Src)
+			if err != nil {
+				return fmt.Errorf("invalid mount %q of function %q: %w", m.Src, f.Image, err)
+			}
+			cfn.StorageMounts = append(cfn.StorageMounts, runtimeutil.StorageMount{
+				MountType: "bind",
+				Src:       src,
+				DstPath:   m.Dst,
+			})
+		}
+	}
+	return nil
+}
+
+// warnUnenforcedSettings prints a warning if the function declares a
+// timeout, limits, network access or mounts, which only the container
+// runtime enforces, so they are not silently ignored when the function runs
+// with another runtime. timeoutEnforced is set if the runtime enforces the
+// timeout.
+func warnUnenforcedSettings(ctx context.Context, f *kptfilev1.Function, runtime string, timeoutEnforced bool) {
+	var settings []string
+	if f.Timeout != "" && !timeoutEnforced {
+		settings = append(settings, "timeout")
+	}
+	if f.Limits != nil && (f.Limits.Memory != "" || f.Limits.CPU != "") {
+		settings = append(settings, "limits")
+	}
+	if f.Network {
+		settings = append(settings, "network")
+	}
+	if len(f.Mounts) > 0 {
+		settings = append(settings, "mounts")
+	}
+	if len(settings) == 0 {
+		return
+	}
+	name := f.Image + f.Exec
+	if f.Starlark != nil {
+		name = "starlark:" + starlarkName(f)
+	}
+	printer.FromContextOrDie(ctx).Printf("[WARNING] the %s of function %q are not enforced by the %s runtime\n",
+		strings.Join(settings, ", "), name, runtime)
+}
+
+// imageAllowed returns true if the image matches one of the glob patterns.
+func imageAllowed(image string, patterns []string) bool {
+	for _, p := range patterns {
+		if globMatch(p, image) {
+			return true
+		}
+	}
+	return false
+}
+
+// mountSrc returns the absolute path of the mount source src, which must
+// not resolve to a path outside the package, even through symlinks.
+func mountSrc(pkgPath types.UniquePath, src string) (string, error) {
+	pkgDir, err := filepath.EvalSymlinks(string(pkgPath))
+	if err != nil {
+		return "", err
+	}
+	p, err := filepath.EvalSymlinks(filepath.Join(pkgDir, filepath.FromSlash(src)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(pkgDir, p)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path must not be outside the package directory")
+	}
+	return p, nil
+}
+
 // NewFunctionRunner returns a FunctionRunner given a specification of a function
 // and it's config.
 func NewFunctionRunner(ctx context.Context,
@@ -197,6 +462,9 @@ func NewFunctionRunner(ctx context.Context,
 	if name == "" {
 		name = fnResult.ExecPath
 	}
+	if name == "" && fnResult.Starlark != "" {
+		name = "starlark:" + fnResult.Starlark
+	}
 	// by default, the inner most runtimeutil.FunctionFilter scopes resources to the
 	// directory specified by the functionConfig, kpt v1+ doesn't scope resources
 	// during function execution, so marking the scope to global.
@@ -238,8 +506,19 @@ func (fr *FunctionRunner) Filter(input []*yaml.RNode) (output []*yaml.RNode, err
 		}
 		pr.Printf("\n")
 	}
+	var step *TraceStep
+	if fr.opts.Tracer != nil {
+		if step, err = fr.opts.Tracer.start(fr, input); err != nil {
+			return nil, fmt.Errorf("failed to record trace of function %q: %w", fr.name, err)
+		}
+	}
 	t0 := time.Now()
 	output, err = fr.do(input)
+	if step != nil {
+		if traceErr := fr.opts.Tracer.finish(step, output, err, time.Since(t0), fr.fnResult.ExitCode, fr.fnResult.Stderr); traceErr != nil && err == nil {
+			return nil, fmt.Errorf("failed to record trace of function %q: %w", fr.name, traceErr)
+		}
+	}
 	if err != nil {
 		printOpt := printer.NewOpt()
 		pr.OptPrintf(printOpt, "[FAIL] %q in %v\n", fr.name, time.Since(t0).Truncate(time.Millisecond*100))
@@ -259,6 +538,11 @@ func (fr *FunctionRunner) Filter(input []*yaml.RNode) (output []*yaml.RNode, err
 	return output, err
 }
 
+// Name returns the image or executable path of the function.
+func (fr *FunctionRunner) Name() string {
+	return fr.name
+}
+
 // SetFnConfig updates the functionConfig for the FunctionRunner instance.
 func (fr *FunctionRunner) SetFnConfig(conf *yaml.RNode) {
 	fr.filter.FunctionConfig = conf
@@ -19,14 +19,20 @@ package fnruntime
 import (
 	"bytes"
 	"context"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
@@ -646,3 +652,396 @@ func TestPrintFnStderr(t *testing.T) {
 		})
 	}
 }
+
+func TestSetContainerFnLimits(t *testing.T) {
+	pkgDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(pkgDir, "schemas"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(os.TempDir(), filepath.Join(pkgDir, "tmp")); err != nil {
+		t.Fatal(err)
+	}
+	realPkgDir, err := filepath.EvalSymlinks(pkgDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		fn        kptfilev1.Function
+		opts      RunnerOptions
+		expected  []string
+		expectErr string
+	}{
+		"defaults": {
+			fn:       kptfilev1.Function{Image: "gcr.io/my-org/fn:v1"},
+			expected: []string{"--network none"},
+		},
+		"limits": {
+			fn: kptfilev1.Function{
+				Image:  "gcr.io/my-org/fn:v1",
+				Limits: &kptfilev1.FunctionLimits{Memory: "512Mi", CPU: "500m"},
+			},
+			expected: []string{"--memory 536870912", "--cpus 0.5"},
+		},
+		"allowed network": {
+			fn:       kptfilev1.Function{Image: "gcr.io/my-org/fn:v1", Network: true},
+			opts:     RunnerOptions{AllowNetworkFor: []string{"gcr.io/other/*", "gcr.io/my-org/*"}},
+			expected: []string{"--network host"},
+		},
+		"network not allowed": {
+			fn:        kptfilev1.Function{Image: "gcr.io/my-org/fn:v1", Network: true},
+			opts:      RunnerOptions{AllowNetworkFor: []string{"gcr.io/other/*"}},
+			expectErr: "requires network access, which must be allowed with `--allow-network-for`",
+		},
+		"allowed mount": {
+			fn: kptfilev1.Function{
+				Image:  "gcr.io/my-org/fn:v1",
+				Mounts: []kptfilev1.FunctionMount{{Src: "schemas", Dst: "/schemas"}},
+			},
+			opts:     RunnerOptions{AllowMountFor: []string{"*"}},
+			expected: []string{"--mount type=bind,source=" + filepath.Join(realPkgDir, "schemas") + ",target=/schemas,readonly"},
+		},
+		"mount not allowed": {
+			fn: kptfilev1.Function{
+				Image:  "gcr.io/my-org/fn:v1",
+				Mounts: []kptfilev1.FunctionMount{{Src: "schemas", Dst: "/schemas"}},
+			},
+			expectErr: "requires mounts, which must be allowed with `--allow-mount-for`",
+		},
+		"mount through symlink outside of the package": {
+			fn: kptfilev1.Function{
+				Image:  "gcr.io/my-org/fn:v1",
+				Mounts: []kptfilev1.FunctionMount{{Src: "tmp", Dst: "/tmp"}},
+			},
+			opts:      RunnerOptions{AllowMountFor: []string{"*"}},
+			expectErr: "path must not be outside the package directory",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			cfn := &ContainerFn{Image: tc.fn.Image}
+			err := setContainerFnLimits(cfn, &tc.fn, types.UniquePath(pkgDir), tc.opts)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			cmd, cancel := cfn.getCmd(dockerBin)
+			defer cancel()
+			args := strings.Join(cmd.Args, " ")
+			for _, e := range tc.expected {
+				assert.Contains(t, args, e)
+			}
+		})
+	}
+}
+
+func TestNewRunner_starlark(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	if err := fsys.MkdirAll("/pkg/scripts"); err != nil {
+		t.Fatal(err)
+	}
+	script := `
+def scale(resources):
+  for r in resources:
+    r["spec"]["replicas"] = int(ctx.resource_list["functionConfig"]["data"]["replicas"])
+scale(ctx.resource_list["items"])
+ctx.resource_list["results"] = [{"message": "scaled", "severity": "info"}]
+`
+	if err := fsys.WriteFile("/pkg/scripts/scale.star", []byte(script)); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string]struct {
+		fn        kptfilev1.Function
+		expected  string
+		results   []string
+		expectErr string
+	}{
+		"inline": {
+			fn: kptfilev1.Function{
+				Starlark:  &kptfilev1.StarlarkFunction{Source: script},
+				ConfigMap: map[string]string{"replicas": "3"},
+			},
+			expected: "replicas: 3",
+			results:  []string{"scaled"},
+		},
+		"script path": {
+			fn: kptfilev1.Function{
+				Starlark:  &kptfilev1.StarlarkFunction{ScriptPath: "scripts/scale.star"},
+				ConfigMap: map[string]string{"replicas": "5"},
+			},
+			expected: "replicas: 5",
+			results:  []string{"scaled"},
+		},
+		"environment is not accessible": {
+			fn: kptfilev1.Function{
+				Starlark: &kptfilev1.StarlarkFunction{Source: `
+def check():
+  if ctx.environment.get("HOME"):
+    fail("environment must be empty")
+check()
+`},
+			},
+			expected: "replicas: 1",
+		},
+		"error": {
+			fn: kptfilev1.Function{
+				Starlark: &kptfilev1.StarlarkFunction{Source: `fail("replicas must be set")`},
+			},
+			expectErr: "replicas must be set",
+		},
+		"timeout": {
+			fn: kptfilev1.Function{
+				Starlark: &kptfilev1.StarlarkFunction{Source: `
+def loop():
+  for _ in range(1000000000):
+    pass
+loop()
+`},
+				Timeout: "100ms",
+			},
+			expectErr: "timed out after 100ms",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			input, err := kio.FromBytes([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			fnResults := fnresult.NewResultList()
+			fr, err := NewRunner(ctx, fsys, &tc.fn, "/pkg", fnResults, RunnerOptions{}, nil)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			output, err := fr.Filter(input)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) || !assert.Len(t, output, 1) {
+				t.FailNow()
+			}
+			assert.Contains(t, output[0].MustString(), tc.expected)
+			if !assert.Len(t, fnResults.Items, 1) {
+				t.FailNow()
+			}
+			assert.Equal(t, starlarkName(&tc.fn), fnResults.Items[0].Starlark)
+			var messages []string
+			for _, r := range fnResults.Items[0].Results {
+				messages = append(messages, r.Message)
+			}
+			assert.Equal(t, tc.results, messages)
+		})
+	}
+}
+
+// fakeRuntime is a function runtime running all the functions with run.
+// It records the images of the functions it runs.
+type fakeRuntime struct {
+	run    func(io.Reader, io.Writer) error
+	images []string
+}
+
+func (r *fakeRuntime) GetRunner(_ context.Context, f *kptfilev1.Function) (fn.FunctionRunner, error) {
+	r.images = append(r.images, f.Image)
+	return r, nil
+}
+
+func (r *fakeRuntime) Run(in io.Reader, out io.Writer) error {
+	return r.run(in, out)
+}
+
+func TestNewRunner_unenforcedSettings(t *testing.T) {
+	runtime := &fakeRuntime{run: func(in io.Reader, out io.Writer) error {
+		_, err := io.Copy(out, in)
+		return err
+	}}
+
+	testCases := map[string]struct {
+		fn       kptfilev1.Function
+		runtime  fn.FunctionRuntime
+		expected string
+	}{
+		"function runtime": {
+			fn: kptfilev1.Function{
+				Image:   "gcr.io/kpt-fn/set-labels:v0.1",
+				Timeout: "1m",
+				Limits:  &kptfilev1.FunctionLimits{Memory: "512Mi"},
+				Network: true,
+				Mounts:  []kptfilev1.FunctionMount{{Src: "data", Dst: "/data"}},
+			},
+			runtime: runtime,
+			expected: `[WARNING] the timeout, limits, network, mounts of function "gcr.io/kpt-fn/set-labels:v0.1" ` +
+				"are not enforced by the function runtime\n",
+		},
+		"starlark enforces the timeout": {
+			fn: kptfilev1.Function{
+				Starlark: &kptfilev1.StarlarkFunction{Source: "pass"},
+				Timeout:  "1m",
+				Limits:   &kptfilev1.FunctionLimits{CPU: "1"},
+			},
+			expected: `[WARNING] the limits of function "starlark:inline" are not enforced by the starlark runtime` + "\n",
+		},
+		"no settings": {
+			fn: kptfilev1.Function{
+				Image: "gcr.io/kpt-fn/set-labels:v0.1",
+			},
+			runtime: runtime,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ctx := printer.WithContext(context.Background(), printer.New(nil, out))
+			opts := RunnerOptions{}
+			opts.InitDefaults()
+			_, err := NewRunner(ctx, filesys.MakeFsInMemory(), &tc.fn, "/pkg", fnresult.NewResultList(), opts, tc.runtime)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestNewRunner_imageLock(t *testing.T) {
+	const setNamespace = "gcr.io/kpt-fn/set-namespace:v0.4"
+	b, found := builtins.Lookup(setNamespace)
+	if !assert.True(t, found) {
+		t.FailNow()
+	}
+	builtinDigest := b.Digests[0]
+	const otherDigest = "sha256:5b5b700ef54461cfa02571ae0db9a0dc1e0cdb5577484a6d75e68dc38e8acc1"
+
+	testCases := map[string]struct {
+		locks        map[string]string
+		mode         ImageLockMode
+		withRuntime  bool
+		runtimeImage string
+		disabled     bool
+		builtin      bool
+	}{
+		"runtime runs the locked image": {
+			locks:        map[string]string{setNamespace: otherDigest},
+			mode:         ImageLockEnforce,
+			withRuntime:  true,
+			runtimeImage: "gcr.io/kpt-fn/set-namespace@" + otherDigest,
+		},
+		"runtime runs the tag if locks are ignored": {
+			locks:        map[string]string{setNamespace: otherDigest},
+			mode:         ImageLockIgnore,
+			withRuntime:  true,
+			runtimeImage: setNamespace,
+		},
+		"builtin implements the locked digest": {
+			locks:   map[string]string{setNamespace: builtinDigest},
+			mode:    ImageLockEnforce,
+			builtin: true,
+		},
+		"builtin doesn't implement the locked digest": {
+			locks:   map[string]string{setNamespace: otherDigest},
+			mode:    ImageLockEnforce,
+			builtin: false,
+		},
+		"builtin runs the tag if locks are ignored": {
+			locks:   map[string]string{setNamespace: otherDigest},
+			mode:    ImageLockIgnore,
+			builtin: true,
+		},
+		"builtin is not run unless enabled": {
+			locks:    map[string]string{setNamespace: builtinDigest},
+			mode:     ImageLockEnforce,
+			disabled: true,
+			builtin:  false,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			opts := RunnerOptions{ImageLocks: tc.locks, ImageLockMode: tc.mode, EnableBuiltins: !tc.disabled}
+			opts.InitDefaults()
+			var runtime *fakeRuntime
+			var fnRuntime fn.FunctionRuntime
+			if tc.withRuntime {
+				runtime = &fakeRuntime{}
+				fnRuntime = runtime
+			}
+			r, err := NewRunner(ctx, filesys.MakeFsInMemory(), &kptfilev1.Function{Image: setNamespace},
+				"/pkg", fnresult.NewResultList(), opts, fnRuntime)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.withRuntime {
+				assert.Equal(t, []string{tc.runtimeImage}, runtime.images)
+				return
+			}
+			isBuiltin := reflect.ValueOf(r.filter.Run).Pointer() == reflect.ValueOf(b.Run).Pointer()
+			assert.Equal(t, tc.builtin, isBuiltin)
+		})
+	}
+}
+
+func TestNewRunner_fnPolicies(t *testing.T) {
+	policies := []*FnPolicy{{
+		Spec: FnPolicySpec{DeniedImages: []string{"gcr.io/kpt-fn/starlark:*"}},
+		Path: "fn-policy.yaml",
+	}}
+	testCases := map[string]struct {
+		image     string
+		expectErr string
+	}{
+		"allowed image": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"denied image": {
+			image: "gcr.io/kpt-fn/starlark:v0.4",
+			expectErr: `not allowed by function policy "fn-policy.yaml": ` +
+				`image matches denied image "gcr.io/kpt-fn/starlark:*"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			opts := RunnerOptions{FnPolicies: policies}
+			opts.InitDefaults()
+			runtime := &fakeRuntime{}
+			_, err := NewRunner(ctx, filesys.MakeFsInMemory(), &kptfilev1.Function{Image: tc.image},
+				"/pkg", fnresult.NewResultList(), opts, runtime)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				assert.Empty(t, runtime.images)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tc.image}, runtime.images)
+		})
+	}
+}
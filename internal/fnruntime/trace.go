@@ -0,0 +1,216 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// TraceStepsDir is the directory of a trace the steps are recorded in.
+	TraceStepsDir = "steps"
+
+	// TraceStepFile is the file of a step directory describing the step.
+	TraceStepFile = "step.yaml"
+
+	// TraceInputFile is the file of a step directory containing the input
+	// ResourceList of the function.
+	TraceInputFile = "input.yaml"
+
+	// TraceOutputFile is the file of a step directory containing the output
+	// resources of the function.
+	TraceOutputFile = "output.yaml"
+
+	// TraceStderrFile is the file of a step directory containing the
+	// content the function wrote to stderr.
+	TraceStderrFile = "stderr"
+)
+
+// TraceStep describes a function run recorded in a trace.
+type TraceStep struct {
+	// Step is the position of the function run in the trace, starting at 1.
+	Step int `yaml:"step"`
+
+	// Function is the image or executable path of the function.
+	Function string `yaml:"function"`
+
+	// Pkg is the path of the package the function was run for, relative to
+	// the root package.
+	Pkg string `yaml:"pkg"`
+
+	// Duration is how long the function took to run.
+	Duration time.Duration `yaml:"duration"`
+
+	// ExitCode is the exit code of the function. The output of functions
+	// that failed is not recorded.
+	ExitCode int `yaml:"exitCode"`
+
+	// Dir is the directory the step is recorded in.
+	Dir string `yaml:"-"`
+}
+
+// Tracer records the input, output and stderr of every function run into a
+// directory, with one directory per function run:
+//
+//	<dir>/steps/<step>/step.yaml
+//	<dir>/steps/<step>/input.yaml
+//	<dir>/steps/<step>/output.yaml
+//	<dir>/steps/<step>/stderr
+type Tracer struct {
+	fsys    filesys.FileSystem
+	dir     string
+	rootPkg types.UniquePath
+
+	mu    sync.Mutex
+	steps int
+}
+
+// NewTracer returns a Tracer recording into dir. Steps recorded by a
+// previous trace in dir are removed. Package paths are recorded relative to
+// rootPkg.
+func NewTracer(fsys filesys.FileSystem, dir string, rootPkg types.UniquePath) (*Tracer, error) {
+	stepsDir := filepath.Join(dir, TraceStepsDir)
+	if fsys.Exists(stepsDir) {
+		if err := fsys.RemoveAll(stepsDir); err != nil {
+			return nil, fmt.Errorf("failed to remove previous trace: %w", err)
+		}
+	}
+	if err := fsys.MkdirAll(stepsDir); err != nil {
+		return nil, fmt.Errorf("failed to create trace directory: %w", err)
+	}
+	return &Tracer{fsys: fsys, dir: dir, rootPkg: rootPkg}, nil
+}
+
+// start records the input of a function run, and returns the step to
+// finish once the function has run.
+func (t *Tracer) start(fr *FunctionRunner, input []*yaml.RNode) (*TraceStep, error) {
+	t.mu.Lock()
+	t.steps++
+	n := t.steps
+	t.mu.Unlock()
+
+	pkgPath, err := filepath.Rel(string(t.rootPkg), string(fr.pkgPath))
+	if err != nil || t.rootPkg == "" {
+		pkgPath = string(fr.pkgPath)
+	}
+	step := &TraceStep{
+		Step:     n,
+		Function: fr.name,
+		Pkg:      filepath.ToSlash(pkgPath),
+		Dir:      filepath.Join(t.dir, TraceStepsDir, fmt.Sprintf("%03d", n)),
+	}
+	if err := t.fsys.MkdirAll(step.Dir); err != nil {
+		return nil, err
+	}
+	if err := t.writeResourceList(filepath.Join(step.Dir, TraceInputFile), input, fr.filter.FunctionConfig); err != nil {
+		return nil, err
+	}
+	return step, nil
+}
+
+// finish records the outcome, stderr and, unless the function failed, the
+// output of a function run.
+func (t *Tracer) finish(step *TraceStep, output []*yaml.RNode, fnErr error, duration time.Duration, exitCode int, stderr string) error {
+	step.Duration = duration
+	step.ExitCode = exitCode
+	if fnErr == nil {
+		if err := t.writeResourceList(filepath.Join(step.Dir, TraceOutputFile), output, nil); err != nil {
+			return err
+		}
+	}
+	if stderr != "" {
+		if err := t.fsys.WriteFile(filepath.Join(step.Dir, TraceStderrFile), []byte(stderr)); err != nil {
+			return err
+		}
+	}
+	b, err := yaml.Marshal(step)
+	if err != nil {
+		return err
+	}
+	return t.fsys.WriteFile(filepath.Join(step.Dir, TraceStepFile), b)
+}
+
+func (t *Tracer) writeResourceList(path string, nodes []*yaml.RNode, functionConfig *yaml.RNode) error {
+	out := &bytes.Buffer{}
+	err := kio.ByteWriter{
+		Writer:                out,
+		KeepReaderAnnotations: true,
+		WrappingAPIVersion:    kio.ResourceListAPIVersion,
+		WrappingKind:          kio.ResourceListKind,
+		FunctionConfig:        functionConfig,
+	}.Write(nodes)
+	if err != nil {
+		return err
+	}
+	return t.fsys.WriteFile(path, out.Bytes())
+}
+
+// ReadTrace returns the steps recorded in the trace in dir, ordered by
+// their position in the trace.
+func ReadTrace(fsys filesys.FileSystem, dir string) ([]TraceStep, error) {
+	stepsDir := filepath.Join(dir, TraceStepsDir)
+	if !fsys.IsDir(stepsDir) {
+		return nil, fmt.Errorf("%q does not contain a trace", dir)
+	}
+	names, err := fsys.ReadDir(stepsDir)
+	if err != nil {
+		return nil, err
+	}
+	var steps []TraceStep
+	for _, name := range names {
+		if _, err := strconv.Atoi(name); err != nil {
+			continue
+		}
+		stepDir := filepath.Join(stepsDir, name)
+		b, err := fsys.ReadFile(filepath.Join(stepDir, TraceStepFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read step %s of trace: %w", name, err)
+		}
+		var step TraceStep
+		if err := yaml.Unmarshal(b, &step); err != nil {
+			return nil, fmt.Errorf("failed to parse step %s of trace: %w", name, err)
+		}
+		step.Dir = stepDir
+		steps = append(steps, step)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Step < steps[j].Step
+	})
+	return steps, nil
+}
+
+// ReadTraceResources returns the resources of the ResourceList recorded in
+// the given file of a step, e.g. TraceInputFile.
+func ReadTraceResources(fsys filesys.FileSystem, step TraceStep, file string) ([]*yaml.RNode, error) {
+	b, err := fsys.ReadFile(filepath.Join(step.Dir, file))
+	if err != nil {
+		return nil, err
+	}
+	return (&kio.ByteReader{
+		Reader:                bytes.NewReader(b),
+		OmitReaderAnnotations: true,
+	}).Read()
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+func TestTracer(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	tracer, err := NewTracer(fsys, "/trace", types.UniquePath("/pkg"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	scale := func(r io.Reader, w io.Writer) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(strings.ReplaceAll(string(b), "replicas: 1", "replicas: 3")))
+		return err
+	}
+	fail := func(r io.Reader, w io.Writer) error {
+		return &ExecError{ExitCode: 2, Stderr: "replicas must be 1"}
+	}
+	newRunner := func(name, pkgPath string, run func(io.Reader, io.Writer) error) *FunctionRunner {
+		fr, err := NewFunctionRunner(fake.CtxWithDefaultPrinter(), &runtimeutil.FunctionFilter{Run: run},
+			types.UniquePath(pkgPath), &fnresult.Result{Image: name}, fnresult.NewResultList(), RunnerOptions{Tracer: tracer})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return fr
+	}
+
+	input, err := kio.FromBytes([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = newRunner("scale", "/pkg/sub", scale).Filter(input)
+	assert.NoError(t, err)
+	_, err = newRunner("validate", "/pkg", fail).Filter(input)
+	assert.Error(t, err)
+
+	steps, err := ReadTrace(fsys, "/trace")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, steps, 2) {
+		t.FailNow()
+	}
+	for i := range steps {
+		steps[i].Duration = 0
+	}
+	assert.Equal(t, []TraceStep{
+		{Step: 1, Function: "scale", Pkg: "sub", Dir: "/trace/steps/001"},
+		{Step: 2, Function: "validate", Pkg: ".", ExitCode: 2, Dir: "/trace/steps/002"},
+	}, steps)
+
+	for _, f := range []string{TraceInputFile, TraceOutputFile} {
+		resources, err := ReadTraceResources(fsys, steps[0], f)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		replicas, err := resources[0].GetFieldValue("spec.replicas")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{TraceInputFile: 1, TraceOutputFile: 3}[f], replicas, f)
+	}
+
+	// the output of failed functions is not recorded, but their stderr is
+	assert.False(t, fsys.Exists(filepath.Join(steps[1].Dir, TraceOutputFile)))
+	stderr, err := fsys.ReadFile(filepath.Join(steps[1].Dir, TraceStderrFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "replicas must be 1", string(stderr))
+
+	// a new trace replaces the previous one
+	_, err = NewTracer(fsys, "/trace", types.UniquePath("/pkg"))
+	assert.NoError(t, err)
+	steps, err = ReadTrace(fsys, "/trace")
+	assert.NoError(t, err)
+	assert.Empty(t, steps)
+
+	_, err = ReadTrace(fsys, "/missing")
+	assert.EqualError(t, err, fmt.Sprintf("%q does not contain a trace", "/missing"))
+}
@@ -18,8 +18,10 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"strconv"
 
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/expr"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -141,13 +143,25 @@ type SelectionContext struct {
 
 // SelectInput returns the selected resources based on criteria in selectors
 func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector, _ *SelectionContext) ([]*yaml.RNode, error) {
+	compiledSelectors, err := compileSelectors(selectors)
+	if err != nil {
+		return nil, err
+	}
+	compiledExclusions, err := compileSelectors(exclusions)
+	if err != nil {
+		return nil, err
+	}
 	var selectedInput []*yaml.RNode
 	if len(selectors) == 0 {
 		selectedInput = input
 	} else {
 		for _, node := range input {
-			for _, selector := range selectors {
-				if isMatch(node, selector) {
+			for _, selector := range compiledSelectors {
+				match, err := isMatch(node, selector)
+				if err != nil {
+					return nil, err
+				}
+				if match {
 					selectedInput = append(selectedInput, node)
 				}
 			}
@@ -159,8 +173,15 @@ func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector
 	var filteredInput []*yaml.RNode
 	for _, node := range selectedInput {
 		matchesExclusion := false
-		for _, exclusion := range exclusions {
-			if !exclusion.IsEmpty() && isMatch(node, exclusion) {
+		for _, exclusion := range compiledExclusions {
+			if exclusion.IsEmpty() {
+				continue
+			}
+			match, err := isMatch(node, exclusion)
+			if err != nil {
+				return nil, err
+			}
+			if match {
 				matchesExclusion = true
 				break
 			}
@@ -172,12 +193,49 @@ func SelectInput(input []*yaml.RNode, selectors, exclusions []kptfilev1.Selector
 	return filteredInput, nil
 }
 
+// compiledSelector is a selector with its field paths and match expression
+// parsed, so they are parsed once per function run rather than for every
+// resource.
+type compiledSelector struct {
+	kptfilev1.Selector
+	paths []*expr.Path
+	match *expr.Expr
+}
+
+// compileSelectors parses the field paths and match expressions of the
+// selectors.
+func compileSelectors(selectors []kptfilev1.Selector) ([]*compiledSelector, error) {
+	var compiled []*compiledSelector
+	for _, selector := range selectors {
+		c := &compiledSelector{Selector: selector}
+		for _, f := range selector.Fields {
+			path, err := expr.ParsePath(f.Path)
+			if err != nil {
+				return nil, err
+			}
+			c.paths = append(c.paths, path)
+		}
+		if selector.Match != "" {
+			e, err := expr.Parse(selector.Match)
+			if err != nil {
+				return nil, err
+			}
+			c.match = e
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
 // isMatch returns true if the resource matches input selection criteria
-func isMatch(node *yaml.RNode, selector kptfilev1.Selector) bool {
+func isMatch(node *yaml.RNode, selector *compiledSelector) (bool, error) {
 	// keep expanding with new selectors
-	return nameMatch(node, selector) && namespaceMatch(node, selector) &&
-		kindMatch(node, selector) && apiVersionMatch(node, selector) &&
-		labelMatch(node, selector) && annoMatch(node, selector)
+	if !(nameMatch(node, selector.Selector) && namespaceMatch(node, selector.Selector) &&
+		kindMatch(node, selector.Selector) && apiVersionMatch(node, selector.Selector) &&
+		labelMatch(node, selector.Selector) && annoMatch(node, selector.Selector)) {
+		return false, nil
+	}
+	return fieldsMatch(node, selector)
 }
 
 // nameMatch returns true if the resource name matches input selection criteria
@@ -222,6 +280,85 @@ func annoMatch(node *yaml.RNode, selector kptfilev1.Selector) bool {
 	return true
 }
 
+// fieldsMatch returns true if the resource matches the field selectors and
+// the match expression of the selection criteria
+func fieldsMatch(node *yaml.RNode, selector *compiledSelector) (bool, error) {
+	if len(selector.paths) == 0 && selector.match == nil {
+		return true, nil
+	}
+	resource, err := expr.ResourceValue(node)
+	if err != nil {
+		return false, err
+	}
+	for i, path := range selector.paths {
+		if !fieldValueMatch(path.Lookup(resource), selector.Fields[i].Value) {
+			return false, nil
+		}
+	}
+	if selector.match == nil {
+		return true, nil
+	}
+	return selector.match.EvalResource(resource)
+}
+
+// fieldValueMatch returns true if any of the field values matches the glob
+// pattern, or if any field is set if the pattern is empty.
+func fieldValueMatch(values []interface{}, pattern string) bool {
+	if pattern == "" {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case bool, int64:
+			s = fmt.Sprint(v)
+		case float64:
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			// null, lists and maps don't match any pattern
+			continue
+		}
+		if globMatch(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch returns true if s matches the glob pattern. In the pattern, '*'
+// matches any sequence of characters, including '/', and '?' matches any
+// single character.
+func globMatch(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	// position of the last '*' in the pattern, and of the character in s
+	// it is matched up to.
+	star, match := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, match = pi, si
+			pi++
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case star != -1:
+			// let the last '*' match one more character
+			pi = star + 1
+			match++
+			si = match
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
 func NewConfigMap(data map[string]string) (*yaml.RNode, error) {
 	node := yaml.NewMapRNode(&data)
 	if node == nil {
@@ -128,6 +128,194 @@ spec:
 			},
 			expected: false,
 		},
+		{
+			name: "field glob match",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Fields: []kptfile.FieldSelector{
+					{Path: "spec.template.spec.containers[*].image", Value: "gcr.io/*"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "field glob not matched",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Fields: []kptfile.FieldSelector{
+					{Path: "spec.template.spec.containers[*].image", Value: "docker.io/*"},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "field index and number match",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Fields: []kptfile.FieldSelector{
+					{Path: "spec.template.spec.containers[0].name", Value: "ng?nx"},
+					{Path: "spec.replicas", Value: "3"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "field must be set",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Fields: []kptfile.FieldSelector{
+					{Path: "spec.paused"},
+				},
+			},
+			expected: false,
+		},
+		{
+			name: "annotation key with dots",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Fields: []kptfile.FieldSelector{
+					{Path: "metadata.annotations['internal.config.k8s.io/kpt-resource-id']", Value: "0"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name: "match expression",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Kind:  "Deployment",
+				Match: "spec.replicas > 1 && spec.template.spec.containers.exists(c, c.name == 'proxy')",
+			},
+			expected: true,
+		},
+		{
+			name: "match expression not matched",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Match: "spec.replicas > 5",
+			},
+			expected: false,
+		},
+		{
+			name: "match expression on missing fields",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    internal.config.k8s.io/kpt-resource-id: "0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: proxy
+        image: gcr.io/proxy/envoy:v1`,
+			selector: kptfile.Selector{
+				Match: "data.env == 'prod'",
+			},
+			expected: false,
+		},
 	}
 
 	for i := range tests {
@@ -135,12 +323,60 @@ spec:
 		t.Run(tc.name, func(t *testing.T) {
 			node, err := yaml.Parse(tc.input)
 			assert.NoError(t, err)
-			actual := isMatch(node, tc.selector)
+			selectors, err := compileSelectors([]kptfile.Selector{tc.selector})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			actual, err := isMatch(node, selectors[0])
+			assert.NoError(t, err)
 			assert.Equal(t, tc.expected, actual)
 		})
 	}
 }
 
+func TestSelectInput_invalidMatch(t *testing.T) {
+	node, err := yaml.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+data:
+  env: prod`)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = SelectInput([]*yaml.RNode{node}, []kptfile.Selector{{Match: "data.env"}}, nil, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must evaluate to a bool, got string")
+	}
+	_, err = SelectInput([]*yaml.RNode{node}, nil, []kptfile.Selector{{Match: "data.env - 1"}}, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no such overload")
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		s        string
+		expected bool
+	}{
+		{pattern: "", s: "", expected: true},
+		{pattern: "nginx", s: "nginx", expected: true},
+		{pattern: "nginx", s: "nginx:1.21", expected: false},
+		{pattern: "nginx:*", s: "nginx:1.21", expected: true},
+		{pattern: "*", s: "gcr.io/kpt-fn/set-labels:v0.1", expected: true},
+		{pattern: "gcr.io/*:v0.1", s: "gcr.io/kpt-fn/set-labels:v0.1", expected: true},
+		{pattern: "gcr.io/*:v0.2", s: "gcr.io/kpt-fn/set-labels:v0.1", expected: false},
+		{pattern: "*a*b", s: "xaxxbxb", expected: true},
+		{pattern: "*a*b", s: "xaxxbx", expected: false},
+		{pattern: "v?.?", s: "v1.2", expected: true},
+		{pattern: "v?.?", s: "v1.22", expected: false},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, globMatch(tc.pattern, tc.s), "pattern %q, string %q", tc.pattern, tc.s)
+	}
+}
+
 func TestNewConfigMap(t *testing.T) {
 	data := map[string]string{
 		"normal string": "abc",
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+)
+
+// wasiExitStatus matches the message of the trap of the proc_exit WASI
+// function, since wasmtime-go doesn't expose the exit status.
+var wasiExitStatus = regexp.MustCompile(`^Exited with i32 exit status (\d+)`)
+
+// WasiFn runs a WASI preview1 module. Like a container function, the
+// module reads the ResourceList from stdin and writes it to stdout, so
+// functions compiled from any language targeting WASI, e.g. Rust or TinyGo,
+// can be run.
+type WasiFn struct {
+	engine *wasmtime.Engine
+	module *wasmtime.Module
+
+	loader WasmLoader
+}
+
+func NewWasiFn(loader WasmLoader) (*WasiFn, error) {
+	rc, err := loader.getReadCloser()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read wasm content from reader: %w", err)
+	}
+
+	engine, module, err := newWasmtimeModule(data)
+	if err != nil {
+		return nil, err
+	}
+	return &WasiFn{
+		engine: engine,
+		module: module,
+		loader: loader,
+	}, nil
+}
+
+// Run runs the module with r as its stdin, and writes its stdout to w.
+func (f *WasiFn) Run(r io.Reader, w io.Writer) error {
+	// wasmtime-go only supports files for the stdio of WASI modules.
+	dir, err := os.MkdirTemp("", "kpt-fn-wasi-")
+	if err != nil {
+		return fmt.Errorf("unable to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	stdin := filepath.Join(dir, "stdin")
+	stdout := filepath.Join(dir, "stdout")
+	stderr := filepath.Join(dir, "stderr")
+	in, err := os.Create(stdin)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(in, r)
+	if closeErr := in.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write the input resource list: %w", err)
+	}
+
+	wasi := wasmtime.NewWasiConfig()
+	wasi.SetArgv([]string{"kpt-fn-wasm-wasi"})
+	if err := wasi.SetStdinFile(stdin); err != nil {
+		return err
+	}
+	if err := wasi.SetStdoutFile(stdout); err != nil {
+		return err
+	}
+	if err := wasi.SetStderrFile(stderr); err != nil {
+		return err
+	}
+	store := wasmtime.NewStore(f.engine)
+	store.SetWasi(wasi)
+	linker := wasmtime.NewLinker(f.engine)
+	if err := linker.DefineWasi(); err != nil {
+		return err
+	}
+	instance, err := linker.Instantiate(store, f.module)
+	if err != nil {
+		return err
+	}
+	start := instance.GetFunc(store, "_start")
+	if start == nil {
+		return fmt.Errorf("_start: missing export")
+	}
+	_, runErr := start.Call(store)
+	exitCode := 0
+	if runErr != nil {
+		m := wasiExitStatus.FindStringSubmatch(runErr.Error())
+		if m == nil {
+			return fmt.Errorf("unable to run wasm module: %w", runErr)
+		}
+		exitCode, _ = strconv.Atoi(m[1])
+	}
+
+	out, err := os.ReadFile(stdout)
+	if err != nil {
+		return err
+	}
+	// the output is written even if the function fails, since it contains
+	// the results of the function.
+	if _, err := w.Write(out); err != nil {
+		return fmt.Errorf("unable to write the output resource list: %w", err)
+	}
+	if exitCode != 0 {
+		errOut, err := os.ReadFile(stderr)
+		if err != nil {
+			return err
+		}
+		return &ExecError{
+			OriginalErr:    runErr,
+			ExitCode:       exitCode,
+			Stderr:         string(errOut),
+			TruncateOutput: printer.TruncateOutput,
+		}
+	}
+	return f.loader.cleanup()
+}
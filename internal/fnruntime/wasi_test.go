@@ -0,0 +1,85 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	goerrors "errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoWasiModule echoes its stdin to stdout. If the input starts with "f",
+// it writes it to stderr instead and exits with 1.
+const echoWasiModule = `
+(module
+  (import "wasi_snapshot_preview1" "fd_read" (func $fd_read (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+  (memory (export "memory") 1)
+  (func (export "_start")
+    (i32.store (i32.const 0) (i32.const 100))
+    (i32.store (i32.const 4) (i32.const 60000))
+    (drop (call $fd_read (i32.const 0) (i32.const 0) (i32.const 1) (i32.const 8)))
+    (i32.store (i32.const 4) (i32.load (i32.const 8)))
+    (if (i32.eq (i32.load8_u (i32.const 100)) (i32.const 102))
+      (then
+        (drop (call $fd_write (i32.const 2) (i32.const 0) (i32.const 1) (i32.const 8)))
+        (call $proc_exit (i32.const 1))))
+    (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (i32.const 8)))))
+`
+
+func TestWasmFn_wasi(t *testing.T) {
+	t.Setenv(WasmCacheDirEnv, t.TempDir())
+	module, err := wasmtime.Wat2Wasm(echoWasiModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wasmFile := filepath.Join(t.TempDir(), "echo.wasm")
+	if err := os.WriteFile(wasmFile, module, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewWasmFn(&FsLoader{Filename: wasmFile})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NotNil(t, f.wasi) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	err = f.Run(strings.NewReader("kind: ResourceList"), out)
+	assert.NoError(t, err)
+	assert.Equal(t, "kind: ResourceList", out.String())
+
+	// the module is instantiated for every run
+	out.Reset()
+	err = f.Run(strings.NewReader("items: []"), out)
+	assert.NoError(t, err)
+	assert.Equal(t, "items: []", out.String())
+
+	out.Reset()
+	err = f.Run(strings.NewReader("fail"), out)
+	var execErr *ExecError
+	if assert.True(t, goerrors.As(err, &execErr)) {
+		assert.Equal(t, 1, execErr.ExitCode)
+		assert.Equal(t, "fail", execErr.Stderr)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleContainerTools/kpt/pkg/wasm"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 )
 
 type WasmRuntime string
@@ -40,9 +41,27 @@ type WasmFn struct {
 
 	wasmtime *WasmtimeFn
 	nodejs   *WasmNodejsFn
+	wasi     *WasiFn
 }
 
+// NewWasmFn returns a function running the wasm module of loader. WASI
+// modules are always run with wasmtime, the runtime of the Go js/wasm
+// modules is set with the KPT_FN_WASM_RUNTIME environment variable.
 func NewWasmFn(loader WasmLoader) (*WasmFn, error) {
+	platform, err := wasmModulePlatform(loader)
+	if err != nil {
+		return nil, err
+	}
+	if platform.Equals(wasm.WasiPlatform) {
+		wf, err := NewWasiFn(loader)
+		if err != nil {
+			return nil, err
+		}
+		return &WasmFn{
+			runtimeType: Wasmtime,
+			wasi:        wf,
+		}, nil
+	}
 	switch os.Getenv(WasmRuntimeEnv) {
 	case string(Nodejs):
 		nf, err := NewNodejsFn(loader)
@@ -74,12 +93,29 @@ func (f *WasmFn) Run(r io.Reader, w io.Writer) error {
 	case Nodejs:
 		return f.nodejs.Run(r, w)
 	case Wasmtime:
+		if f.wasi != nil {
+			return f.wasi.Run(r, w)
+		}
 		return f.wasmtime.Run(r, w)
 	default:
 		return fmt.Errorf("unknown wasm runtime type: %q", f.runtimeType)
 	}
 }
 
+// wasmModulePlatform returns the platform of the wasm module of loader.
+func wasmModulePlatform(loader WasmLoader) (v1.Platform, error) {
+	rc, err := loader.getReadCloser()
+	if err != nil {
+		return v1.Platform{}, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return v1.Platform{}, fmt.Errorf("unable to read wasm content from reader: %w", err)
+	}
+	return wasm.ModulePlatform(data)
+}
+
 type WasmLoader interface {
 	// getReadCloser returns an io.ReadCloser to read the wasm contents.
 	getReadCloser() (io.ReadCloser, error)
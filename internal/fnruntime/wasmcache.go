@@ -0,0 +1,221 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+)
+
+const (
+	// WasmCacheDirEnv is the name of the environment variable that controls
+	// the directory compiled wasm modules are cached in.
+	WasmCacheDirEnv = "KPT_WASM_CACHE_DIR"
+
+	wasmtimeModulePath = "github.com/bytecodealliance/wasmtime-go"
+
+	// compiledWasmExt is the extension of the compiled modules in the cache.
+	compiledWasmExt = ".cwasm"
+)
+
+// DefaultWasmCacheDir returns the directory compiled wasm modules are cached
+// in. It defaults to <HOME>/.kpt/wasm-cache, unless overridden by the
+// KPT_WASM_CACHE_DIR environment variable.
+func DefaultWasmCacheDir() (string, error) {
+	if dir := os.Getenv(WasmCacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error looking up user home dir: %w", err)
+	}
+	return filepath.Join(dir, ".kpt", "wasm-cache"), nil
+}
+
+// WasmtimeVersion returns the version of wasmtime kpt is built with.
+func WasmtimeVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == wasmtimeModulePath && dep.Version != "" {
+				return dep.Version
+			}
+		}
+	}
+	return "unknown"
+}
+
+// WasmCache is an on-disk cache of compiled wasm modules, keyed by the
+// version of wasmtime and the digest of the module. Compiled modules are
+// only valid for the version of wasmtime that compiled them, the cached
+// modules of other versions are never loaded.
+type WasmCache struct {
+	// Dir is the directory the compiled modules are stored in.
+	Dir string
+}
+
+// WasmCacheEntry is a compiled module in the cache.
+type WasmCacheEntry struct {
+	// Digest is the digest of the wasm module.
+	Digest string
+	// WasmtimeVersion is the version of wasmtime that compiled the module.
+	WasmtimeVersion string
+	// Size is the size of the compiled module in bytes.
+	Size int64
+	// LastUsed is when the compiled module was last used.
+	LastUsed time.Time
+}
+
+// Module returns the module of the wasm binary data, compiled for engine. It
+// loads the compiled module from the cache if it was compiled before, and
+// compiles and caches it otherwise.
+func (c *WasmCache) Module(engine *wasmtime.Engine, data []byte) (*wasmtime.Module, error) {
+	path := c.path(WasmtimeVersion(), wasmDigest(data))
+	if _, err := os.Stat(path); err == nil {
+		module, err := wasmtime.NewModuleDeserializeFile(engine, path)
+		if err == nil {
+			// the modification time records when the module was last
+			// used, so modules that are still used are not pruned.
+			now := time.Now()
+			_ = os.Chtimes(path, now, now)
+			return module, nil
+		}
+		// wasmtime rejects modules compiled with an incompatible
+		// configuration, recompile the module then.
+		_ = os.Remove(path)
+	}
+
+	module, err := wasmtime.NewModule(engine, data)
+	if err != nil {
+		return nil, err
+	}
+	// failing to cache the module must not fail the function.
+	if compiled, err := module.Serialize(); err == nil {
+		_ = writeFileAtomic(path, compiled)
+	}
+	return module, nil
+}
+
+// List returns the compiled modules in the cache, most recently used first.
+func (c *WasmCache) List() ([]WasmCacheEntry, error) {
+	var entries []WasmCacheEntry
+	err := c.walk(func(path string, entry WasmCacheEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries, err
+}
+
+// Prune removes the compiled modules that haven't been used for longer than
+// maxAge, or all of them if maxAge is 0. The modules compiled by other
+// versions of wasmtime are always removed. It returns the number of removed
+// modules.
+func (c *WasmCache) Prune(maxAge time.Duration) (int, error) {
+	now := time.Now()
+	version := WasmtimeVersion()
+	removed := 0
+	err := c.walk(func(path string, entry WasmCacheEntry) error {
+		if maxAge > 0 && now.Sub(entry.LastUsed) <= maxAge && entry.WasmtimeVersion == version {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// walk calls fn for each compiled module in the cache.
+func (c *WasmCache) walk(fn func(path string, entry WasmCacheEntry) error) error {
+	if _, err := os.Stat(c.Dir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != compiledWasmExt {
+			return nil
+		}
+		rel, err := filepath.Rel(c.Dir, path)
+		if err != nil {
+			return err
+		}
+		return fn(path, WasmCacheEntry{
+			Digest:          "sha256:" + strings.TrimSuffix(filepath.Base(path), compiledWasmExt),
+			WasmtimeVersion: filepath.Dir(rel),
+			Size:            info.Size(),
+			LastUsed:        info.ModTime(),
+		})
+	})
+}
+
+func (c *WasmCache) path(version, digest string) string {
+	return filepath.Join(c.Dir, version, digest+compiledWasmExt)
+}
+
+// newWasmtimeModule compiles the wasm binary data, using the compiled module
+// cache unless its directory can't be determined.
+func newWasmtimeModule(data []byte) (*wasmtime.Engine, *wasmtime.Module, error) {
+	engine := wasmtime.NewEngine()
+	dir, err := DefaultWasmCacheDir()
+	if err != nil {
+		module, err := wasmtime.NewModule(engine, data)
+		return engine, module, err
+	}
+	module, err := (&WasmCache{Dir: dir}).Module(engine, data)
+	return engine, module, err
+}
+
+// wasmDigest returns the hex encoded sha256 digest of a wasm module.
+func wasmDigest(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// writeFileAtomic writes a file through a temporary file, so concurrent
+// runs never read a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	wasmtime "github.com/bytecodealliance/wasmtime-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWasmCache(t *testing.T) {
+	data, err := wasmtime.Wat2Wasm(echoWasiModule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &WasmCache{Dir: t.TempDir()}
+	engine := wasmtime.NewEngine()
+
+	_, err = cache.Module(engine, data)
+	assert.NoError(t, err)
+	entries, err := cache.List()
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "sha256:"+wasmDigest(data), entries[0].Digest)
+	assert.Equal(t, WasmtimeVersion(), entries[0].WasmtimeVersion)
+	path := cache.path(entries[0].WasmtimeVersion, wasmDigest(data))
+
+	// the compiled module is loaded from the cache
+	_, err = cache.Module(engine, data)
+	assert.NoError(t, err)
+	entries, err = cache.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// an invalid compiled module is replaced
+	if err := os.WriteFile(path, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	module, err := cache.Module(engine, data)
+	assert.NoError(t, err)
+	assert.NotNil(t, module)
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "invalid", string(b))
+
+	// the modules of other wasmtime versions are pruned
+	other := cache.path("v0.0.1", wasmDigest(data))
+	if err := os.MkdirAll(filepath.Dir(other), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := cache.Prune(time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.FileExists(t, path)
+
+	removed, err = cache.Prune(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	assert.NoFileExists(t, path)
+}
@@ -56,14 +56,7 @@ func NewWasmtimeFn(loader WasmLoader) (*WasmtimeFn, error) {
 	}
 
 	// Create the engine and store.
-	config := wasmtime.NewConfig()
-	err = config.CacheConfigLoadDefault()
-	if err != nil {
-		return nil, fmt.Errorf("failed to config cache in wasmtime")
-	}
-	engine := wasmtime.NewEngineWithConfig(config)
-
-	module, err := wasmtime.NewModule(engine, data)
+	engine, module, err := newWasmtimeModule(data)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,202 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ociutil contains functions for working with kpt packages stored
+// as images in OCI registries. A package image has the same format as the
+// package revisions Porch stores in OCI repositories: the files of the
+// package are stored in tar layers, relative to the root of the package.
+package ociutil
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Scheme is the prefix used on the command line for package images.
+const Scheme = "oci://"
+
+// HasOciScheme returns true if the provided package url references a
+// package image in an OCI registry, i.e. it starts with "oci://".
+func HasOciScheme(pkgURL string) bool {
+	return strings.HasPrefix(pkgURL, Scheme)
+}
+
+// DefaultCacheDir returns the directory used for caching package images
+// if no other directory is specified.
+func DefaultCacheDir() string {
+	return filepath.Join(os.TempDir(), "kpt")
+}
+
+// ParseImage parses the provided image reference, with or without the
+// "oci://" prefix, and returns the fully qualified image name. Images
+// without tag or digest default to the "latest" tag.
+func ParseImage(image string) (string, error) {
+	ref, err := name.ParseReference(strings.TrimPrefix(image, Scheme))
+	if err != nil {
+		return "", fmt.Errorf("invalid image %q: %w", image, err)
+	}
+	return ref.Name(), nil
+}
+
+// ImageWithRef returns the provided image with the tag or digest replaced
+// by ref. A ref with an algorithm prefix (e.g. 'sha256:') is treated as a
+// digest, any other ref as a tag.
+func ImageWithRef(image, ref string) (string, error) {
+	r, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %q: %w", image, err)
+	}
+	if strings.Contains(ref, ":") {
+		d, err := name.NewDigest(r.Context().Name() + "@" + ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid digest %q: %w", ref, err)
+		}
+		return d.Name(), nil
+	}
+	t, err := name.NewTag(r.Context().Name() + ":" + ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid tag %q: %w", ref, err)
+	}
+	return t.Name(), nil
+}
+
+// ImageRef returns the tag or digest the provided image references.
+func ImageRef(image string) (string, error) {
+	r, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("invalid image %q: %w", image, err)
+	}
+	return r.Identifier(), nil
+}
+
+// Client pulls kpt packages from OCI registries.
+type Client struct {
+	*oci.Storage
+}
+
+// NewClient creates a new Client that caches images in cacheDir.
+func NewClient(cacheDir string) (*Client, error) {
+	store, err := oci.NewStorage(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Storage: store}, nil
+}
+
+// ResolveImage looks up the digest of the provided image, which can be
+// referenced either by tag or by digest.
+func (c *Client) ResolveImage(ctx context.Context, image string) (*oci.ImageDigestName, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image %q: %w", image, err)
+	}
+	if d, ok := ref.(name.Digest); ok {
+		return &oci.ImageDigestName{
+			Image:  d.Context().Name(),
+			Digest: d.DigestStr(),
+		}, nil
+	}
+
+	tagName, err := oci.ParseImageTagName(ref.Name())
+	if err != nil {
+		return nil, err
+	}
+	ociImage, err := c.ToRemoteImage(ctx, *tagName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get remote image %q: %w", image, err)
+	}
+	digest, err := ociImage.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get digest of image %q: %w", image, err)
+	}
+	return &oci.ImageDigestName{
+		Image:  tagName.Image,
+		Digest: digest.String(),
+	}, nil
+}
+
+// PullPackage extracts the files of the package stored in the provided image
+// into the dest directory.
+func (c *Client) PullPackage(ctx context.Context, image oci.ImageDigestName, dest string) error {
+	fetcher := func() (io.ReadCloser, error) {
+		ociImage, err := c.ToRemoteImage(ctx, image)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get remote image %q: %w", image, err)
+		}
+		return mutate.Extract(ociImage), nil
+	}
+
+	// Images are immutable when referenced by digest, so the extracted
+	// contents can be cached by digest.
+	f, err := oci.WithCacheFile(filepath.Join(c.GetCacheDir(), "packages", image.Digest), fetcher)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return extractTar(tar.NewReader(f), dest)
+}
+
+// extractTar writes the files in the tar archive into the dest directory.
+func extractTar(tarReader *tar.Reader, dest string) error {
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if p == ".." || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("package cannot contain files outside the package (%q)", hdr.Name)
+		}
+		target := filepath.Join(dest, filepath.FromSlash(p))
+
+		fileType := hdr.FileInfo().Mode().Type()
+		switch fileType {
+		case fs.ModeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case fs.ModeSymlink:
+			return fmt.Errorf("package cannot contain symlink (%q)", hdr.Name)
+		case 0:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			b, err := io.ReadAll(tarReader)
+			if err != nil {
+				return fmt.Errorf("error reading %q from image: %w", hdr.Name, err)
+			}
+			if err := os.WriteFile(target, b, 0644); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("package cannot contain unsupported entry type for %q (%v)", hdr.Name, fileType)
+		}
+	}
+}
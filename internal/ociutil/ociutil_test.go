@@ -0,0 +1,155 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociutil_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/ociutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageWithRef(t *testing.T) {
+	testCases := map[string]struct {
+		image    string
+		ref      string
+		expected string
+	}{
+		"replace tag": {
+			image:    "us-docker.pkg.dev/my-project/blueprints/nginx:v1",
+			ref:      "v2",
+			expected: "us-docker.pkg.dev/my-project/blueprints/nginx:v2",
+		},
+		"replace tag with digest": {
+			image:    "localhost:5000/nginx:v1",
+			ref:      "sha256:6d4b6d6f8f3b1d7e9e1b3b4b8f1d6f6c8a1e5e4a2f9d8c7b6a5f4e3d2c1b0a9f",
+			expected: "localhost:5000/nginx@sha256:6d4b6d6f8f3b1d7e9e1b3b4b8f1d6f6c8a1e5e4a2f9d8c7b6a5f4e3d2c1b0a9f",
+		},
+		"replace digest with tag": {
+			image:    "localhost:5000/nginx@sha256:6d4b6d6f8f3b1d7e9e1b3b4b8f1d6f6c8a1e5e4a2f9d8c7b6a5f4e3d2c1b0a9f",
+			ref:      "v1",
+			expected: "localhost:5000/nginx:v1",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			image, err := ImageWithRef(tc.image, tc.ref)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, image)
+		})
+	}
+}
+
+func TestPullPackage(t *testing.T) {
+	dataPath, err := testutil.GetTestDataPath()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	src := filepath.Join(dataPath, testutil.Dataset1)
+	image := testutil.StartOciRegistry(t) + "/blueprints/dataset1:v1"
+	digest := testutil.PushOciPackage(t, image, src)
+
+	client, err := NewClient(t.TempDir())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	digestName, err := client.ResolveImage(context.Background(), image)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, digest, digestName.Digest)
+
+	dest := t.TempDir()
+	if !assert.NoError(t, client.PullPackage(context.Background(), *digestName, dest)) {
+		t.FailNow()
+	}
+	diff, err := testutil.Diff(src, dest, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, diff.List())
+}
+
+func TestPullPackage_invalidEntries(t *testing.T) {
+	testCases := map[string]*tar.Header{
+		"symlink": {
+			Name:     "link.yaml",
+			Typeflag: tar.TypeSymlink,
+			Linkname: "/etc/passwd",
+		},
+		"file outside package": {
+			Name:     "../outside.yaml",
+			Typeflag: tar.TypeReg,
+		},
+	}
+
+	registry := testutil.StartOciRegistry(t)
+	for tn, hdr := range testCases {
+		hdr := hdr
+		t.Run(tn, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			w := tar.NewWriter(buf)
+			if !assert.NoError(t, w.WriteHeader(hdr)) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, w.Close()) {
+				t.FailNow()
+			}
+			layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			img, err := mutate.AppendLayers(empty.Image, layer)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			tag, err := name.NewTag(registry + "/invalid:v1")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, remote.Write(tag, img)) {
+				t.FailNow()
+			}
+
+			client, err := NewClient(t.TempDir())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			digestName, err := client.ResolveImage(context.Background(), tag.Name())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			err = client.PullPackage(context.Background(), *digestName, t.TempDir())
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/oci"
+	"github.com/google/go-containerregistry/pkg/gcrane"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	gitignore "github.com/monochromegane/go-gitignore"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+)
+
+const (
+	// AnnotationKeyLifecycle is the image annotation holding the lifecycle
+	// of a package revision, as read by Porch.
+	AnnotationKeyLifecycle = "kpt.dev/lifecycle"
+
+	// AnnotationKeyRevision is the image annotation holding the revision of
+	// a published package revision, as read by Porch.
+	AnnotationKeyRevision = "kpt.dev/revision"
+
+	// krmIgnoreFileName is the name of the file with patterns for files
+	// that should be excluded from a package.
+	krmIgnoreFileName = ".krmignore"
+)
+
+// PushPackage pushes the package in dir, including its subpackages, as an
+// image with the provided name. The annotations are added to the image
+// manifest. It returns the name and digest of the pushed image.
+func (c *Client) PushPackage(ctx context.Context, dir, image string, annotations map[string]string) (*oci.ImageDigestName, error) {
+	tag, err := name.NewTag(image)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse tag %q: %w", image, err)
+	}
+
+	b, err := packageToTar(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to archive package %q: %w", dir, err)
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}, tarball.WithCompressionLevel(gzip.BestCompression))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image layer: %w", err)
+	}
+
+	now := v1.Time{Time: time.Now()}
+	// Porch writes the annotations on the last layer, but reads them from
+	// the manifest, so we write them in both places.
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       layer,
+		Annotations: annotations,
+		History: v1.History{
+			Created:   now,
+			CreatedBy: "kpt pkg push",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append image layers: %w", err)
+	}
+	img, err = mutate.CreatedAt(img, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set created time for the image: %w", err)
+	}
+	img = mutate.Annotations(img, annotations).(v1.Image)
+
+	options := []remote.Option{
+		remote.WithAuthFromKeychain(gcrane.Keychain),
+		remote.WithContext(ctx),
+	}
+	if err := remote.Write(tag, img, options...); err != nil {
+		return nil, fmt.Errorf("failed to push image %s: %w", tag, err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get digest of the image: %w", err)
+	}
+	return &oci.ImageDigestName{
+		Image:  tag.Repository.Name(),
+		Digest: digest.String(),
+	}, nil
+}
+
+// packageToTar archives the files of the package in dir and its
+// subpackages. The .git folder and files matching the patterns in the
+// .krmignore file of a package are excluded.
+func packageToTar(dir string) ([]byte, error) {
+	if _, err := os.Stat(filepath.Join(dir, kptfilev1.KptFileName)); err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	writer := tar.NewWriter(buf)
+	ignore := &ignoreMatcher{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if copyutil.IsDotGitFolder(rel) {
+				return filepath.SkipDir
+			}
+			_, err := os.Stat(filepath.Join(path, kptfilev1.KptFileName))
+			switch {
+			case err == nil:
+				// Ignore patterns can not exclude a subpackage.
+				return ignore.addPackage(path)
+			case !os.IsNotExist(err):
+				return err
+			case ignore.match(path, true):
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if ignore.match(path, false) {
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("package cannot contain non-regular file %q", rel)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := writer.WriteHeader(&tar.Header{
+			Name: filepath.ToSlash(rel),
+			Size: int64(len(b)),
+			Mode: 0644,
+		}); err != nil {
+			return fmt.Errorf("failed to write tar header: %w", err)
+		}
+		if _, err := writer.Write(b); err != nil {
+			return fmt.Errorf("failed to write tar contents: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize tar contents: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ignoreMatcher matches paths against the patterns in the .krmignore files
+// of the packages being walked. Patterns only apply to files in the package
+// that contains the .krmignore file, not to its subpackages.
+type ignoreMatcher struct {
+	matchers []pkgIgnoreMatcher
+}
+
+type pkgIgnoreMatcher struct {
+	pkgPath string
+	matcher gitignore.IgnoreMatcher
+}
+
+// addPackage reads the .krmignore file, if any, of the package at pkgPath.
+func (i *ignoreMatcher) addPackage(pkgPath string) error {
+	i.forPath(pkgPath)
+	var m gitignore.IgnoreMatcher = gitignore.DummyIgnoreMatcher(false)
+	f, err := os.Open(filepath.Join(pkgPath, krmIgnoreFileName))
+	switch {
+	case err == nil:
+		defer f.Close()
+		m = gitignore.NewGitIgnoreFromReader(pkgPath, f)
+	case !os.IsNotExist(err):
+		return err
+	}
+	i.matchers = append(i.matchers, pkgIgnoreMatcher{pkgPath: pkgPath, matcher: m})
+	return nil
+}
+
+// match returns true if the path matches the patterns of the package
+// it belongs to.
+func (i *ignoreMatcher) match(path string, isDir bool) bool {
+	m := i.forPath(filepath.Dir(path))
+	if m == nil {
+		return false
+	}
+	return m.matcher.Match(path, isDir)
+}
+
+// forPath drops the matchers of packages the walk has left and returns the
+// matcher of the package containing dir.
+func (i *ignoreMatcher) forPath(dir string) *pkgIgnoreMatcher {
+	for j := len(i.matchers) - 1; j >= 0; j-- {
+		p := i.matchers[j].pkgPath
+		if dir == p || strings.HasPrefix(dir, p+string(filepath.Separator)) {
+			i.matchers = i.matchers[:j+1]
+			return &i.matchers[j]
+		}
+	}
+	i.matchers = nil
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociutil_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/ociutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushPackage(t *testing.T) {
+	testCases := map[string]struct {
+		files    map[string]string
+		expected []string
+	}{
+		"package without .krmignore": {
+			files: map[string]string{
+				"Kptfile":          "kind: Kptfile",
+				"deployment.yaml":  "kind: Deployment",
+				"docs/README.md":   "docs",
+				".git/config":      "git",
+				"sub/Kptfile":      "kind: Kptfile",
+				"sub/service.yaml": "kind: Service",
+			},
+			expected: []string{
+				"Kptfile",
+				"deployment.yaml",
+				"docs/README.md",
+				"sub/Kptfile",
+				"sub/service.yaml",
+			},
+		},
+		"ignored files and directories": {
+			files: map[string]string{
+				"Kptfile":         "kind: Kptfile",
+				".krmignore":      "*.txt\ntmp/\n",
+				"deployment.yaml": "kind: Deployment",
+				"notes.txt":       "notes",
+				"tmp/cache.yaml":  "kind: Cache",
+				"docs/notes.txt":  "notes",
+			},
+			expected: []string{
+				".krmignore",
+				"Kptfile",
+				"deployment.yaml",
+			},
+		},
+		"subpackages cannot be ignored": {
+			files: map[string]string{
+				"Kptfile":          "kind: Kptfile",
+				".krmignore":       "sub/\n*.txt\n",
+				"sub/Kptfile":      "kind: Kptfile",
+				"sub/notes.txt":    "notes",
+				"sub/.krmignore":   "service.yaml\n",
+				"sub/service.yaml": "kind: Service",
+			},
+			expected: []string{
+				".krmignore",
+				"Kptfile",
+				"sub/.krmignore",
+				"sub/Kptfile",
+				"sub/notes.txt",
+			},
+		},
+	}
+
+	registry := testutil.StartOciRegistry(t)
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := writePackage(t, tc.files)
+			client, err := NewClient(t.TempDir())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			digestName, err := client.PushPackage(context.Background(), dir, registry+"/pkg:v1", nil)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			dest := t.TempDir()
+			if !assert.NoError(t, client.PullPackage(context.Background(), *digestName, dest)) {
+				t.FailNow()
+			}
+			var files []string
+			err = filepath.Walk(dest, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dest, path)
+				files = append(files, filepath.ToSlash(rel))
+				return err
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			sort.Strings(files)
+			assert.Equal(t, tc.expected, files)
+		})
+	}
+}
+
+func TestPushPackage_annotations(t *testing.T) {
+	dir := writePackage(t, map[string]string{
+		"Kptfile":         "kind: Kptfile",
+		"deployment.yaml": "kind: Deployment",
+	})
+	image := testutil.StartOciRegistry(t) + "/blueprints/nginx:v1"
+
+	client, err := NewClient(t.TempDir())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	annotations := map[string]string{
+		AnnotationKeyLifecycle: "Published",
+		AnnotationKeyRevision:  "v1",
+	}
+	digestName, err := client.PushPackage(context.Background(), dir, image, annotations)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	ref, err := name.ParseReference(image)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	img, err := remote.Image(ref)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	digest, err := img.Digest()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, digest.String(), digestName.Digest)
+	manifest, err := img.Manifest()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, annotations, manifest.Annotations)
+}
+
+func TestPushPackage_notPackage(t *testing.T) {
+	client, err := NewClient(t.TempDir())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = client.PushPackage(context.Background(), t.TempDir(),
+		testutil.StartOciRegistry(t)+"/pkg:v1", nil)
+	assert.Error(t, err)
+}
+
+// writePackage writes the files, keyed by slash separated paths, into a new
+// temporary directory and returns the directory.
+func writePackage(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for p, content := range files {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/types"
@@ -385,6 +387,34 @@ func (p *Pkg) DirectSubpackages() ([]*Pkg, error) {
 	return subPkgs, nil
 }
 
+// Resolve returns the package located at the given slash-separated path
+// relative to the current package, e.g. './base' or '../foo'. The returned
+// package shares the root package of p for display purposes.
+func (p *Pkg) Resolve(relPath string) (*Pkg, error) {
+	if path.IsAbs(relPath) {
+		return nil, fmt.Errorf("package path %q must be relative", relPath)
+	}
+	resolved, err := New(p.fsys, filepath.Join(p.UniquePath.String(), filepath.FromSlash(relPath)))
+	if err != nil {
+		return nil, err
+	}
+	if err := p.adjustDisplayPathForSubpkg(resolved); err != nil {
+		return nil, fmt.Errorf("failed to resolve display path for %q: %w", relPath, err)
+	}
+	return resolved, nil
+}
+
+// IsDescendantOf returns true if p is located inside the directory of
+// the given package. A package is not a descendant of itself.
+func (p *Pkg) IsDescendantOf(ancestorPkg *Pkg) bool {
+	rel, err := p.RelativePathTo(ancestorPkg)
+	if err != nil {
+		return false
+	}
+	return rel != CurDir && rel != ParentDir &&
+		!strings.HasPrefix(rel, ParentDir+string(filepath.Separator))
+}
+
 // adjustDisplayPathForSubpkg adjusts the display path of subPkg relative to the RootPkgUniquePath
 // subPkg also inherits the RootPkgUniquePath value from parent package p
 func (p *Pkg) adjustDisplayPathForSubpkg(subPkg *Pkg) error {
@@ -164,6 +164,13 @@ func (p *pkg) allReferencedRepos(collector map[string]bool) {
 	if p.Kptfile != nil && p.Kptfile.Upstream != nil {
 		collector[p.Kptfile.Upstream.RepoRef] = true
 	}
+	if p.Kptfile != nil {
+		for _, sp := range p.Kptfile.Subpackages {
+			if sp.RepoRef != "" {
+				collector[sp.RepoRef] = true
+			}
+		}
+	}
 }
 
 // RootPkg is a package without any parent package.
@@ -328,6 +335,7 @@ func (rg *RGFile) WithInventory(inv Inventory) *RGFile {
 type Kptfile struct {
 	Upstream     *Upstream
 	UpstreamLock *UpstreamLock
+	Subpackages  []RemoteSubpackage
 	Pipeline     *Pipeline
 	Inventory    *Inventory
 }
@@ -407,6 +415,13 @@ type UpstreamLock struct {
 	Commit  string
 }
 
+// WithSubpackages adds the provided remote subpackages to the list of
+// subpackages declared in the Kptfile.
+func (k *Kptfile) WithSubpackages(subpackages ...RemoteSubpackage) *Kptfile {
+	k.Subpackages = append(k.Subpackages, subpackages...)
+	return k
+}
+
 func (k *Kptfile) WithInventory(inv Inventory) *Kptfile {
 	k.Inventory = &inv
 	return k
@@ -448,9 +463,8 @@ func (f Function) WithConfigPath(configPath string) Function {
 // RemoteSubpackage contains information about remote subpackages that should
 // be listed in the Kptfile.
 type RemoteSubpackage struct {
-	// Name is the name of the remote subpackage. It will be used as the value
-	// for the LocalDir property and also used to resolve the Repo path from
-	// other defined repos.
+	// RepoRef is the name of the repo of the remote subpackage. It is used
+	// to resolve the Repo path from other defined repos.
 	RepoRef   string
 	Repo      string
 	Directory string
@@ -584,6 +598,21 @@ upstreamLock:
     ref: {{.Pkg.Kptfile.UpstreamLock.Ref}}
     commit: {{.Pkg.Kptfile.UpstreamLock.Commit}}
 {{- end }}
+{{- if .Pkg.Kptfile.Subpackages }}
+subpackages:
+{{- range .Pkg.Kptfile.Subpackages }}
+- localDir: {{ .LocalDir }}
+  upstream:
+    type: git
+    git:
+      repo: {{ .Repo }}
+      directory: {{ .Directory }}
+      ref: {{ .Ref }}
+{{- if .Strategy }}
+    updateStrategy: {{ .Strategy }}
+{{- end }}
+{{- end }}
+{{- end }}
 {{- if .Pkg.Kptfile.Pipeline }}
 pipeline:
   mutators:
@@ -635,6 +664,16 @@ func buildKptfile(pkg *pkg, pkgName string, reposInfo ReposInfo) string {
 			pkg.Kptfile.UpstreamLock.Ref = newRef
 		}
 	}
+	for i := range pkg.Kptfile.Subpackages {
+		sp := &pkg.Kptfile.Subpackages[i]
+		if len(sp.RepoRef) == 0 {
+			continue
+		}
+		sp.Repo = resolveRepoRef(sp.RepoRef, reposInfo)
+		if newRef, ok := resolveCommitRef(sp.RepoRef, sp.Ref, reposInfo); ok {
+			sp.Ref = newRef
+		}
+	}
 	tmpl, err := template.New("test").Parse(kptfileTemplate)
 	if err != nil {
 		panic(err)
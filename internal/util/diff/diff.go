@@ -25,12 +25,14 @@ import (
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/ociutil"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
 	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/google/go-containerregistry/pkg/name"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -124,9 +126,13 @@ func (c *Command) Run(ctx context.Context) error {
 	}
 
 	// Return early if upstream is not set
-	if kptFile.Upstream == nil || kptFile.Upstream.Git == nil {
+	if kptFile.Upstream == nil || (kptFile.Upstream.Git == nil && kptFile.Upstream.Oci == nil) {
 		return errors.Errorf("package missing upstream in Kptfile at '%s'", c.Path)
 	}
+	upstreamRef, err := getUpstreamRef(kptFile.Upstream)
+	if err != nil {
+		return err
+	}
 
 	// Create a staging directory to store all compared packages
 	stagingDirectory, err := os.MkdirTemp("", "kpt-")
@@ -143,7 +149,7 @@ func (c *Command) Run(ctx context.Context) error {
 	// Stage current package
 	// This prevents prepareForDiff from modifying the local package
 	localPkgName := NameStagingDirectory(LocalPackageSource,
-		kptFile.Upstream.Git.Ref)
+		upstreamRef)
 	currPkg, err := stageDirectory(stagingDirectory, localPkgName)
 	if err != nil {
 		return errors.Errorf("failed to create stage dir for current package: %v", err)
@@ -156,13 +162,11 @@ func (c *Command) Run(ctx context.Context) error {
 
 	// get the upstreamPkg at current version
 	upstreamPkgName := NameStagingDirectory(RemotePackageSource,
-		kptFile.Upstream.Git.Ref)
+		upstreamRef)
 	upstreamPkg, err := c.PkgGetter.GetPkg(ctx,
 		stagingDirectory,
 		upstreamPkgName,
-		kptFile.Upstream.Git.Repo,
-		kptFile.Upstream.Git.Directory,
-		kptFile.Upstream.Git.Ref)
+		*kptFile.Upstream)
 	if err != nil {
 		return err
 	}
@@ -170,13 +174,17 @@ func (c *Command) Run(ctx context.Context) error {
 	var upstreamTargetPkg string
 
 	if c.Ref == "" {
-		gur, err := gitutil.NewGitUpstreamRepo(ctx, kptFile.UpstreamLock.Git.Repo)
-		if err != nil {
-			return err
-		}
-		c.Ref, err = gur.GetDefaultBranch(ctx)
-		if err != nil {
-			return err
+		if kptFile.Upstream.Oci != nil {
+			c.Ref = name.DefaultTag
+		} else {
+			gur, err := gitutil.NewGitUpstreamRepo(ctx, kptFile.UpstreamLock.Git.Repo)
+			if err != nil {
+				return err
+			}
+			c.Ref, err = gur.GetDefaultBranch(ctx)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -186,11 +194,13 @@ func (c *Command) Run(ctx context.Context) error {
 		// get the upstream pkg at the target version
 		upstreamTargetPkgName := NameStagingDirectory(TargetRemotePackageSource,
 			c.Ref)
+		targetUpstream, err := upstreamWithRef(*kptFile.Upstream, c.Ref)
+		if err != nil {
+			return err
+		}
 		upstreamTargetPkg, err = c.PkgGetter.GetPkg(ctx, stagingDirectory,
 			upstreamTargetPkgName,
-			kptFile.Upstream.Git.Repo,
-			kptFile.Upstream.Git.Directory,
-			c.Ref)
+			targetUpstream)
 		if err != nil {
 			return err
 		}
@@ -327,20 +337,19 @@ func (d *defaultPkgDiffer) prepareForDiff(dir string) error {
 	return nil
 }
 
-// PkgGetter knows how to fetch a package given a git repo, path and ref.
+// PkgGetter knows how to fetch a package given its upstream.
 type PkgGetter interface {
-	GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (dir string, err error)
+	GetPkg(ctx context.Context, stagingDir, targetDir string, upstream kptfilev1.Upstream) (dir string, err error)
 }
 
 // defaultPkgGetter uses fetch.Command abstraction to implement PkgGetter.
 type defaultPkgGetter struct{}
 
-// GetPkg checks out a repository into a temporary directory for diffing
-// and returns the directory containing the checked out package or an error.
-// repo is the git repository the package was cloned from.  e.g. https://
-// path is the sub directory of the git repository that the package was cloned from
-// ref is the git ref the package was cloned from
-func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, repo, path, ref string) (string, error) {
+// GetPkg checks out a repository or pulls an image into a temporary directory
+// for diffing and returns the directory containing the checked out package or
+// an error.
+// upstream is the git repository or OCI image the package is fetched from.
+func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir string, upstream kptfilev1.Upstream) (string, error) {
 	dir, err := stageDirectory(stagingDir, targetDir)
 	if err != nil {
 		return dir, err
@@ -349,12 +358,9 @@ func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, re
 	name := filepath.Base(dir)
 	kf := kptfileutil.DefaultKptfile(name)
 	kf.Upstream = &kptfilev1.Upstream{
-		Type: kptfilev1.GitOrigin,
-		Git: &kptfilev1.Git{
-			Repo:      repo,
-			Directory: path,
-			Ref:       ref,
-		},
+		Type: upstream.Type,
+		Git:  upstream.Git,
+		Oci:  upstream.Oci,
 	}
 	err = kptfileutil.WriteFile(dir, kf)
 	if err != nil {
@@ -373,6 +379,32 @@ func (pg defaultPkgGetter) GetPkg(ctx context.Context, stagingDir, targetDir, re
 	return dir, err
 }
 
+// getUpstreamRef returns the version of the package referenced by upstream,
+// i.e. the git ref or the image tag or digest.
+func getUpstreamRef(upstream *kptfilev1.Upstream) (string, error) {
+	if upstream.Oci != nil {
+		return ociutil.ImageRef(upstream.Oci.Image)
+	}
+	return upstream.Git.Ref, nil
+}
+
+// upstreamWithRef returns a copy of upstream that references the provided
+// version of the package.
+func upstreamWithRef(upstream kptfilev1.Upstream, ref string) (kptfilev1.Upstream, error) {
+	if upstream.Oci != nil {
+		image, err := ociutil.ImageWithRef(upstream.Oci.Image, ref)
+		if err != nil {
+			return upstream, err
+		}
+		upstream.Oci = &kptfilev1.Oci{Image: image}
+		return upstream, nil
+	}
+	g := *upstream.Git
+	g.Ref = ref
+	upstream.Git = &g
+	return upstream, nil
+}
+
 // stageDirectory creates a subdirectory of the provided path for temporary operations
 // path is the parent staged directory and should already exist
 // subpath is the subdirectory that should be created inside path
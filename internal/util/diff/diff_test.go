@@ -22,6 +22,7 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -30,6 +31,8 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
 	. "github.com/GoogleContainerTools/kpt/internal/util/diff"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -352,6 +355,55 @@ locally changed: foo
 	}
 }
 
+func TestCommand_DiffOci(t *testing.T) {
+	dataPath, err := testutil.GetTestDataPath()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	image := testutil.StartOciRegistry(t) + "/blueprints/pkg"
+	testutil.PushOciPackage(t, image+":v1", filepath.Join(dataPath, testutil.Dataset2))
+	testutil.PushOciPackage(t, image+":v2", filepath.Join(dataPath, testutil.Dataset3))
+
+	w, clean := testutil.SetupWorkspace(t)
+	defer clean()
+	localPath := filepath.Join(w.WorkspaceDirectory, "pkg")
+	if !assert.NoError(t, (get.Command{
+		Oci:         &kptfilev1.Oci{Image: image + ":v1"},
+		Destination: localPath,
+	}).Run(fake.CtxWithDefaultPrinter())) {
+		t.FailNow()
+	}
+
+	diffOutput := &bytes.Buffer{}
+	err = (&Command{
+		Path:         localPath,
+		Ref:          "v2",
+		DiffType:     TypeRemote,
+		DiffTool:     "diff",
+		DiffToolOpts: "-r -i -w",
+		Output:       diffOutput,
+	}).Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	expDiff := `
+41c41
+<             - containerPort: 80
+---
+>             - containerPort: 8081
+27,29c27,29
+<     - name: "80"
+<       port: 80
+<       targetPort: 80
+---
+>     - name: "8081"
+>       port: 8081
+>       targetPort: 8081
+`
+	assert.Equal(t, strings.TrimSpace(expDiff)+"\n", filterDiffMetadata(diffOutput))
+}
+
 func TestCommand_InvalidRef(t *testing.T) {
 	reposChanges := map[string][]testutil.Content{
 		testutil.Upstream: {
@@ -0,0 +1,294 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expr contains libraries for evaluating Common Expression Language
+// (CEL) expressions against KRM resources, see https://github.com/google/cel-spec.
+//
+// The package also parses field paths, which select fields of resources
+// without evaluating an expression, see Path.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// costLimit bounds the evaluation of an expression, so expressions in
+// untrusted packages can't make render run forever.
+const costLimit = 1000000
+
+var (
+	envOnce sync.Once
+	env     *cel.Env
+	envErr  error
+)
+
+// celEnv returns the environment expressions are parsed in. The variables
+// of expressions are not declared, so expressions are parsed but not type
+// checked, and referencing a variable that is not set fails the evaluation.
+func celEnv() (*cel.Env, error) {
+	envOnce.Do(func() {
+		env, envErr = cel.NewEnv(cel.CrossTypeNumericComparisons(true))
+	})
+	return env, envErr
+}
+
+// Expr is a parsed expression.
+type Expr struct {
+	src string
+	prg cel.Program
+	// fields are the paths of the fields selected by the expression, see
+	// fieldPaths.
+	fields [][]string
+}
+
+// Parse parses the expression src.
+func Parse(src string) (*Expr, error) {
+	env, err := celEnv()
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Parse(src)
+	if issues != nil && len(issues.Errors()) > 0 {
+		e := issues.Errors()[0]
+		return nil, fmt.Errorf("invalid expression %q: %s at position %d", src, e.Message, e.Location.Column())
+	}
+	prg, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", src, err)
+	}
+	return &Expr{src: src, prg: prg, fields: fieldPaths(ast.Expr())}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// EvalBool evaluates the expression with the given variables, and fails if
+// the result is not a boolean. Values of the variables must be as decoded
+// from YAML, see ResourceValue.
+func (e *Expr) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, _, err := e.prg.Eval(vars)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate %q: %w", e.src, err)
+	}
+	return e.toBool(v)
+}
+
+// EvalResource evaluates the expression against the resource, and fails if
+// the result is not a boolean. The fields of the resource are available as
+// variables, e.g. spec.replicas > 1, and the whole resource is available as
+// the `resource` variable.
+//
+// Selecting a field the resource doesn't have is an error in CEL. As
+// expressions are evaluated against resources of any kind, the expression
+// evaluates to false instead if it fails and selects a field the resource
+// doesn't have. The fields of the variables of macros, e.g. c in
+// `spec.containers.exists(c, c.image == 'nginx')`, are not checked: has()
+// must be used if they may be missing.
+func (e *Expr) EvalResource(resource interface{}) (bool, error) {
+	vars := map[string]interface{}{}
+	if m, ok := resource.(map[string]interface{}); ok {
+		for k, v := range m {
+			vars[k] = v
+		}
+	}
+	vars["resource"] = resource
+	v, _, err := e.prg.Eval(vars)
+	if err != nil {
+		if missingField(vars, e.fields) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to evaluate %q: %w", e.src, err)
+	}
+	return e.toBool(v)
+}
+
+// missingField returns true if a field of the paths is not set in vars.
+// Fields of values which are not maps, e.g. the elements of lists, are
+// considered set.
+func missingField(vars map[string]interface{}, paths [][]string) bool {
+	for _, path := range paths {
+		var v interface{} = vars
+		for _, key := range path {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if v, ok = m[key]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldPaths returns the paths of the fields selected by the expression,
+// e.g. [spec replicas] for `spec.replicas > 1`, or [metadata labels app]
+// for `metadata.labels['app'] == 'nginx'`. The fields of the variables of
+// macros are ignored.
+func fieldPaths(e *exprpb.Expr) [][]string {
+	var paths [][]string
+	var walk func(e *exprpb.Expr, bound map[string]bool)
+	walk = func(e *exprpb.Expr, bound map[string]bool) {
+		if e == nil {
+			return
+		}
+		if path, ok := selectPath(e, bound); ok {
+			paths = append(paths, path)
+			return
+		}
+		switch k := e.ExprKind.(type) {
+		case *exprpb.Expr_SelectExpr:
+			walk(k.SelectExpr.Operand, bound)
+		case *exprpb.Expr_CallExpr:
+			walk(k.CallExpr.Target, bound)
+			for _, arg := range k.CallExpr.Args {
+				walk(arg, bound)
+			}
+		case *exprpb.Expr_ListExpr:
+			for _, elem := range k.ListExpr.Elements {
+				walk(elem, bound)
+			}
+		case *exprpb.Expr_StructExpr:
+			for _, entry := range k.StructExpr.Entries {
+				walk(entry.GetMapKey(), bound)
+				walk(entry.Value, bound)
+			}
+		case *exprpb.Expr_ComprehensionExpr:
+			c := k.ComprehensionExpr
+			walk(c.IterRange, bound)
+			walk(c.AccuInit, bound)
+			inner := map[string]bool{c.IterVar: true, c.AccuVar: true}
+			for v := range bound {
+				inner[v] = true
+			}
+			walk(c.LoopCondition, inner)
+			walk(c.LoopStep, inner)
+			walk(c.Result, inner)
+		}
+	}
+	walk(e, map[string]bool{})
+	return paths
+}
+
+// selectPath returns the path of the field selected by e, if e only selects
+// fields of a variable, with the . operator or with a constant string index.
+func selectPath(e *exprpb.Expr, bound map[string]bool) ([]string, bool) {
+	switch k := e.ExprKind.(type) {
+	case *exprpb.Expr_IdentExpr:
+		if bound[k.IdentExpr.Name] {
+			return nil, false
+		}
+		return []string{k.IdentExpr.Name}, true
+	case *exprpb.Expr_SelectExpr:
+		if k.SelectExpr.TestOnly {
+			return nil, false
+		}
+		if path, ok := selectPath(k.SelectExpr.Operand, bound); ok {
+			return append(path, k.SelectExpr.Field), true
+		}
+	case *exprpb.Expr_CallExpr:
+		call := k.CallExpr
+		if call.Function != operators.Index || len(call.Args) != 2 {
+			return nil, false
+		}
+		key, ok := call.Args[1].ExprKind.(*exprpb.Expr_ConstExpr)
+		if !ok {
+			return nil, false
+		}
+		if _, ok := key.ConstExpr.ConstantKind.(*exprpb.Constant_StringValue); !ok {
+			return nil, false
+		}
+		if path, ok := selectPath(call.Args[0], bound); ok {
+			return append(path, key.ConstExpr.GetStringValue()), true
+		}
+	}
+	return nil, false
+}
+
+func (e *Expr) toBool(v ref.Val) (bool, error) {
+	b, ok := v.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q must evaluate to a bool, got %s", e.src, v.Type().TypeName())
+	}
+	return bool(b), nil
+}
+
+// ResourceValue returns the value of the resource, which can be used as a
+// variable of expressions.
+func ResourceValue(r *yaml.RNode) (interface{}, error) {
+	var v interface{}
+	if err := r.YNode().Decode(&v); err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+// ResourceValues returns the values of the resources as a list.
+func ResourceValues(resources []*yaml.RNode) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(resources))
+	for _, r := range resources {
+		v, err := ResourceValue(r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// normalize converts the numbers and maps decoded from YAML to the types
+// expressions are evaluated with.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return float64(v)
+		}
+		return int64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalize(v[k])
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	}
+	return v
+}
@@ -0,0 +1,357 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+const resources = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+data:
+  env: prod
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  labels:
+    app: nginx
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+      - name: sidecar
+        image: gcr.io/proxy:v1
+`
+
+func TestEvalBool(t *testing.T) {
+	values := resourceValues(t, resources)
+	vars := map[string]interface{}{
+		"resources": values,
+		"r":         values[1],
+	}
+
+	testCases := map[string]struct {
+		expr      string
+		expected  bool
+		expectErr string
+	}{
+		"field selection": {
+			expr:     `r.metadata.name == 'nginx'`,
+			expected: true,
+		},
+		"index": {
+			expr:     `r.spec.template.spec.containers[1].image == 'gcr.io/proxy:v1'`,
+			expected: true,
+		},
+		"map index": {
+			expr:     `r.metadata.labels['app'] == 'nginx'`,
+			expected: true,
+		},
+		"arithmetic": {
+			expr:     `r.spec.replicas * 2 + 1 - 10 / 5 % 3 == 5`,
+			expected: true,
+		},
+		"mixed numbers": {
+			expr:     `r.spec.replicas < 3.5`,
+			expected: true,
+		},
+		"in list": {
+			expr:     `r.kind in ['Deployment', 'StatefulSet']`,
+			expected: true,
+		},
+		"in map": {
+			expr:     `'app' in r.metadata.labels`,
+			expected: true,
+		},
+		"has": {
+			expr:     `has(r.spec.replicas) && !has(r.spec.paused)`,
+			expected: true,
+		},
+		"string functions": {
+			expr:     `r.metadata.name.startsWith('ng') && r.kind.matches('^D.*t$') && size(r.metadata.name) == 5`,
+			expected: true,
+		},
+		"exists": {
+			expr:     `resources.exists(x, x.kind == 'ConfigMap' && x.metadata.name == 'env' && x.data.env == 'prod')`,
+			expected: true,
+		},
+		"exists with no match": {
+			expr:     `resources.exists(x, x.kind == 'Service')`,
+			expected: false,
+		},
+		"all": {
+			expr:     `r.spec.template.spec.containers.all(c, c.image.contains(':'))`,
+			expected: true,
+		},
+		"filter and map": {
+			expr:     `r.spec.template.spec.containers.filter(c, c.name != 'nginx').map(c, c.image) == ['gcr.io/proxy:v1']`,
+			expected: true,
+		},
+		"missing field": {
+			expr:      `r.metadata.namespace == 'default'`,
+			expectErr: `no such key: namespace`,
+		},
+		"undeclared reference": {
+			expr:      `foo.bar`,
+			expectErr: `no such attribute`,
+		},
+		"invalid operands": {
+			expr:      `r.kind - 1 == 0`,
+			expectErr: `no such overload`,
+		},
+		"not a bool": {
+			expr:      `size(resources)`,
+			expectErr: "must evaluate to a bool, got int",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			e, err := Parse(tc.expr)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			v, err := e.EvalBool(vars)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	testCases := map[string]struct {
+		expr      string
+		expectErr string
+	}{
+		"unterminated string": {
+			expr:      `r.kind == 'foo`,
+			expectErr: `invalid expression "r.kind == 'foo": Syntax error: token recognition error at: ''foo' at position 10`,
+		},
+		"missing parenthesis": {
+			expr:      `(r.kind == 'foo'`,
+			expectErr: `Syntax error: missing ')' at '<EOF>'`,
+		},
+		"macro without variable": {
+			expr:      `resources.exists('x', true)`,
+			expectErr: `argument must be a simple name`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			_, err := Parse(tc.expr)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expectErr)
+			}
+		})
+	}
+}
+
+func resourceValues(t *testing.T, s string) []interface{} {
+	nodes, err := kio.FromBytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := ResourceValues(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestEvalResource(t *testing.T) {
+	values := resourceValues(t, resources)
+
+	testCases := map[string]struct {
+		expr      string
+		resource  interface{}
+		expected  bool
+		expectErr string
+	}{
+		"fields are variables": {
+			expr:     `kind == 'Deployment' && spec.replicas > 1`,
+			resource: values[1],
+			expected: true,
+		},
+		"missing fields don't match": {
+			expr:     `spec.replicas > 1`,
+			resource: values[0],
+			expected: false,
+		},
+		"missing keys don't match": {
+			expr:     `metadata.labels.app == 'nginx'`,
+			resource: values[0],
+			expected: false,
+		},
+		"missing index keys don't match": {
+			expr:     `metadata.labels['app'] == 'nginx'`,
+			resource: values[0],
+			expected: false,
+		},
+		"missing fields in a list literal don't match": {
+			expr:     `[spec.replicas, 1].exists(r, r > 2)`,
+			resource: values[0],
+			expected: false,
+		},
+		"errors with the selected fields set are reported": {
+			expr:      `spec.replicas / 0 > 1`,
+			resource:  values[1],
+			expectErr: "division by zero",
+		},
+		"missing fields of macro variables are reported": {
+			expr:      `spec.template.spec.containers.exists(c, c.ports.size() > 0)`,
+			resource:  values[1],
+			expectErr: "ports",
+		},
+		"resource variable": {
+			expr:     `has(resource.data) && resource.data.env == 'prod'`,
+			resource: values[0],
+			expected: true,
+		},
+		"macro variables": {
+			expr:     `spec.template.spec.containers.exists(c, c.image.startsWith('gcr.io/'))`,
+			resource: values[1],
+			expected: true,
+		},
+		"not a bool": {
+			expr:      `metadata.name`,
+			resource:  values[1],
+			expectErr: "must evaluate to a bool, got string",
+		},
+		"invalid operands": {
+			expr:      `metadata.name - 1 == 0`,
+			resource:  values[1],
+			expectErr: "no such overload",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			e, err := Parse(tc.expr)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			match, err := e.EvalResource(tc.resource)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, match)
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	deployment := resourceValues(t, resources)[1]
+
+	testCases := map[string]struct {
+		path      string
+		expected  []interface{}
+		expectErr string
+	}{
+		"field": {
+			path:     `metadata.name`,
+			expected: []interface{}{"nginx"},
+		},
+		"missing field": {
+			path:     `metadata.namespace`,
+			expected: nil,
+		},
+		"list wildcard": {
+			path:     `spec.template.spec.containers[*].image`,
+			expected: []interface{}{"nginx:1.21", "gcr.io/proxy:v1"},
+		},
+		"map wildcard": {
+			path:     `metadata.labels[*]`,
+			expected: []interface{}{"nginx"},
+		},
+		"index": {
+			path:     `spec.template.spec.containers[1].name`,
+			expected: []interface{}{"sidecar"},
+		},
+		"index out of range": {
+			path:     `spec.template.spec.containers[2].name`,
+			expected: nil,
+		},
+		"quoted key": {
+			path:     `metadata.labels['app']`,
+			expected: []interface{}{"nginx"},
+		},
+		"empty": {
+			path:      ``,
+			expectErr: "field path must not be empty",
+		},
+		"missing bracket": {
+			path:      `spec.containers[*.image`,
+			expectErr: "missing ']'",
+		},
+		"invalid subscript": {
+			path:      `spec.containers[-1]`,
+			expectErr: "invalid subscript [-1]",
+		},
+		"trailing dot": {
+			path:      `spec.`,
+			expectErr: "must not end with '.'",
+		},
+		"empty field name": {
+			path:      `spec..replicas`,
+			expectErr: "expected field name at position 5",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			p, err := ParsePath(tc.path)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, p.Lookup(deployment))
+		})
+	}
+}
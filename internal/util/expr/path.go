@@ -0,0 +1,167 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Path is a parsed field path, e.g. spec.template.spec.containers[*].image.
+//
+// A path is a list of field names separated by '.'. A field name can be
+// followed by any number of subscripts:
+//   - [N] selects the Nth element of a list
+//   - ['key'] selects the key of a map, which can contain '.'
+//   - [*] selects all elements of a list or all values of a map
+type Path struct {
+	src      string
+	segments []pathSegment
+}
+
+// pathSegment is a field name or a subscript of a path.
+type pathSegment struct {
+	// key is the field name or map key.
+	key string
+	// index is the list index if isIndex is set.
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParsePath parses the field path p.
+func ParsePath(p string) (*Path, error) {
+	path := &Path{src: p}
+	if strings.TrimSpace(p) == "" {
+		return nil, fmt.Errorf("field path must not be empty")
+	}
+	rest := p
+	for rest != "" {
+		end := strings.IndexAny(rest, ".[")
+		if end == -1 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("invalid field path %q: expected field name at position %d", p, len(p)-len(rest))
+		}
+		path.segments = append(path.segments, pathSegment{key: rest[:end]})
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, "[") {
+			closing := subscriptEnd(rest)
+			if closing == -1 {
+				return nil, fmt.Errorf("invalid field path %q: missing ']'", p)
+			}
+			seg, err := parseSubscript(rest[1:closing])
+			if err != nil {
+				return nil, fmt.Errorf("invalid field path %q: %w", p, err)
+			}
+			path.segments = append(path.segments, seg)
+			rest = rest[closing+1:]
+		}
+		if rest == "" {
+			break
+		}
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid field path %q: expected '.' at position %d", p, len(p)-len(rest))
+		}
+		rest = rest[1:]
+		if rest == "" {
+			return nil, fmt.Errorf("invalid field path %q: must not end with '.'", p)
+		}
+	}
+	return path, nil
+}
+
+// subscriptEnd returns the index of the ']' that closes the subscript at the
+// start of s, skipping quoted keys.
+func subscriptEnd(s string) int {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		closing := strings.IndexByte(s[2:], s[1])
+		if closing == -1 {
+			return -1
+		}
+		i := closing + 3
+		if i >= len(s) || s[i] != ']' {
+			return -1
+		}
+		return i
+	}
+	return strings.IndexByte(s, ']')
+}
+
+func parseSubscript(s string) (pathSegment, error) {
+	switch {
+	case s == "*":
+		return pathSegment{wildcard: true}, nil
+	case len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]:
+		return pathSegment{key: s[1 : len(s)-1]}, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		return pathSegment{}, fmt.Errorf("invalid subscript [%s]", s)
+	}
+	return pathSegment{index: i, isIndex: true}, nil
+}
+
+// String returns the source of the path.
+func (p *Path) String() string {
+	return p.src
+}
+
+// Lookup returns the values at the path in v. It returns no values if the
+// path doesn't exist, and multiple values if the path contains wildcards.
+func (p *Path) Lookup(v interface{}) []interface{} {
+	values := []interface{}{v}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, v := range values {
+			next = append(next, seg.lookup(v)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func (seg pathSegment) lookup(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if seg.wildcard {
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, 0, len(v))
+			for _, k := range keys {
+				values = append(values, v[k])
+			}
+			return values
+		}
+		if f, found := v[seg.key]; found && !seg.isIndex {
+			return []interface{}{f}
+		}
+	case []interface{}:
+		if seg.wildcard {
+			return v
+		}
+		if seg.isIndex && seg.index < len(v) {
+			return []interface{}{v[seg.index]}
+		}
+	}
+	return nil
+}
@@ -55,6 +55,13 @@ func (c Command) Run(ctx context.Context) error {
 		return errors.E(op, c.Pkg.UniquePath, err)
 	}
 
+	if o := kf.Upstream.Oci; o != nil {
+		if err := NewPuller(o.Image).pullAndCopy(ctx, c.Pkg.UniquePath.String()); err != nil {
+			return errors.E(op, c.Pkg.UniquePath, err)
+		}
+		return nil
+	}
+
 	g := kf.Upstream.Git
 	repoSpec := &git.RepoSpec{
 		OrgRepo: g.Repo,
@@ -76,6 +83,13 @@ func (c Command) validate(kf *kptfilev1.KptFile) error {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile doesn't contain upstream information"))
 	}
 
+	if o := kf.Upstream.Oci; o != nil {
+		if len(o.Image) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+		}
+		return nil
+	}
+
 	if kf.Upstream.Git == nil {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile upstream doesn't have git information"))
 	}
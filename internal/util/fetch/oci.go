@@ -0,0 +1,126 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/ociutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+)
+
+// Puller pulls a package stored as an image in an OCI registry into a
+// temporary directory.
+type Puller struct {
+	// Image is the image to pull, referenced by tag or digest.
+	Image string
+
+	// Digest is the digest of the pulled image. It is set by Pull.
+	Digest string
+
+	// Dir is the directory the package is extracted to. It is set by Pull.
+	Dir string
+
+	// cacheDir is the directory used for caching images.
+	cacheDir string
+}
+
+type NewPullerOption func(*Puller)
+
+func WithCacheDir(dir string) NewPullerOption {
+	return func(p *Puller) {
+		p.cacheDir = dir
+	}
+}
+
+func NewPuller(image string, opts ...NewPullerOption) *Puller {
+	p := &Puller{
+		Image: image,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.cacheDir == "" {
+		p.cacheDir = ociutil.DefaultCacheDir()
+	}
+	return p
+}
+
+// AbsPath returns the absolute path to the directory the package was
+// extracted to.
+func (p *Puller) AbsPath() string {
+	return p.Dir
+}
+
+// Pull resolves the digest of the image and extracts the package into a
+// new temporary directory. It is the responsibility of the caller to
+// remove the directory.
+func (p *Puller) Pull(ctx context.Context) error {
+	const op errors.Op = "fetch.Pull"
+	client, err := ociutil.NewClient(p.cacheDir)
+	if err != nil {
+		return errors.E(op, errors.OCI, err)
+	}
+
+	digestName, err := client.ResolveImage(ctx, p.Image)
+	if err != nil {
+		return errors.E(op, errors.OCI, err)
+	}
+
+	dir, err := os.MkdirTemp("", "kpt-get-")
+	if err != nil {
+		return errors.E(op, errors.IO, fmt.Errorf("error creating temp directory: %w", err))
+	}
+	if err := client.PullPackage(ctx, *digestName, dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return errors.E(op, errors.OCI, err)
+	}
+	p.Digest = digestName.Digest
+	p.Dir = dir
+	return nil
+}
+
+// pullAndCopy pulls the image and copies the package into the directory
+// specified by dest.
+func (p *Puller) pullAndCopy(ctx context.Context, dest string) error {
+	const op errors.Op = "fetch.pullAndCopy"
+	pr := printer.FromContextOrDie(ctx)
+
+	if err := p.Pull(ctx); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+	defer os.RemoveAll(p.Dir)
+
+	pr.Printf("Adding package %q.\n", p.Image)
+	if err := pkgutil.CopyPackage(p.Dir, dest, true, pkg.All); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateKptfileWithoutOrigin(dest, p.Dir, false); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateUpstreamLockFromOci(dest, p.Image, p.Digest); err != nil {
+		return errors.E(op, errors.OCI, types.UniquePath(dest), err)
+	}
+	return nil
+}
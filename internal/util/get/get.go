@@ -33,6 +33,7 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/attribution"
 	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
 	"github.com/GoogleContainerTools/kpt/internal/util/pathutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/stack"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
@@ -40,12 +41,16 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/kio"
 )
 
-// Command fetches a package from a git repository, copies it to a local
-// directory, and expands any remote subpackages.
+// Command fetches a package from a git repository or an OCI registry, copies
+// it to a local directory, and expands any remote subpackages.
 type Command struct {
 	// Git contains information about the git repo to fetch
 	Git *kptfilev1.Git
 
+	// Oci contains information about the package image to fetch. Only one
+	// of Git and Oci can be set.
+	Oci *kptfilev1.Oci
+
 	// Destination is the output directory to clone the package to.  Defaults to the name of the package --
 	// either the base repo name, or the base subdirectory name.
 	Destination string
@@ -79,20 +84,28 @@ func (c Command) Run(ctx context.Context) error {
 		return errors.E(op, errors.IO, types.UniquePath(c.Destination), err)
 	}
 
-	// normalize path to a filepath
-	repoDir := c.Git.Directory
-	if !strings.HasSuffix(repoDir, "file://") {
-		// Convert from separator to slash and back.
-		// This ensures all separators are compatible with the local OS.
-		repoDir = filepath.FromSlash(filepath.ToSlash(repoDir))
-	}
-	c.Git.Directory = repoDir
-
 	kf := kptfileutil.DefaultKptfile(c.Name)
-	kf.Upstream = &kptfilev1.Upstream{
-		Type:           kptfilev1.GitOrigin,
-		Git:            c.Git,
-		UpdateStrategy: c.UpdateStrategy,
+	if c.Oci != nil {
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.OciOrigin,
+			Oci:            c.Oci,
+			UpdateStrategy: c.UpdateStrategy,
+		}
+	} else {
+		// normalize path to a filepath
+		repoDir := c.Git.Directory
+		if !strings.HasSuffix(repoDir, "file://") {
+			// Convert from separator to slash and back.
+			// This ensures all separators are compatible with the local OS.
+			repoDir = filepath.FromSlash(filepath.ToSlash(repoDir))
+		}
+		c.Git.Directory = repoDir
+
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.GitOrigin,
+			Git:            c.Git,
+			UpdateStrategy: c.UpdateStrategy,
+		}
 	}
 
 	err = kptfileutil.WriteFile(c.Destination, kf)
@@ -166,7 +179,11 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 		if kf.Upstream != nil && kf.UpstreamLock == nil {
 			packageCount++
 			pr.PrintPackage(p, !(p == rootPkg))
-			pr.Printf("Fetching %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+			if kf.Upstream.Oci != nil {
+				pr.Printf("Fetching %s\n", kf.Upstream.Oci.Image)
+			} else {
+				pr.Printf("Fetching %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+			}
 			err := (&fetch.Command{
 				Pkg: p,
 			}).Run(ctx)
@@ -175,6 +192,12 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 			}
 		}
 
+		// remote subpackages declared in the Kptfile are fetched
+		// like any other unfetched remote subpackage.
+		if _, err := pkgutil.InitDeclaredSubpackages(p.UniquePath.String()); err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+
 		subPkgs, err := p.DirectSubpackages()
 		if err != nil {
 			return errors.E(op, p.UniquePath, err)
@@ -190,22 +213,30 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 // DefaultValues sets values to the default values if they were unspecified
 func (c *Command) DefaultValues() error {
 	const op errors.Op = "get.DefaultValues"
-	if c.Git == nil {
+	switch {
+	case c.Git != nil && c.Oci != nil:
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("only one of git repo and oci image information can be specified"))
+	case c.Oci != nil:
+		if len(c.Oci.Image) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+		}
+	case c.Git != nil:
+		g := c.Git
+		if len(g.Repo) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify repo"))
+		}
+		if len(g.Ref) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify ref"))
+		}
+		if len(g.Directory) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
+		}
+	default:
 		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify git repo information"))
 	}
-	g := c.Git
-	if len(g.Repo) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify repo"))
-	}
-	if len(g.Ref) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify ref"))
-	}
 	if len(c.Destination) == 0 {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify destination"))
 	}
-	if len(g.Directory) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
-	}
 
 	if !filepath.IsAbs(c.Destination) {
 		return errors.E(op, errors.InvalidParam, fmt.Errorf("destination must be an absolute path"))
@@ -20,12 +20,14 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
 	. "github.com/GoogleContainerTools/kpt/internal/util/get"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/kio/filters"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -128,6 +130,58 @@ func TestCommand_Run(t *testing.T) {
 	})
 }
 
+// TestCommand_Run_oci verifies that Command will pull a package from an OCI
+// registry.
+//
+// - KptFile should be populated with values pointing to the image
+func TestCommand_Run_oci(t *testing.T) {
+	w, clean := testutil.SetupWorkspace(t)
+	defer clean()
+
+	dataPath, err := testutil.GetTestDataPath()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	src := filepath.Join(dataPath, testutil.Dataset1)
+	image := testutil.StartOciRegistry(t) + "/blueprints/dataset1:v1"
+	digest := testutil.PushOciPackage(t, image, src)
+
+	absPath := filepath.Join(w.WorkspaceDirectory, "dataset1")
+	err = Command{
+		Oci:         &kptfilev1.Oci{Image: image},
+		Destination: absPath,
+	}.Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// verify the pulled contents matches the image
+	diff, err := testutil.Diff(src, absPath, true)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, diff.Difference(testutil.KptfileSet).List())
+
+	// verify the KptFile contains the expected values
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, absPath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "dataset1", kf.Name)
+	assert.Equal(t, &kptfilev1.Upstream{
+		Type:           kptfilev1.OciOrigin,
+		Oci:            &kptfilev1.Oci{Image: image},
+		UpdateStrategy: kptfilev1.ResourceMerge,
+	}, kf.Upstream)
+	assert.Equal(t, &kptfilev1.UpstreamLock{
+		Type: kptfilev1.OciOrigin,
+		Oci: &kptfilev1.OciLock{
+			Image:  image,
+			Digest: digest,
+		},
+	}, kf.UpstreamLock)
+}
+
 // TestCommand_Run_subdir verifies that Command will clone a subdirectory of a repo.
 //
 // - destination dir should match the name of the subdirectory
@@ -1040,6 +1094,58 @@ func TestCommand_Run_subpackages(t *testing.T) {
 						WithResource(pkgbuilder.ConfigMapResource),
 				),
 		},
+		"package with declared remote subpackage": {
+			directory: "/",
+			ref:       "master",
+			reposContent: map[string][]testutil.Content{
+				testutil.Upstream: {
+					{
+						Branch: "master",
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(
+								pkgbuilder.NewKptfile().
+									WithSubpackages(pkgbuilder.RemoteSubpackage{
+										RepoRef:   "foo",
+										Directory: "/",
+										Ref:       "main",
+										LocalDir:  "foo",
+									}),
+							).
+							WithResource(pkgbuilder.DeploymentResource),
+					},
+				},
+				"foo": {
+					{
+						Branch: "main",
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile().
+							WithResource(pkgbuilder.SecretResource),
+					},
+				},
+			},
+			expectedResult: pkgbuilder.NewRootPkg().
+				WithKptfile(
+					pkgbuilder.NewKptfile().
+						WithUpstreamRef("upstream", "/", "master", "resource-merge").
+						WithUpstreamLockRef("upstream", "/", "master", 0).
+						WithSubpackages(pkgbuilder.RemoteSubpackage{
+							RepoRef:   "foo",
+							Directory: "/",
+							Ref:       "main",
+							LocalDir:  "foo",
+						}),
+				).
+				WithResource(pkgbuilder.DeploymentResource).
+				WithSubPackages(
+					pkgbuilder.NewSubPkg("foo").
+						WithKptfile(
+							pkgbuilder.NewKptfile().
+								WithUpstreamRef("foo", "/", "main", "resource-merge").
+								WithUpstreamLockRef("foo", "/", "main", 0),
+						).
+						WithResource(pkgbuilder.SecretResource),
+				),
+		},
 		"package with deeply nested subpackages": {
 			directory: "/",
 			ref:       "master",
@@ -0,0 +1,401 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// ConflictAnnotation is the annotation recording the conflicting fields
+	// of a resource, when the conflicts are annotated. It is removed by
+	// `kpt pkg resolve`.
+	ConflictAnnotation = "kpt.dev/merge-conflicts"
+
+	// ConflictMarkerLocal, ConflictMarkerSep and ConflictMarkerUpstream are
+	// the lines surrounding the local and upstream versions of the lines of
+	// a non KRM file changed both locally and in upstream, when the
+	// conflicts are annotated.
+	ConflictMarkerLocal    = "<<<<<<< local"
+	ConflictMarkerSep      = "======="
+	ConflictMarkerUpstream = ">>>>>>> upstream"
+)
+
+// ConflictMode controls how the fields changed both locally and in upstream
+// to different values are handled by the merge.
+type ConflictMode string
+
+const (
+	// ConflictUseUpstream sets the conflicting fields to their upstream
+	// values.
+	ConflictUseUpstream ConflictMode = "use-upstream"
+	// ConflictFail fails the merge with a ConflictError.
+	ConflictFail ConflictMode = "fail"
+	// ConflictAnnotate sets the conflicting fields to their upstream values
+	// and records them, with their local values, in the ConflictAnnotation
+	// of their resource. The conflicting lines of non KRM files are kept
+	// from both versions, between conflict markers. Without it, non KRM
+	// files with conflicting changes are left unchanged.
+	ConflictAnnotate ConflictMode = "annotate"
+)
+
+// ConflictModesAsStrings returns the conflict modes as strings.
+func ConflictModesAsStrings() []string {
+	return []string{string(ConflictUseUpstream), string(ConflictFail), string(ConflictAnnotate)}
+}
+
+// FieldConflict is a field changed both locally and in upstream to different
+// values. The values are empty if the field is not set.
+type FieldConflict struct {
+	// Field is the path of the field, e.g.
+	// spec.template.spec.containers[name=nginx].image.
+	Field    string `yaml:"field"`
+	Origin   string `yaml:"origin,omitempty"`
+	Local    string `yaml:"local,omitempty"`
+	Upstream string `yaml:"upstream,omitempty"`
+}
+
+// Conflict are the conflicting fields of a resource, or the conflicting
+// lines of a non KRM file.
+type Conflict struct {
+	// File is the path of the file of the resource in the package.
+	File string
+	// Resource identifies the resource, e.g. apps/v1/Deployment/nginx. It
+	// is empty for a non KRM file.
+	Resource string
+	Fields   []FieldConflict
+}
+
+// ConflictError is returned by the merge when fields or lines of non KRM
+// files were changed both locally and in upstream to different values, with
+// ConflictFail.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+// Add adds the conflicts of err, a ConflictError of the subpackage at the
+// relative path subPkgPath, to e.
+func (e *ConflictError) Add(subPkgPath string, err *ConflictError) {
+	for _, c := range err.Conflicts {
+		c.File = filepath.Join(subPkgPath, c.File)
+		e.Conflicts = append(e.Conflicts, c)
+	}
+}
+
+func (e *ConflictError) Error() string {
+	var b strings.Builder
+	b.WriteString("fields or lines changed both locally and in upstream to different values:\n")
+	for _, c := range e.Conflicts {
+		if c.Resource == "" {
+			fmt.Fprintf(&b, "  %s: lines changed both locally and in upstream\n", c.File)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s (%s):\n", c.Resource, c.File)
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "    %s: local %q, upstream %q\n", f.Field, f.Local, f.Upstream)
+		}
+	}
+	fmt.Fprintf(&b, "use --on-conflict=%s to use the upstream values or --on-conflict=%s to annotate the conflicting resources",
+		ConflictUseUpstream, ConflictAnnotate)
+	return b.String()
+}
+
+// findConflicts returns the fields changed both in upstream and local from
+// origin, to different values.
+func findConflicts(origin, upstream, local *yaml.RNode) []FieldConflict {
+	originFields := FieldValues(origin)
+	upstreamFields := FieldValues(upstream)
+	localFields := FieldValues(local)
+
+	paths := map[string]bool{}
+	for _, fields := range []map[string]string{originFields, upstreamFields, localFields} {
+		for p := range fields {
+			paths[p] = true
+		}
+	}
+
+	var conflicts []FieldConflict
+	for p := range paths {
+		o, inOrigin := originFields[p]
+		u, inUpstream := upstreamFields[p]
+		l, inLocal := localFields[p]
+		upstreamChanged := inOrigin != inUpstream || o != u
+		localChanged := inOrigin != inLocal || o != l
+		if upstreamChanged && localChanged && (inUpstream != inLocal || u != l) {
+			conflicts = append(conflicts, FieldConflict{Field: p, Origin: o, Local: l, Upstream: u})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Field < conflicts[j].Field
+	})
+	return conflicts
+}
+
+// FieldValues flattens the fields of a resource into their values keyed by
+// path. The elements of the sequences with an associative key are keyed by
+// the value of their key, the other sequences are compared as a whole. The
+// annotations set by kpt and kyaml while reading and merging packages are
+// skipped.
+func FieldValues(node *yaml.RNode) map[string]string {
+	values := map[string]string{}
+	walkFields("", node.YNode(), values)
+	return values
+}
+
+func walkFields(path string, n *yaml.Node, values map[string]string) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			values[path] = "{}"
+			return
+		}
+		for i := 0; i < len(n.Content)-1; i += 2 {
+			key := n.Content[i].Value
+			if path == "metadata.annotations" && isMergeAnnotation(key) {
+				continue
+			}
+			walkFields(joinFieldPath(path, key), n.Content[i+1], values)
+		}
+	case yaml.SequenceNode:
+		if key := associativeKey(n); key != "" {
+			for _, e := range n.Content {
+				value := yaml.NewRNode(e).Field(key).Value.YNode().Value
+				walkFields(fmt.Sprintf("%s[%s=%s]", path, key, value), e, values)
+			}
+			return
+		}
+		s, err := yaml.NewRNode(n).String()
+		if err != nil {
+			s = fmt.Sprint(n.Content)
+		}
+		values[path] = strings.TrimSpace(s)
+	case yaml.AliasNode:
+		walkFields(path, n.Alias, values)
+	default:
+		values[path] = n.Value
+	}
+}
+
+// associativeKey returns the associative key of the sequence, or an empty
+// string if its elements are not all mappings with the same key.
+func associativeKey(n *yaml.Node) string {
+	if len(n.Content) == 0 {
+		return ""
+	}
+	for _, key := range yaml.AssociativeSequenceKeys {
+		found := true
+		for _, e := range n.Content {
+			if e.Kind != yaml.MappingNode || yaml.NewRNode(e).Field(key) == nil {
+				found = false
+				break
+			}
+		}
+		if found {
+			return key
+		}
+	}
+	return ""
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isMergeAnnotation returns true for the annotations set by kpt and kyaml
+// during the merge, which are not compared.
+func isMergeAnnotation(key string) bool {
+	return key == ConflictAnnotation || key == mergeSourceAnnotation ||
+		strings.HasPrefix(key, "config.kubernetes.io/") || strings.HasPrefix(key, "internal.config.kubernetes.io/")
+}
+
+// resourceID identifies a resource in the conflicts.
+func resourceID(node *yaml.RNode) string {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return ""
+	}
+	id := meta.APIVersion + "/" + meta.Kind + "/"
+	if meta.Namespace != "" {
+		id += meta.Namespace + "/"
+	}
+	return id + meta.Name
+}
+
+// setConflictAnnotation records the conflicting fields in the
+// ConflictAnnotation of node.
+func setConflictAnnotation(node *yaml.RNode, fields []FieldConflict) error {
+	b, err := yaml.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := node.PipeE(yaml.SetAnnotation(ConflictAnnotation, string(b))); err != nil {
+		return err
+	}
+	value, err := node.Pipe(yaml.Lookup(yaml.MetadataField, yaml.AnnotationsField, ConflictAnnotation))
+	if err != nil {
+		return err
+	}
+	value.YNode().Style = yaml.LiteralStyle
+	return nil
+}
+
+// ResolveConflicts removes the ConflictAnnotation of node. It returns the
+// conflicts that were recorded in it, or nil if it had none.
+func ResolveConflicts(node *yaml.RNode) (*Conflict, error) {
+	value := node.GetAnnotations()[ConflictAnnotation]
+	if value == "" {
+		return nil, nil
+	}
+	c := &Conflict{
+		File:     node.GetAnnotations()[kioutil.PathAnnotation],
+		Resource: resourceID(node),
+	}
+	if err := yaml.Unmarshal([]byte(value), &c.Fields); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation of %s: %w", ConflictAnnotation, c.Resource, err)
+	}
+	if err := node.PipeE(yaml.ClearAnnotation(ConflictAnnotation)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// PackageConflicts returns the conflicts annotated on the resources of the
+// package at path and its subpackages, and the files with conflict markers.
+func PackageConflicts(path string) ([]Conflict, error) {
+	nodes, err := conflictsReadWriter(path).Read()
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := resolveNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+	files, err := FilesWithConflictMarkers(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		conflicts = append(conflicts, Conflict{File: f})
+	}
+	return conflicts, nil
+}
+
+// ResolvePackage removes the ConflictAnnotation of the resources of the
+// package at path and its subpackages. It returns the conflicts that were
+// resolved. The package is not written if it has no conflicts. It fails
+// without writing the package if files still have conflict markers, as
+// they must be resolved by editing the files.
+func ResolvePackage(path string) ([]Conflict, error) {
+	files, err := FilesWithConflictMarkers(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 {
+		return nil, fmt.Errorf("the conflict markers of these files must be removed first: %s",
+			strings.Join(files, ", "))
+	}
+	rw := conflictsReadWriter(path)
+	nodes, err := rw.Read()
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := resolveNodes(nodes)
+	if err != nil || len(conflicts) == 0 {
+		return nil, err
+	}
+	return conflicts, rw.Write(nodes)
+}
+
+// FilesWithConflictMarkers returns the relative paths of the files of the
+// package at path and its subpackages with a line starting a conflict, see
+// ConflictMarkerLocal.
+func FilesWithConflictMarkers(path string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if !hasConflictMarker(b) {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+func hasConflictMarker(b []byte) bool {
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if string(bytes.TrimSuffix(line, []byte("\r"))) == ConflictMarkerLocal {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveNodes(nodes []*yaml.RNode) ([]Conflict, error) {
+	var conflicts []Conflict
+	for _, node := range nodes {
+		c, err := ResolveConflicts(node)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			conflicts = append(conflicts, *c)
+		}
+	}
+	return conflicts, nil
+}
+
+func conflictsReadWriter(path string) *kio.LocalPackageReadWriter {
+	return &kio.LocalPackageReadWriter{
+		PackagePath:        path,
+		IncludeSubpackages: true,
+		PackageFileName:    kptfilev1.KptFileName,
+		PreserveSeqIndent:  true,
+		WrapBareSeqNode:    true,
+	}
+}
@@ -48,6 +48,19 @@ type Merge3 struct {
 	MatchFilesGlob     []string
 	MergeOnPath        bool
 	IncludeSubPackages bool
+	// ConflictMode controls how the fields changed both locally and in
+	// upstream to different values are handled. It defaults to
+	// ConflictUseUpstream.
+	ConflictMode ConflictMode
+	// Filter merges the resources instead of the 3-way merge if set. It
+	// receives the resources of the three packages, with their package in
+	// the config.kubernetes.io/merge-source annotation (original, updated or
+	// dest), and returns the resources of the merged package.
+	Filter kio.Filter
+	// Conflicts are the conflicts found outside of the resources, e.g. in
+	// the non KRM files of the package. With ConflictFail, the merge fails
+	// with them and the conflicting fields before the package is written.
+	Conflicts []Conflict
 }
 
 func (m Merge3) Merge() error {
@@ -104,15 +117,28 @@ func (m Merge3) Merge() error {
 	})
 
 	rmMatcher := ResourceMergeMatcher{MergeOnPath: m.MergeOnPath}
-	resourceHandler := resourceHandler{}
+	resourceHandler := resourceHandler{conflictMode: m.ConflictMode}
 	kyamlMerge := filters.Merge3{
 		Matcher: &rmMatcher,
 		Handler: &resourceHandler,
 	}
 
+	// failOnConflicts fails the merge before the package is written.
+	failOnConflicts := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		conflicts := append(resourceHandler.conflicts, m.Conflicts...)
+		if m.ConflictMode == ConflictFail && len(conflicts) > 0 {
+			return nil, &ConflictError{Conflicts: conflicts}
+		}
+		return nodes, nil
+	})
+
+	mergeFilters := []kio.Filter{kyamlMerge, failOnConflicts}
+	if m.Filter != nil {
+		mergeFilters = []kio.Filter{m.Filter, failOnConflicts}
+	}
 	return kio.Pipeline{
 		Inputs:  inputs,
-		Filters: []kio.Filter{kyamlMerge},
+		Filters: mergeFilters,
 		Outputs: []kio.Writer{dest},
 	}.Execute()
 }
@@ -294,9 +320,13 @@ func metadataComment(node *yaml.RNode) string {
 // kyaml. It is used to decide how a resource should be handled during the
 // 3-way merge. This differs from the default implementation in that if a
 // resource is deleted from upstream, it will only be deleted from local if
-// there is no diff between origin and local.
+// there is no diff between origin and local. The merge policies of the
+// resources and their fields are honored, see MergePolicy. Merged resources
+// are checked for conflicts unless conflictMode is ConflictUseUpstream.
 type resourceHandler struct {
 	keptResources []*yaml.RNode
+	conflictMode  ConflictMode
+	conflicts     []Conflict
 }
 
 func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.ResourceMergeStrategy, error) {
@@ -311,8 +341,16 @@ func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.R
 	// Do not re-add the resource if deleted from both upstream and local
 	case upstream == nil && local == nil:
 		strategy = filters.Skip
-	// If deleted from upstream, only delete if local fork does not have changes.
+	// If deleted from upstream, only delete if local fork does not have
+	// changes, or if it is replaced by upstream.
 	case origin != nil && upstream == nil:
+		policy, err := resourceMergePolicy(nil, local)
+		if err != nil {
+			return strategy, err
+		}
+		if policy == MergePolicyReplace {
+			return filters.Skip, nil
+		}
 		equal, err := r.equals(origin, local)
 		if err != nil {
 			return strategy, err
@@ -327,11 +365,47 @@ func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.R
 	case origin != nil && local == nil:
 		strategy = filters.Skip
 	default:
+		policy, err := applyMergePolicies(origin, upstream, local)
+		if err != nil {
+			return strategy, err
+		}
+		if policy == MergePolicyReplace {
+			if err := setFileAnnotations(upstream, local); err != nil {
+				return strategy, err
+			}
+			return filters.KeepUpdated, nil
+		}
+		if err := r.checkConflicts(origin, upstream, local); err != nil {
+			return strategy, err
+		}
 		strategy = filters.Merge
 	}
 	return strategy, nil
 }
 
+// checkConflicts records the conflicting fields of a resource merged from
+// origin, upstream and local, and annotates local with them if the conflicts
+// are annotated. The merge sets the conflicting fields to their upstream
+// values.
+func (r *resourceHandler) checkConflicts(origin, upstream, local *yaml.RNode) error {
+	if r.conflictMode == "" || r.conflictMode == ConflictUseUpstream || origin == nil {
+		return nil
+	}
+	fields := findConflicts(origin, upstream, local)
+	if len(fields) == 0 {
+		return nil
+	}
+	r.conflicts = append(r.conflicts, Conflict{
+		File:     local.GetAnnotations()[kioutil.PathAnnotation],
+		Resource: resourceID(local),
+		Fields:   fields,
+	})
+	if r.conflictMode == ConflictAnnotate {
+		return setConflictAnnotation(local, fields)
+	}
+	return nil
+}
+
 func (*resourceHandler) equals(r1, r2 *yaml.RNode) (bool, error) {
 	// We need to create new copies of the resources since we need to
 	// mutate them before comparing them.
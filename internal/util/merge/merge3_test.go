@@ -633,3 +633,451 @@ spec:
 		})
 	}
 }
+
+func TestMerge3_conflicts(t *testing.T) {
+	origin := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.0
+      - name: sidecar
+        image: sidecar:1.0`
+	update := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:2.0`
+	local := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:1.0`
+
+	testCases := map[string]struct {
+		conflictMode merge.ConflictMode
+		expected     string
+		errMsg       string
+	}{
+		"use upstream": {
+			conflictMode: merge.ConflictUseUpstream,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:2.0`,
+		},
+		"fail": {
+			conflictMode: merge.ConflictFail,
+			errMsg: `  apps/v1/Deployment/nginx-deployment (f1.yaml):
+    spec.replicas: local "5", upstream "4"
+`,
+		},
+		"annotate": {
+			conflictMode: merge.ConflictAnnotate,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-conflicts: |
+      - field: spec.replicas
+        origin: "3"
+        local: "5"
+        upstream: "4"
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:2.0`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			for d, content := range map[string]string{"originalDir": origin, "updatedDir": update, "localDir": local} {
+				if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+					t.Fatal(err)
+				}
+				err := os.WriteFile(filepath.Join(dir, d, "f1.yaml"), []byte(strings.TrimSpace(content)), 0700)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := merge.Merge3{
+				OriginalPath: filepath.Join(dir, "originalDir"),
+				UpdatedPath:  filepath.Join(dir, "updatedDir"),
+				DestPath:     filepath.Join(dir, "localDir"),
+				MergeOnPath:  true,
+				ConflictMode: tc.conflictMode,
+			}.Merge()
+			b, readErr := os.ReadFile(filepath.Join(dir, "localDir", "f1.yaml"))
+			if !assert.NoError(t, readErr) {
+				t.FailNow()
+			}
+			if tc.errMsg != "" {
+				var conflictErr *merge.ConflictError
+				if assert.ErrorAs(t, err, &conflictErr) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				// the package is not modified
+				assert.Equal(t, strings.TrimSpace(local), string(b))
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, strings.TrimSpace(tc.expected), strings.TrimSpace(string(b)))
+		})
+	}
+}
+
+func TestMerge3_mergePolicies(t *testing.T) {
+	origin := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.0
+      - name: sidecar
+        image: sidecar:1.0`
+
+	testCases := map[string]struct {
+		update   string
+		local    string
+		expected string
+		errMsg   string
+	}{
+		"local-wins resource": {
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:2.0`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-policy: local-wins
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.5
+      - name: sidecar
+        image: sidecar:1.0`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-policy: local-wins
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.5
+      - name: sidecar
+        image: sidecar:2.0`,
+		},
+		"local-wins field declared in upstream": {
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 4 # kpt.dev/merge-policy: local-wins
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:1.0`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.5
+      - name: sidecar
+        image: sidecar:1.0`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:1.0`,
+		},
+		"upstream-wins field in local-wins resource": {
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-policy: local-wins
+spec:
+  replicas: 4
+  template:
+    spec:
+      containers:
+      - name: nginx # kpt.dev/merge-policy: upstream-wins
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:2.0`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.5
+      - name: sidecar
+        image: sidecar:1.5`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-policy: local-wins
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx # kpt.dev/merge-policy: upstream-wins
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:1.5`,
+		},
+		"replace list item": {
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx # kpt.dev/merge-policy: replace
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:1.0`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.0
+        args: [--debug]
+      - name: sidecar
+        image: sidecar:1.5`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx # kpt.dev/merge-policy: replace
+        image: nginx:2.0
+      - name: sidecar
+        image: sidecar:1.5`,
+		},
+		"replace resource": {
+			update: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-policy: replace
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0`,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.5
+      - name: sidecar
+        image: sidecar:1.0`,
+			expected: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    kpt.dev/merge-policy: replace
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:2.0`,
+		},
+		"invalid policy": {
+			update: origin,
+			local: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+spec:
+  replicas: 5 # kpt.dev/merge-policy: keep
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.0
+      - name: sidecar
+        image: sidecar:1.0`,
+			errMsg: `invalid merge policy of field spec.replicas: unknown merge policy "keep"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			for d, content := range map[string]string{"originalDir": origin, "updatedDir": tc.update, "localDir": tc.local} {
+				if err := os.MkdirAll(filepath.Join(dir, d), 0700); err != nil {
+					t.Fatal(err)
+				}
+				err := os.WriteFile(filepath.Join(dir, d, "f1.yaml"), []byte(strings.TrimSpace(content)), 0700)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := merge.Merge3{
+				OriginalPath: filepath.Join(dir, "originalDir"),
+				UpdatedPath:  filepath.Join(dir, "updatedDir"),
+				DestPath:     filepath.Join(dir, "localDir"),
+				MergeOnPath:  true,
+			}.Merge()
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b, err := os.ReadFile(filepath.Join(dir, "localDir", "f1.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, strings.TrimSpace(tc.expected), strings.TrimSpace(string(b)))
+		})
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// MergePolicyAnnotation declares the merge policy of a resource. The
+	// merge policy of a field or a list item is declared with a line comment
+	// with the same key, e.g.
+	//
+	//	replicas: 3 # kpt.dev/merge-policy: local-wins
+	//
+	// The policies declared in the local package take precedence over the
+	// ones declared in upstream.
+	MergePolicyAnnotation = "kpt.dev/merge-policy"
+)
+
+// MergePolicy controls how a resource, field or list item changed both
+// locally and in upstream is merged. The policy of a resource or field
+// applies to its fields, unless they declare their own.
+type MergePolicy string
+
+const (
+	// MergePolicyUpstreamWins sets the fields changed both locally and in
+	// upstream to their upstream values. This is the default.
+	MergePolicyUpstreamWins MergePolicy = "upstream-wins"
+	// MergePolicyLocalWins keeps the local values of the fields changed
+	// both locally and in upstream.
+	MergePolicyLocalWins MergePolicy = "local-wins"
+	// MergePolicyReplace replaces the local value with the upstream one,
+	// discarding the local changes.
+	MergePolicyReplace MergePolicy = "replace"
+)
+
+// MergePoliciesAsStrings returns the merge policies as strings.
+func MergePoliciesAsStrings() []string {
+	return []string{string(MergePolicyUpstreamWins), string(MergePolicyLocalWins), string(MergePolicyReplace)}
+}
+
+func toMergePolicy(s string) (MergePolicy, error) {
+	switch p := MergePolicy(s); p {
+	case MergePolicyUpstreamWins, MergePolicyLocalWins, MergePolicyReplace:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown merge policy %q, must be one of %s", s,
+			strings.Join(MergePoliciesAsStrings(), ","))
+	}
+}
+
+// resourceMergePolicy returns the merge policy declared by the
+// MergePolicyAnnotation of local, or else of upstream. Either may be nil.
+func resourceMergePolicy(upstream, local *yaml.RNode) (MergePolicy, error) {
+	for _, node := range []*yaml.RNode{local, upstream} {
+		if node == nil {
+			continue
+		}
+		if value, found := node.GetAnnotations()[MergePolicyAnnotation]; found {
+			p, err := toMergePolicy(value)
+			if err != nil {
+				return "", fmt.Errorf("invalid %s annotation of %s: %w", MergePolicyAnnotation, resourceID(node), err)
+			}
+			return p, nil
+		}
+	}
+	return "", nil
+}
+
+// commentMergePolicy returns the merge policy declared by the line comments
+// of the nodes of a field or list item. The nodes are in order of precedence
+// and may be nil.
+func commentMergePolicy(nodes ...*yaml.Node) (MergePolicy, error) {
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(n.LineComment, "#"))
+		if !strings.HasPrefix(comment, MergePolicyAnnotation+":") {
+			continue
+		}
+		return toMergePolicy(strings.TrimSpace(strings.TrimPrefix(comment, MergePolicyAnnotation+":")))
+	}
+	return "", nil
+}
+
+// applyMergePolicies prepares upstream and local for the 3-way merge so that
+// it honors the merge policies of the resource and of its fields: the local
+// values kept with local-wins are copied to upstream, and the upstream values
+// replacing the local ones with replace are copied to local. It returns the
+// policy of the resource.
+func applyMergePolicies(origin, upstream, local *yaml.RNode) (MergePolicy, error) {
+	policy, err := resourceMergePolicy(upstream, local)
+	if err != nil {
+		return "", err
+	}
+	if policy == MergePolicyReplace {
+		return policy, nil
+	}
+	var o *yaml.Node
+	if origin != nil {
+		o = origin.YNode()
+	}
+	return policy, applyMappingPolicies("", policy, o, upstream.YNode(), local.YNode())
+}
+
+// applyMappingPolicies applies the merge policies to the fields of the
+// mappings upstream and local. origin may be nil.
+func applyMappingPolicies(path string, policy MergePolicy, origin, upstream, local *yaml.Node) error {
+	var keys []string
+	seen := map[string]bool{}
+	for _, n := range []*yaml.Node{local, upstream, origin} {
+		if n == nil || n.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i < len(n.Content)-1; i += 2 {
+			if key := n.Content[i].Value; !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		if path == "metadata.annotations" && isMergeAnnotation(key) {
+			continue
+		}
+		fieldPath := joinFieldPath(path, key)
+		lKey, lValue := mappingField(local, key)
+		uKey, uValue := mappingField(upstream, key)
+		_, oValue := mappingField(origin, key)
+		p, err := commentMergePolicy(lKey, lValue, uKey, uValue)
+		if err != nil {
+			return fmt.Errorf("invalid merge policy of field %s: %w", fieldPath, err)
+		}
+		if p == "" {
+			p = policy
+		}
+
+		switch {
+		case p == MergePolicyReplace:
+			setMappingField(local, uKey, uValue, key)
+		case uValue != nil && lValue != nil && uValue.Kind == yaml.MappingNode && lValue.Kind == yaml.MappingNode:
+			if err := applyMappingPolicies(fieldPath, p, mappingValue(oValue), uValue, lValue); err != nil {
+				return err
+			}
+		case uValue != nil && lValue != nil && uValue.Kind == yaml.SequenceNode && lValue.Kind == yaml.SequenceNode &&
+			associativeKey(lValue) != "" && associativeKey(lValue) == associativeKey(uValue):
+			if err := applySequencePolicies(fieldPath, p, oValue, uValue, lValue); err != nil {
+				return err
+			}
+		case p == MergePolicyLocalWins && !nodesEqual(oValue, lValue):
+			setMappingField(upstream, lKey, lValue, key)
+		}
+	}
+	return nil
+}
+
+// applySequencePolicies applies the merge policies to the elements of the
+// associative sequences upstream and local. origin may be nil.
+func applySequencePolicies(path string, policy MergePolicy, origin, upstream, local *yaml.Node) error {
+	key := associativeKey(local)
+	if origin != nil && (origin.Kind != yaml.SequenceNode || associativeKey(origin) != key) {
+		origin = nil
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, n := range []*yaml.Node{local, upstream, origin} {
+		if n == nil {
+			continue
+		}
+		for _, e := range n.Content {
+			if id := elementID(e, key); !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		elementPath := fmt.Sprintf("%s[%s=%s]", path, key, id)
+		l, u, o := sequenceElement(local, key, id), sequenceElement(upstream, key, id), sequenceElement(origin, key, id)
+		_, lKeyValue := mappingField(l, key)
+		_, uKeyValue := mappingField(u, key)
+		p, err := commentMergePolicy(lKeyValue, uKeyValue)
+		if err != nil {
+			return fmt.Errorf("invalid merge policy of list item %s: %w", elementPath, err)
+		}
+		if p == "" {
+			p = policy
+		}
+
+		switch {
+		case p == MergePolicyReplace:
+			setSequenceElement(local, key, id, u)
+		case u != nil && l != nil:
+			if err := applyMappingPolicies(elementPath, p, o, u, l); err != nil {
+				return err
+			}
+		case p == MergePolicyLocalWins && !nodesEqual(o, l):
+			setSequenceElement(upstream, key, id, l)
+		}
+	}
+	return nil
+}
+
+// mappingField returns the key and value nodes of a field of the mapping n,
+// or nils if n is not a mapping or doesn't have the field.
+func mappingField(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i < len(n.Content)-1; i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+func mappingValue(n *yaml.Node) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	return n
+}
+
+// setMappingField sets the field key of the mapping n to a copy of value, or
+// removes it if value is nil.
+func setMappingField(n, keyNode, value *yaml.Node, key string) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(n.Content)-1; i += 2 {
+		if n.Content[i].Value != key {
+			continue
+		}
+		if value == nil {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+		} else {
+			n.Content[i+1] = yaml.CopyYNode(value)
+		}
+		return
+	}
+	if value != nil {
+		n.Content = append(n.Content, yaml.CopyYNode(keyNode), yaml.CopyYNode(value))
+	}
+}
+
+func elementID(e *yaml.Node, key string) string {
+	_, value := mappingField(e, key)
+	if value == nil {
+		return ""
+	}
+	return value.Value
+}
+
+func sequenceElement(n *yaml.Node, key, id string) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	for _, e := range n.Content {
+		if elementID(e, key) == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// setSequenceElement sets the element id of the sequence n to a copy of
+// value, or removes it if value is nil.
+func setSequenceElement(n *yaml.Node, key, id string, value *yaml.Node) {
+	for i, e := range n.Content {
+		if elementID(e, key) != id {
+			continue
+		}
+		if value == nil {
+			n.Content = append(n.Content[:i], n.Content[i+1:]...)
+		} else {
+			n.Content[i] = yaml.CopyYNode(value)
+		}
+		return
+	}
+	if value != nil {
+		n.Content = append(n.Content, yaml.CopyYNode(value))
+	}
+}
+
+// nodesEqual compares the values of two nodes, ignoring their comments and
+// styles. Either may be nil.
+func nodesEqual(a, b *yaml.Node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind == yaml.AliasNode {
+		return nodesEqual(a.Alias, b)
+	}
+	if b.Kind == yaml.AliasNode {
+		return nodesEqual(a, b.Alias)
+	}
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !nodesEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// setFileAnnotations sets the file annotations of upstream to the ones of
+// local, so upstream replaces local in the package.
+func setFileAnnotations(upstream, local *yaml.RNode) error {
+	annotations := upstream.GetAnnotations()
+	for _, a := range []string{kioutil.PathAnnotation, kioutil.IndexAnnotation,
+		kioutil.LegacyPathAnnotation, kioutil.LegacyIndexAnnotation} { // nolint:staticcheck
+		if value, found := local.GetAnnotations()[a]; found {
+			annotations[a] = value
+		} else {
+			delete(annotations, a)
+		}
+	}
+	return upstream.SetAnnotations(annotations)
+}
@@ -24,7 +24,9 @@ import (
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/ociutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/google/go-containerregistry/pkg/name"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 )
 
@@ -86,6 +88,35 @@ func GitParseArgs(ctx context.Context, args []string) (Target, error) {
 	return g, nil
 }
 
+// OciTarget contains the image of a package stored in an OCI registry and
+// the local destination of the package.
+type OciTarget struct {
+	kptfilev1.Oci
+	Destination string
+}
+
+// OciParseArgs parses the arguments for fetching a package image from an
+// OCI registry, where the first argument is of the form
+// oci://REGISTRY/IMAGE[:TAG|@DIGEST].
+func OciParseArgs(args []string) (OciTarget, error) {
+	t := OciTarget{}
+	image, err := ociutil.ParseImage(args[0])
+	if err != nil {
+		return t, err
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return t, err
+	}
+	destination, err := getDest(args[1], ref.Context().RepositoryStr(), "/")
+	if err != nil {
+		return t, err
+	}
+	t.Image = image
+	t.Destination = filepath.Clean(destination)
+	return t, nil
+}
+
 // targetFromPkgURL parses a pkg url and destination into kptfile git info and local destination Target
 func targetFromPkgURL(ctx context.Context, pkgURL string, dest string) (Target, error) {
 	g := Target{}
@@ -267,3 +267,40 @@ func Test_GitParseArgs(t *testing.T) {
 		})
 	}
 }
+
+func Test_OciParseArgs(t *testing.T) {
+	tests := map[string]struct {
+		url      string
+		expected OciTarget
+	}{
+		"image with tag": {
+			url: "oci://us-docker.pkg.dev/my-project/blueprints/nginx:v1",
+			expected: OciTarget{Oci: v1.Oci{
+				Image: "us-docker.pkg.dev/my-project/blueprints/nginx:v1",
+			},
+				Destination: "nginx"},
+		},
+		"image without tag": {
+			url: "oci://us-docker.pkg.dev/my-project/blueprints/nginx",
+			expected: OciTarget{Oci: v1.Oci{
+				Image: "us-docker.pkg.dev/my-project/blueprints/nginx:latest",
+			},
+				Destination: "nginx"},
+		},
+		"image with digest": {
+			url: "oci://localhost:5000/nginx@sha256:6d4b6d6f8f3b1d7e9e1b3b4b8f1d6f6c8a1e5e4a2f9d8c7b6a5f4e3d2c1b0a9f",
+			expected: OciTarget{Oci: v1.Oci{
+				Image: "localhost:5000/nginx@sha256:6d4b6d6f8f3b1d7e9e1b3b4b8f1d6f6c8a1e5e4a2f9d8c7b6a5f4e3d2c1b0a9f",
+			},
+				Destination: "nginx"},
+		},
+	}
+	for name, test := range tests {
+		test := test // capture range variable
+		t.Run(name, func(t *testing.T) {
+			actual, err := OciParseArgs([]string{test.url, ""})
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
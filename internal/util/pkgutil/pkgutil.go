@@ -15,9 +15,11 @@
 package pkgutil
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
 
@@ -340,6 +342,78 @@ func RoundTripKptfilesInPkg(pkgPath string) error {
 	return nil
 }
 
+// InitDeclaredSubpackages makes sure that all the remote subpackages declared
+// in the Kptfile of the package at pkgPath exist on the local filesystem.
+// A declared subpackage that doesn't exist yet is initialized with a Kptfile
+// referencing the declared upstream, which makes it an unfetched remote
+// subpackage. The upstream of an existing remote subpackage is set to the
+// declared upstream, so the subpackage is updated along with the package.
+// It returns the absolute paths of the subpackages that were initialized.
+func InitDeclaredSubpackages(pkgPath string) ([]string, error) {
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	var initialized []string
+	for _, sp := range kf.Subpackages {
+		if sp.Upstream == nil {
+			continue
+		}
+		upstream := *sp.Upstream
+		if upstream.Type == "" {
+			upstream.Type = kptfilev1.GitOrigin
+		}
+		if upstream.UpdateStrategy == "" {
+			upstream.UpdateStrategy = kptfilev1.ResourceMerge
+		}
+		if upstream.Git != nil {
+			g := *upstream.Git
+			upstream.Git = &g
+		}
+
+		spPath := filepath.Join(pkgPath, sp.LocalDir)
+		isPkg, err := pkg.IsPackageDir(filesys.FileSystemOrOnDisk{}, spPath)
+		if err != nil {
+			return nil, err
+		}
+		if !isPkg {
+			exists, err := Exists(spPath)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				return nil, fmt.Errorf("subpackage %q must not exist as a directory without a Kptfile", sp.LocalDir)
+			}
+			if err := os.MkdirAll(spPath, 0700); err != nil {
+				return nil, err
+			}
+			spKf := kptfileutil.DefaultKptfile(sp.LocalDir)
+			spKf.Upstream = &upstream
+			if err := kptfileutil.WriteFile(spPath, spKf); err != nil {
+				return nil, err
+			}
+			initialized = append(initialized, spPath)
+			continue
+		}
+
+		spKf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, spPath)
+		if err != nil {
+			return nil, err
+		}
+		if spKf.Upstream == nil {
+			return nil, fmt.Errorf("subpackage %q is declared as a remote subpackage, but is a local package", sp.LocalDir)
+		}
+		if reflect.DeepEqual(spKf.Upstream, &upstream) {
+			continue
+		}
+		spKf.Upstream = &upstream
+		if err := kptfileutil.WriteFile(spPath, spKf); err != nil {
+			return nil, err
+		}
+	}
+	return initialized, nil
+}
+
 // Exists returns true if a file or directory exists on the provided path,
 // and false otherwise.
 func Exists(path string) (bool, error) {
@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package push contains libraries for publishing packages to OCI registries.
+package push
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/ociutil"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	porchapi "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+)
+
+// Command pushes a local package as an image to an OCI registry.
+type Command struct {
+	// Path is the path to the local package to push.
+	Path string
+
+	// Image is the name of the image to push, including the tag.
+	Image string
+
+	// Lifecycle is the lifecycle of the package revision recorded on the
+	// image. Defaults to Draft.
+	Lifecycle porchapi.PackageRevisionLifecycle
+
+	// Revision is the revision of the package revision recorded on the
+	// image. It can only be set for published package revisions.
+	Revision string
+
+	// CacheDir is the directory used for caching images. Defaults to
+	// ociutil.DefaultCacheDir().
+	CacheDir string
+
+	// Digest is the digest of the pushed image. It is set by Run.
+	Digest string
+}
+
+// Run runs the Command.
+func (c *Command) Run(ctx context.Context) error {
+	const op errors.Op = "push.Run"
+	pr := printer.FromContextOrDie(ctx)
+
+	if err := c.DefaultValues(); err != nil {
+		return errors.E(op, types.UniquePath(c.Path), err)
+	}
+
+	client, err := ociutil.NewClient(c.CacheDir)
+	if err != nil {
+		return errors.E(op, errors.OCI, err)
+	}
+
+	annotations := map[string]string{
+		ociutil.AnnotationKeyLifecycle: string(c.Lifecycle),
+	}
+	if c.Revision != "" {
+		annotations[ociutil.AnnotationKeyRevision] = c.Revision
+	}
+
+	pr.Printf("Pushing package %q to %s\n", c.Path, c.Image)
+	digestName, err := client.PushPackage(ctx, c.Path, c.Image, annotations)
+	if err != nil {
+		return errors.E(op, errors.OCI, types.UniquePath(c.Path), err)
+	}
+	c.Digest = digestName.Digest
+	return nil
+}
+
+// DefaultValues sets values to the default values if they were unspecified,
+// and validates the Command.
+func (c *Command) DefaultValues() error {
+	const op errors.Op = "push.DefaultValues"
+	if c.Path == "" {
+		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify package path"))
+	}
+	if _, err := os.Stat(filepath.Join(c.Path, kptfilev1.KptFileName)); err != nil {
+		return errors.E(op, errors.InvalidParam,
+			fmt.Errorf("%q is not a kpt package: %w", c.Path, err))
+	}
+
+	if c.Image == "" {
+		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+	}
+	image, err := ociutil.ParseImage(c.Image)
+	if err != nil {
+		return errors.E(op, errors.InvalidParam, err)
+	}
+	c.Image = image
+
+	switch c.Lifecycle {
+	case "":
+		c.Lifecycle = porchapi.PackageRevisionLifecycleDraft
+	case porchapi.PackageRevisionLifecycleDraft,
+		porchapi.PackageRevisionLifecycleProposed,
+		porchapi.PackageRevisionLifecyclePublished:
+	default:
+		return errors.E(op, errors.InvalidParam,
+			fmt.Errorf("unknown lifecycle %q, must be one of %s, %s or %s", c.Lifecycle,
+				porchapi.PackageRevisionLifecycleDraft,
+				porchapi.PackageRevisionLifecycleProposed,
+				porchapi.PackageRevisionLifecyclePublished))
+	}
+	if c.Revision != "" && c.Lifecycle != porchapi.PackageRevisionLifecyclePublished {
+		return errors.E(op, errors.InvalidParam,
+			fmt.Errorf("revision can only be set for %s packages", porchapi.PackageRevisionLifecyclePublished))
+	}
+
+	if c.CacheDir == "" {
+		c.CacheDir = ociutil.DefaultCacheDir()
+	}
+	return nil
+}
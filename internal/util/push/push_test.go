@@ -0,0 +1,154 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	porchapi "github.com/GoogleContainerTools/kpt/porch/api/porch/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+)
+
+func TestCommand_Run(t *testing.T) {
+	src := setupPackage(t)
+	image := testutil.StartOciRegistry(t) + "/blueprints/mysql:v1"
+
+	cmd := Command{
+		Path:      src,
+		Image:     "oci://" + image,
+		Lifecycle: porchapi.PackageRevisionLifecyclePublished,
+		Revision:  "v1",
+		CacheDir:  t.TempDir(),
+	}
+	err := cmd.Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotEmpty(t, cmd.Digest)
+
+	// verify the package can be fetched from the pushed image
+	dest := filepath.Join(t.TempDir(), "mysql")
+	err = get.Command{
+		Oci:         &kptfilev1.Oci{Image: image},
+		Destination: dest,
+	}.Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	diff, err := testutil.Diff(src, dest, true)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, diff.Difference(testutil.KptfileSet).List())
+}
+
+func TestCommand_DefaultValues(t *testing.T) {
+	pkgPath := setupPackage(t)
+
+	testCases := map[string]struct {
+		command   Command
+		expected  Command
+		expectErr string
+	}{
+		"defaults": {
+			command: Command{
+				Path:     pkgPath,
+				Image:    "oci://localhost:5000/mysql",
+				CacheDir: "/tmp/cache",
+			},
+			expected: Command{
+				Path:      pkgPath,
+				Image:     "localhost:5000/mysql:latest",
+				Lifecycle: porchapi.PackageRevisionLifecycleDraft,
+				CacheDir:  "/tmp/cache",
+			},
+		},
+		"not a package": {
+			command: Command{
+				Path:  t.TempDir(),
+				Image: "localhost:5000/mysql:v1",
+			},
+			expectErr: "is not a kpt package",
+		},
+		"missing image": {
+			command: Command{
+				Path: pkgPath,
+			},
+			expectErr: "must specify image",
+		},
+		"unknown lifecycle": {
+			command: Command{
+				Path:      pkgPath,
+				Image:     "localhost:5000/mysql:v1",
+				Lifecycle: "Deleted",
+			},
+			expectErr: "unknown lifecycle",
+		},
+		"revision for draft": {
+			command: Command{
+				Path:     pkgPath,
+				Image:    "localhost:5000/mysql:v1",
+				Revision: "v1",
+			},
+			expectErr: "revision can only be set for Published packages",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			err := tc.command.DefaultValues()
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, tc.command)
+		})
+	}
+}
+
+// setupPackage copies the mysql package of Dataset1 into a new temporary
+// directory, adds a Kptfile and returns the directory.
+func setupPackage(t *testing.T) string {
+	dataPath, err := testutil.GetTestDataPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := copyutil.CopyDir(filepath.Join(dataPath, testutil.Dataset1, "mysql"), dir); err != nil {
+		t.Fatal(err)
+	}
+	kptfile := `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: mysql
+`
+	if err := os.WriteFile(filepath.Join(dir, kptfilev1.KptFileName), []byte(kptfile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
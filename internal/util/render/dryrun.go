@@ -0,0 +1,418 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/sets"
+)
+
+// ChangeStatus describes how rendering changes a file or resource.
+type ChangeStatus string
+
+const (
+	Added    ChangeStatus = "Added"
+	Modified ChangeStatus = "Modified"
+	Deleted  ChangeStatus = "Deleted"
+)
+
+// Formats the changes of a dry run can be written in.
+const (
+	DiffFormatUnified = "unified"
+	DiffFormatJSON    = "json"
+)
+
+// FileChange describes how rendering changes a file of the package.
+type FileChange struct {
+	// Path is the slash separated path of the file relative to the root
+	// package.
+	Path string `json:"path"`
+
+	// Status is Deleted for files pruned by rendering.
+	Status ChangeStatus `json:"status"`
+
+	// Resources are the changed resources in the file. It is empty if the
+	// file doesn't contain KRM resources, in which case Diff is set instead.
+	Resources []ResourceChange `json:"resources,omitempty"`
+
+	// Diff is the unified diff of a file that doesn't contain KRM resources.
+	Diff string `json:"diff,omitempty"`
+}
+
+// ResourceChange describes how rendering changes a resource.
+type ResourceChange struct {
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Namespace  string       `json:"namespace,omitempty"`
+	Name       string       `json:"name"`
+	Status     ChangeStatus `json:"status"`
+
+	// Diff is the unified diff of the resource, without file headers.
+	Diff string `json:"diff"`
+}
+
+// String returns the identifier of the resource, e.g. apps/v1/Deployment/default/nginx.
+func (c ResourceChange) String() string {
+	parts := []string{c.APIVersion, c.Kind}
+	if c.Namespace != "" {
+		parts = append(parts, c.Namespace)
+	}
+	return strings.Join(append(parts, c.Name), "/")
+}
+
+// WriteChanges writes the changes in the given format, which is either
+// DiffFormatUnified or DiffFormatJSON.
+func WriteChanges(w io.Writer, changes []FileChange, format string) error {
+	switch format {
+	case DiffFormatJSON:
+		if changes == nil {
+			changes = []FileChange{}
+		}
+		b, err := json.MarshalIndent(struct {
+			Files []FileChange `json:"files"`
+		}{Files: changes}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case DiffFormatUnified, "":
+	default:
+		return fmt.Errorf("unknown diff format %q, must be one of %s, %s", format, DiffFormatUnified, DiffFormatJSON)
+	}
+
+	for _, fc := range changes {
+		from, to := "a/"+fc.Path, "b/"+fc.Path
+		switch fc.Status {
+		case Added:
+			from = "/dev/null"
+		case Deleted:
+			to = "/dev/null"
+		}
+		if len(fc.Resources) == 0 {
+			if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n%s", from, to, fc.Diff); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, rc := range fc.Resources {
+			if _, err := fmt.Fprintf(w, "--- %s\t%s\n+++ %s\t%s\n%s", from, rc, to, rc, rc.Diff); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dryRunFileSystem is a file system that keeps the changes to a directory in
+// memory. Paths in the directory are served from an in-memory copy of the
+// directory, and all other paths from the underlying file system, so
+// packages outside the directory can still be read.
+type dryRunFileSystem struct {
+	// dir is the absolute path of the directory kept in memory.
+	dir  string
+	mem  filesys.FileSystem
+	base filesys.FileSystem
+}
+
+var _ filesys.FileSystem = &dryRunFileSystem{}
+
+// newDryRunFileSystem returns a file system that keeps the changes to dir in
+// memory.
+func newDryRunFileSystem(base filesys.FileSystem, dir string) (*dryRunFileSystem, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	mem := filesys.MakeFsInMemory()
+	err = base.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return mem.MkdirAll(path)
+		}
+		b, err := base.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return mem.WriteFile(path, b)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %q into memory: %w", dir, err)
+	}
+	return &dryRunFileSystem{dir: dir, mem: mem, base: base}, nil
+}
+
+// fs returns the file system that serves path.
+func (fsys *dryRunFileSystem) fs(path string) filesys.FileSystem {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if path == fsys.dir || strings.HasPrefix(path, fsys.dir+string(filepath.Separator)) {
+		return fsys.mem
+	}
+	return fsys.base
+}
+
+func (fsys *dryRunFileSystem) Create(path string) (filesys.File, error) {
+	return fsys.fs(path).Create(path)
+}
+
+func (fsys *dryRunFileSystem) Mkdir(path string) error {
+	return fsys.fs(path).Mkdir(path)
+}
+
+func (fsys *dryRunFileSystem) MkdirAll(path string) error {
+	return fsys.fs(path).MkdirAll(path)
+}
+
+func (fsys *dryRunFileSystem) RemoveAll(path string) error {
+	return fsys.fs(path).RemoveAll(path)
+}
+
+func (fsys *dryRunFileSystem) Open(path string) (filesys.File, error) {
+	return fsys.fs(path).Open(path)
+}
+
+func (fsys *dryRunFileSystem) IsDir(path string) bool {
+	return fsys.fs(path).IsDir(path)
+}
+
+func (fsys *dryRunFileSystem) ReadDir(path string) ([]string, error) {
+	return fsys.fs(path).ReadDir(path)
+}
+
+func (fsys *dryRunFileSystem) CleanedAbs(path string) (filesys.ConfirmedDir, string, error) {
+	return fsys.fs(path).CleanedAbs(path)
+}
+
+func (fsys *dryRunFileSystem) Exists(path string) bool {
+	return fsys.fs(path).Exists(path)
+}
+
+func (fsys *dryRunFileSystem) Glob(pattern string) ([]string, error) {
+	return fsys.fs(pattern).Glob(pattern)
+}
+
+func (fsys *dryRunFileSystem) ReadFile(path string) ([]byte, error) {
+	return fsys.fs(path).ReadFile(path)
+}
+
+func (fsys *dryRunFileSystem) WriteFile(path string, data []byte) error {
+	return fsys.fs(path).WriteFile(path, data)
+}
+
+func (fsys *dryRunFileSystem) Walk(path string, walkFn filepath.WalkFunc) error {
+	return fsys.fs(path).Walk(path, walkFn)
+}
+
+// changes returns the changes between the files of the directory in the
+// underlying file system and in memory.
+func (fsys *dryRunFileSystem) changes() ([]FileChange, error) {
+	before, err := readFiles(fsys.base, fsys.dir)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readFiles(fsys.mem, fsys.dir)
+	if err != nil {
+		return nil, err
+	}
+	paths := sets.String{}
+	for p := range before {
+		paths.Insert(p)
+	}
+	for p := range after {
+		paths.Insert(p)
+	}
+	sortedPaths := paths.List()
+	sort.Strings(sortedPaths)
+
+	var changes []FileChange
+	for _, p := range sortedPaths {
+		b, inBefore := before[p]
+		a, inAfter := after[p]
+		if inBefore && inAfter && bytes.Equal(a, b) {
+			continue
+		}
+		fc := FileChange{Path: p, Status: Modified}
+		switch {
+		case !inBefore:
+			fc.Status = Added
+		case !inAfter:
+			fc.Status = Deleted
+		}
+		fc.Resources, err = resourceChanges(b, a)
+		if err != nil || len(fc.Resources) == 0 {
+			// the file doesn't contain KRM resources, or only differs in
+			// formatting, so the whole file is diffed.
+			fc.Resources = nil
+			fc.Diff = unifiedDiff(string(b), string(a))
+		}
+		changes = append(changes, fc)
+	}
+	return changes, nil
+}
+
+// readFiles returns the content of the files in dir, keyed by their slash
+// separated paths relative to dir.
+func readFiles(fsys filesys.FileSystem, dir string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := fsys.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		b, err := fsys.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = b
+		return nil
+	})
+	return files, err
+}
+
+// resourceChanges returns the changes between the resources in the files
+// with the content before and after. Resources are matched by their
+// apiVersion, kind, namespace and name.
+func resourceChanges(before, after []byte) ([]ResourceChange, error) {
+	beforeResources, err := readResources(before)
+	if err != nil {
+		return nil, err
+	}
+	afterResources, err := readResources(after)
+	if err != nil {
+		return nil, err
+	}
+	beforeByID := map[string]resource{}
+	for _, r := range beforeResources {
+		beforeByID[r.String()] = r
+	}
+	afterIDs := sets.String{}
+
+	var changes []ResourceChange
+	for _, r := range afterResources {
+		afterIDs.Insert(r.String())
+		rc := r.ResourceChange
+		b, found := beforeByID[r.String()]
+		switch {
+		case !found:
+			rc.Status = Added
+		case b.content != r.content:
+			rc.Status = Modified
+		default:
+			continue
+		}
+		rc.Diff = unifiedDiff(b.content, r.content)
+		changes = append(changes, rc)
+	}
+	for _, r := range beforeResources {
+		if afterIDs.Has(r.String()) {
+			continue
+		}
+		rc := r.ResourceChange
+		rc.Status = Deleted
+		rc.Diff = unifiedDiff(r.content, "")
+		changes = append(changes, rc)
+	}
+	return changes, nil
+}
+
+// resource is a resource read from a file.
+type resource struct {
+	ResourceChange
+	content string
+}
+
+func readResources(b []byte) ([]resource, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	nodes, err := (&kio.ByteReader{
+		Reader:                bytes.NewReader(b),
+		OmitReaderAnnotations: true,
+	}).Read()
+	if err != nil {
+		return nil, err
+	}
+	var resources []resource
+	for _, n := range nodes {
+		if n.GetKind() == "" || n.GetName() == "" {
+			return nil, fmt.Errorf("not a KRM resource")
+		}
+		s, err := n.String()
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource{
+			ResourceChange: ResourceChange{
+				APIVersion: n.GetApiVersion(),
+				Kind:       n.GetKind(),
+				Namespace:  n.GetNamespace(),
+				Name:       n.GetName(),
+			},
+			content: s,
+		})
+	}
+	return resources, nil
+}
+
+// unifiedDiff returns the hunks of the unified diff between a and b.
+func unifiedDiff(a, b string) string {
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:       splitLines(a),
+		B:       splitLines(b),
+		Context: 3,
+	})
+	return diff
+}
+
+// splitLines splits s into lines that each end with a newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
@@ -15,12 +15,14 @@
 package render
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
@@ -28,12 +30,14 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/attribution"
+	"github.com/GoogleContainerTools/kpt/internal/util/expr"
 	"github.com/GoogleContainerTools/kpt/internal/util/printerutil"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
 	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/sets"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -41,6 +45,11 @@ import (
 
 var errAllowedExecNotSpecified = fmt.Errorf("must run with `--allow-exec` option to allow running function binaries")
 
+// sourcePkgAnnotation is the annotation set on resources that are rendered
+// into a package from a source package outside of it. Such resources are
+// regenerated from the source package on every render.
+const sourcePkgAnnotation = "internal.kpt.dev/source-package"
+
 // Renderer hydrates a given pkg by running the functions in the input pipeline
 type Renderer struct {
 	// PkgPath is the absolute path to the root package
@@ -63,6 +72,25 @@ type Renderer struct {
 
 	// FileSystem is the input filesystem to operate on
 	FileSystem filesys.FileSystem
+
+	// Concurrency is the maximum number of packages hydrated concurrently.
+	// Sibling subpackages that don't depend on packages outside of their
+	// own directory can be hydrated concurrently. Values less than 2
+	// hydrate the packages sequentially.
+	Concurrency int
+
+	// DryRun renders the package in memory instead of writing it in place.
+	// The changes rendering would make are recorded in Changes.
+	DryRun bool
+
+	// Changes are the changes to the files of the package that rendering
+	// would make. It is set by Execute if DryRun is set.
+	Changes []FileChange
+
+	// TraceDir is the directory to record the input and output of every
+	// function run in, along with the function results. Nothing is
+	// recorded if it is empty.
+	TraceDir string
 }
 
 // Execute runs a pipeline.
@@ -71,7 +99,22 @@ func (e *Renderer) Execute(ctx context.Context) (*fnresult.ResultList, error) {
 
 	pr := printer.FromContextOrDie(ctx)
 
-	root, err := newPkgNode(e.FileSystem, e.PkgPath, nil)
+	fsys := e.FileSystem
+	var dryRunFS *dryRunFileSystem
+	if e.DryRun {
+		if e.Output != nil {
+			return nil, errors.E(op, types.UniquePath(e.PkgPath),
+				fmt.Errorf("dry run can not be combined with writing the output"))
+		}
+		var err error
+		dryRunFS, err = newDryRunFileSystem(e.FileSystem, e.PkgPath)
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(e.PkgPath), err)
+		}
+		fsys = dryRunFS
+	}
+
+	root, err := newPkgNode(fsys, e.PkgPath, nil)
 	if err != nil {
 		return nil, errors.E(op, types.UniquePath(e.PkgPath), err)
 	}
@@ -82,11 +125,24 @@ func (e *Renderer) Execute(ctx context.Context) (*fnresult.ResultList, error) {
 		pkgs:          map[types.UniquePath]*pkgNode{},
 		fnResults:     fnresult.NewResultList(),
 		runnerOptions: e.RunnerOptions,
-		fileSystem:    e.FileSystem,
+		fileSystem:    fsys,
 		runtime:       e.Runtime,
+		pkgsMu:        &sync.Mutex{},
+	}
+	if e.TraceDir != "" {
+		hctx.runnerOptions.Tracer, err = fnruntime.NewTracer(e.FileSystem, e.TraceDir, types.UniquePath(e.PkgPath))
+		if err != nil {
+			return nil, errors.E(op, root.pkg.UniquePath, err)
+		}
+	}
+	if e.Concurrency > 1 {
+		// the goroutine hydrating the root package is one of the workers.
+		hctx.workers = make(chan struct{}, e.Concurrency-1)
 	}
 
-	if _, err = hydrate(ctx, root, hctx); err != nil {
+	_, err = hydrate(ctx, root, hctx)
+	hctx.inputFiles = root.inputFiles
+	if err != nil {
 		// Note(droot): ignore the error in function result saving
 		// to avoid masking the hydration error.
 		// don't disable the CLI output in case of error
@@ -117,7 +173,7 @@ func (e *Renderer) Execute(ctx context.Context) (*fnresult.ResultList, error) {
 			PackageFileName:    kptfilev1.KptFileName,
 			IncludeSubpackages: true,
 			WrapBareSeqNode:    true,
-			FileSystem:         filesys.FileSystemOrOnDisk{FileSystem: e.FileSystem},
+			FileSystem:         filesys.FileSystemOrOnDisk{FileSystem: fsys},
 			MatchFilesGlob:     pkg.MatchAllKRM,
 		}
 		err = pkgWriter.Write(hctx.root.resources)
@@ -125,10 +181,16 @@ func (e *Renderer) Execute(ctx context.Context) (*fnresult.ResultList, error) {
 			return nil, fmt.Errorf("failed to save resources: %w", err)
 		}
 
-		if err = pruneResources(e.FileSystem, hctx); err != nil {
+		if err = pruneResources(fsys, hctx); err != nil {
 			return nil, err
 		}
 		pr.Printf("Successfully executed %d function(s) in %d package(s).\n", hctx.executedFunctionCnt, len(hctx.pkgs))
+		if e.DryRun {
+			e.Changes, err = dryRunFS.changes()
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute changes: %w", err)
+			}
+		}
 	} else {
 		// the intent of the user is to write the resources to either stdout|unwrapped|<OUT_DIR>
 		// so, write the resources to provided e.Output which will be written to appropriate destination by cobra layer
@@ -153,6 +215,9 @@ func (e *Renderer) saveFnResults(ctx context.Context, fnResults *fnresult.Result
 	if err != nil {
 		return fmt.Errorf("failed to save function results: %w", err)
 	}
+	if _, err := fnruntime.SaveResults(e.FileSystem, e.TraceDir, fnResults); err != nil {
+		return fmt.Errorf("failed to save function results to trace: %w", err)
+	}
 
 	printerutil.PrintFnResultInfo(ctx, resultsFile, false)
 	return nil
@@ -170,8 +235,8 @@ type hydrationContext struct {
 	pkgs map[types.UniquePath]*pkgNode
 
 	// inputFiles is a set of filepaths containing input resources to the
-	// functions across all the packages during hydration.
-	// The file paths are relative to the root package.
+	// functions across all the packages whose resources are written back
+	// during hydration. The file paths are relative to the root package.
 	inputFiles sets.String
 
 	// outputFiles is a set of filepaths containing output resources. This
@@ -191,6 +256,48 @@ type hydrationContext struct {
 
 	// function runtime
 	runtime fn.FunctionRuntime
+
+	// pkgsMu guards pkgs, which is shared with the hydration contexts of
+	// the packages being hydrated concurrently.
+	pkgsMu *sync.Mutex
+
+	// workers limits the number of additional goroutines hydrating
+	// packages concurrently. It is nil if packages are hydrated sequentially.
+	workers chan struct{}
+}
+
+// getPkg returns the package with the given path if it has been discovered.
+func (hctx *hydrationContext) getPkg(path types.UniquePath) (*pkgNode, bool) {
+	hctx.pkgsMu.Lock()
+	defer hctx.pkgsMu.Unlock()
+	pn, found := hctx.pkgs[path]
+	return pn, found
+}
+
+// addPkg adds the package to the discovered packages.
+func (hctx *hydrationContext) addPkg(pn *pkgNode) {
+	hctx.pkgsMu.Lock()
+	defer hctx.pkgsMu.Unlock()
+	hctx.pkgs[pn.pkg.UniquePath] = pn
+}
+
+// fork returns a hydration context for hydrating a package concurrently
+// with other packages. The returned context shares the discovered packages,
+// but gathers its own function results, which are added back by join.
+func (hctx *hydrationContext) fork() *hydrationContext {
+	forked := *hctx
+	forked.executedFunctionCnt = 0
+	forked.fnResults = fnresult.NewResultList()
+	return &forked
+}
+
+// join adds the function results gathered in the forked context to hctx.
+func (hctx *hydrationContext) join(forked *hydrationContext) {
+	hctx.executedFunctionCnt += forked.executedFunctionCnt
+	hctx.fnResults.Items = append(hctx.fnResults.Items, forked.fnResults.Items...)
+	if forked.fnResults.ExitCode != 0 {
+		hctx.fnResults.ExitCode = forked.fnResults.ExitCode
+	}
 }
 
 // pkgNode represents a package being hydrated. Think of it as a node in the hydration DAG.
@@ -203,6 +310,11 @@ type pkgNode struct {
 	// KRM resources that we have gathered post hydration for this package.
 	// These inludes resources at this pkg as well all it's children.
 	resources []*yaml.RNode
+
+	// inputFiles is a set of filepaths containing input resources of this
+	// package and the source packages whose resources remain part of
+	// their own package. The file paths are relative to the root package.
+	inputFiles sets.String
 }
 
 // newPkgNode returns a pkgNode instance given a path or pkg.
@@ -229,6 +341,9 @@ func newPkgNode(fsys filesys.FileSystem, path string, p *pkg.Pkg) (pn *pkgNode,
 	if err := kf.Validate(fsys, p.UniquePath); err != nil {
 		return pn, errors.E(op, p.UniquePath, err)
 	}
+	if err := validateExpressions(kf.Pipeline); err != nil {
+		return pn, errors.E(op, p.UniquePath, err)
+	}
 
 	pn = &pkgNode{
 		pkg:   p,
@@ -255,7 +370,7 @@ func (s hydrationState) String() string {
 func hydrate(ctx context.Context, pn *pkgNode, hctx *hydrationContext) (output []*yaml.RNode, err error) {
 	const op errors.Op = "pkg.render"
 
-	curr, found := hctx.pkgs[pn.pkg.UniquePath]
+	curr, found := hctx.getPkg(pn.pkg.UniquePath)
 	if found {
 		switch curr.state {
 		case Hydrating:
@@ -271,64 +386,325 @@ func hydrate(ctx context.Context, pn *pkgNode, hctx *hydrationContext) (output [
 		}
 	}
 	// add it to the discovered package list
-	hctx.pkgs[pn.pkg.UniquePath] = pn
+	hctx.addPkg(pn)
 	curr = pn
 	// mark the pkg in hydrating
 	curr.state = Hydrating
 
-	relPath, err := curr.pkg.RelativePathTo(hctx.root.pkg)
+	pl, err := curr.pkg.Pipeline()
 	if err != nil {
-		return nil, errors.E(op, curr.pkg.UniquePath, err)
+		return output, errors.E(op, curr.pkg.UniquePath, err)
 	}
 
 	var input []*yaml.RNode
 
-	// determine sub packages to be hydrated
-	subpkgs, err := curr.pkg.DirectSubpackages()
+	// resolve the sources in the order they are declared in the pipeline.
+	for _, src := range pl.ResolvedSources() {
+		var resources []*yaml.RNode
+		switch src {
+		case kptfilev1.SourceCurrentPkg:
+			resources, err = curr.localResources(hctx)
+		case kptfilev1.SourceAllSubPkgs:
+			resources, err = curr.subpkgResources(ctx, hctx)
+			if err != nil {
+				break
+			}
+			// subpackages are resolved before the package resources
+			var currPkgResources []*yaml.RNode
+			currPkgResources, err = curr.localResources(hctx)
+			resources = append(resources, currPkgResources...)
+		default:
+			resources, err = curr.sourcePkgResources(ctx, hctx, src)
+		}
+		if err != nil {
+			return output, errors.E(op, curr.pkg.UniquePath, err)
+		}
+		input = append(input, resources...)
+	}
+
+	output, err = curr.runPipeline(ctx, hctx, input)
 	if err != nil {
 		return output, errors.E(op, curr.pkg.UniquePath, err)
 	}
-	// hydrate recursively and gather hydated transitive resources.
-	for _, subpkg := range subpkgs {
-		var transitiveResources []*yaml.RNode
-		var subPkgNode *pkgNode
 
-		if subPkgNode, err = newPkgNode(hctx.fileSystem, "", subpkg); err != nil {
-			return output, errors.E(op, subpkg.UniquePath, err)
+	// pkg is hydrated, mark the pkg as wet and update the resources
+	curr.state = Wet
+	curr.resources = output
+
+	return output, err
+}
+
+// localResources returns the resources present at the current package
+// excluding the resources previously rendered from source packages, which
+// are regenerated on every render.
+func (pn *pkgNode) localResources(hctx *hydrationContext) ([]*yaml.RNode, error) {
+	resources, err := pn.pkg.LocalResources()
+	if err != nil {
+		return nil, err
+	}
+	if err := pn.trackInputFiles(hctx, resources); err != nil {
+		return nil, err
+	}
+	var output []*yaml.RNode
+	for _, r := range resources {
+		if _, found := r.GetAnnotations()[sourcePkgAnnotation]; found {
+			continue
 		}
+		output = append(output, r)
+	}
+	return output, nil
+}
 
-		transitiveResources, err = hydrate(ctx, subPkgNode, hctx)
+// subpkgResources hydrates the direct subpackages of the current package and
+// returns their resources in alphanumerical order of the subpackages.
+func (pn *pkgNode) subpkgResources(ctx context.Context, hctx *hydrationContext) ([]*yaml.RNode, error) {
+	subpkgs, err := pn.pkg.DirectSubpackages()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*pkgNode, len(subpkgs))
+	for i, subpkg := range subpkgs {
+		nodes[i], err = newPkgNode(hctx.fileSystem, "", subpkg)
 		if err != nil {
-			return output, errors.E(op, subpkg.UniquePath, err)
+			return nil, errors.E(subpkg.UniquePath, err)
 		}
+	}
+	hydrated, err := hydrateSiblings(ctx, hctx, nodes)
+	if err != nil {
+		return nil, err
+	}
+	var output []*yaml.RNode
+	for i, node := range nodes {
+		pn.addSourceInputFiles(hctx, node)
+		output = append(output, hydrated[i]...)
+	}
+	return output, nil
+}
 
-		input = append(input, transitiveResources...)
+// hydrateSiblings hydrates the given sibling packages and returns their
+// resources in the same order as the packages.
+// Packages that are self-contained are hydrated concurrently if workers are
+// available, and are joined in order so that the function results and the
+// output are the same as if they were hydrated sequentially. The remaining
+// packages are hydrated sequentially afterwards, since they may depend on
+// their siblings.
+func hydrateSiblings(ctx context.Context, hctx *hydrationContext, nodes []*pkgNode) ([][]*yaml.RNode, error) {
+	output := make([][]*yaml.RNode, len(nodes))
+	hydrated := make([]bool, len(nodes))
+
+	if hctx.workers != nil {
+		pr := printer.FromContextOrDie(ctx)
+		forks := make([]*hydrationContext, len(nodes))
+		logs := make([]*bytes.Buffer, len(nodes))
+		errs := make([]error, len(nodes))
+		var failedMu sync.Mutex
+		failed := false
+
+		hydrateNode := func(i int) {
+			// messages are buffered and printed in order once all the
+			// packages are hydrated.
+			fctx := printer.WithContext(ctx, printer.New(pr.OutStream(), logs[i]))
+			output[i], errs[i] = hydrate(fctx, nodes[i], forks[i])
+			if errs[i] != nil {
+				failedMu.Lock()
+				failed = true
+				failedMu.Unlock()
+			}
+		}
+		hasFailed := func() bool {
+			failedMu.Lock()
+			defer failedMu.Unlock()
+			return failed
+		}
+
+		var wg sync.WaitGroup
+		for i, node := range nodes {
+			if hasFailed() || !isSelfContained(hctx.fileSystem, node.pkg) {
+				continue
+			}
+			hydrated[i] = true
+			forks[i] = hctx.fork()
+			logs[i] = &bytes.Buffer{}
+			select {
+			case hctx.workers <- struct{}{}:
+				wg.Add(1)
+				go func(i int) {
+					defer func() {
+						<-hctx.workers
+						wg.Done()
+					}()
+					hydrateNode(i)
+				}(i)
+			default:
+				// no worker available, so hydrate the package in the
+				// current goroutine.
+				hydrateNode(i)
+			}
+		}
+		wg.Wait()
+
+		for i := range nodes {
+			if !hydrated[i] {
+				continue
+			}
+			fmt.Fprint(pr.ErrStream(), logs[i].String())
+			hctx.join(forks[i])
+		}
+		for i, node := range nodes {
+			if errs[i] != nil {
+				return nil, errors.E(node.pkg.UniquePath, errs[i])
+			}
+		}
+	}
+
+	for i, node := range nodes {
+		if hydrated[i] {
+			continue
+		}
+		resources, err := hydrate(ctx, node, hctx)
+		if err != nil {
+			return nil, errors.E(node.pkg.UniquePath, err)
+		}
+		output[i] = resources
 	}
+	return output, nil
+}
 
-	// gather resources present at the current package
-	currPkgResources, err := curr.pkg.LocalResources()
+// isSelfContained returns true if the package and its subpackages only
+// use sources located inside the package, which means the package can be
+// hydrated independently of its siblings.
+func isSelfContained(fsys filesys.FileSystem, p *pkg.Pkg) bool {
+	subpkgPaths, err := pkg.Subpackages(fsys, p.UniquePath.String(), pkg.All, true)
 	if err != nil {
-		return output, errors.E(op, curr.pkg.UniquePath, err)
+		return false
+	}
+	pkgs := []*pkg.Pkg{p}
+	for _, subpkgPath := range subpkgPaths {
+		subpkg, err := p.Resolve(filepath.ToSlash(subpkgPath))
+		if err != nil {
+			return false
+		}
+		pkgs = append(pkgs, subpkg)
+	}
+	for _, curr := range pkgs {
+		pl, err := curr.Pipeline()
+		if err != nil {
+			return false
+		}
+		for _, src := range pl.ResolvedSources() {
+			if src == kptfilev1.SourceCurrentPkg || src == kptfilev1.SourceAllSubPkgs {
+				continue
+			}
+			srcPkg, err := curr.Resolve(src)
+			if err != nil {
+				return false
+			}
+			if srcPkg.UniquePath != p.UniquePath && !srcPkg.IsDescendantOf(p) {
+				return false
+			}
+		}
 	}
+	return true
+}
 
-	err = trackInputFiles(hctx, relPath, currPkgResources)
+// sourcePkgResources hydrates the package at the given source path and
+// returns its resources.
+// Resources of a source package located inside the current package remain
+// part of that package. Resources of any other source package are copied
+// into the current package, since that package must not be modified when
+// rendering the current package.
+func (pn *pkgNode) sourcePkgResources(ctx context.Context, hctx *hydrationContext, src string) ([]*yaml.RNode, error) {
+	p, err := pn.pkg.Resolve(src)
 	if err != nil {
 		return nil, err
 	}
+	srcPkgNode, err := newPkgNode(hctx.fileSystem, "", p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source %q: %w", src, err)
+	}
+	if srcPkgNode.pkg.IsDescendantOf(pn.pkg) {
+		resources, err := pn.hydrateSource(ctx, hctx, srcPkgNode)
+		if err != nil {
+			return nil, errors.E(srcPkgNode.pkg.UniquePath, err)
+		}
+		return resources, nil
+	}
+	resources, err := hydrate(ctx, srcPkgNode, hctx)
+	if err != nil {
+		return nil, errors.E(srcPkgNode.pkg.UniquePath, err)
+	}
+	return pn.copySourceResources(src, srcPkgNode.pkg.UniquePath, resources)
+}
 
-	// include current package's resources in the input resource list
-	input = append(input, currPkgResources...)
-
-	output, err = curr.runPipeline(ctx, hctx, input)
+// hydrateSource hydrates the given package whose resources remain part of
+// the package they belong to, and records its input files as input files of
+// the current package.
+func (pn *pkgNode) hydrateSource(ctx context.Context, hctx *hydrationContext, src *pkgNode) ([]*yaml.RNode, error) {
+	resources, err := hydrate(ctx, src, hctx)
 	if err != nil {
-		return output, errors.E(op, curr.pkg.UniquePath, err)
+		return nil, err
 	}
+	pn.addSourceInputFiles(hctx, src)
+	return resources, nil
+}
 
-	// pkg is hydrated, mark the pkg as wet and update the resources
-	curr.state = Wet
-	curr.resources = output
+// addSourceInputFiles records the input files of the given hydrated package
+// as input files of the current package.
+func (pn *pkgNode) addSourceInputFiles(hctx *hydrationContext, src *pkgNode) {
+	// a package may be hydrated before, so use the node in the
+	// hydration context to gather the input files.
+	hydrated, _ := hctx.getPkg(src.pkg.UniquePath)
+	if pn.inputFiles == nil {
+		pn.inputFiles = sets.String{}
+	}
+	pn.inputFiles.Insert(hydrated.inputFiles.List()...)
+}
 
-	return output, err
+// copySourceResources returns copies of the given resources of the source
+// package at path src that belong to the current package. The Kptfile and
+// local config resources of the source package are not copied. The copied
+// resources are annotated with the source they are rendered from.
+func (pn *pkgNode) copySourceResources(src string, srcPkgPath types.UniquePath, resources []*yaml.RNode) ([]*yaml.RNode, error) {
+	srcPath := srcPkgPath.String()
+	var output []*yaml.RNode
+	for _, r := range resources {
+		if r.GetKind() == kptfilev1.KptFileKind ||
+			r.GetAnnotations()[filters.LocalConfigAnnotation] == "true" {
+			continue
+		}
+		pkgPath, err := pkg.GetPkgPathAnnotation(r)
+		if err != nil {
+			return nil, err
+		}
+		if pkgPath == "" {
+			pkgPath = srcPath
+		}
+		currPath, _, err := kioutil.GetFileAnnotations(r)
+		if err != nil {
+			return nil, err
+		}
+		// file path relative to the source package is used as the file
+		// path in the current package.
+		newPath, err := pathRelToRoot(srcPath, pkgPath, currPath)
+		if err != nil {
+			return nil, err
+		}
+		c := r.Copy()
+		if err = c.PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, newPath)); err != nil {
+			return nil, err
+		}
+		if err = c.PipeE(yaml.SetAnnotation(kioutil.LegacyPathAnnotation, newPath)); err != nil { // nolint:staticcheck
+			return nil, err
+		}
+		if err = c.PipeE(yaml.SetAnnotation(sourcePkgAnnotation, src)); err != nil {
+			return nil, err
+		}
+		if err = pkg.SetPkgPathAnnotation(c, pn.pkg.UniquePath); err != nil {
+			return nil, err
+		}
+		output = append(output, c)
+	}
+	return output, nil
 }
 
 // runPipeline runs the pipeline defined at current pkgNode on given input resources.
@@ -381,12 +757,23 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 		return input, nil
 	}
 
-	mutators, err := fnChain(ctx, hctx, pn.pkg.UniquePath, pl.Mutators)
+	opts, err := pn.runnerOptions(hctx)
+	if err != nil {
+		return nil, err
+	}
+	mutators, err := fnChain(ctx, hctx, opts, pn.pkg.UniquePath, pl.Mutators)
 	if err != nil {
 		return nil, err
 	}
 
 	for i, mutator := range mutators {
+		run, err := shouldRun(ctx, &pl.Mutators[i], mutator.Name(), input)
+		if err != nil {
+			return nil, err
+		}
+		if !run {
+			continue
+		}
 		if pl.Mutators[i].ConfigPath != "" {
 			// kpt v1.0.0-beta15+ onwards, functionConfigs are included in the
 			// function inputs during `render` and as a result, they can be
@@ -468,6 +855,10 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 	if len(pl.Validators) == 0 {
 		return nil
 	}
+	pkgOpts, err := pn.runnerOptions(hctx)
+	if err != nil {
+		return err
+	}
 
 	for i := range pl.Validators {
 		function := pl.Validators[i]
@@ -477,21 +868,27 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 		if err != nil {
 			return err
 		}
-		var validator kio.Filter
 		displayResourceCount := false
 		if len(function.Selectors) > 0 || len(function.Exclusions) > 0 {
 			displayResourceCount = true
 		}
-		if function.Exec != "" && !hctx.runnerOptions.AllowExec {
+		if function.Exec != "" && !pkgOpts.AllowExec {
 			return errAllowedExecNotSpecified
 		}
-		opts := hctx.runnerOptions
+		opts := pkgOpts
 		opts.SetPkgPathAnnotation = true
 		opts.DisplayResourceCount = displayResourceCount
-		validator, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pn.pkg.UniquePath, hctx.fnResults, opts, hctx.runtime)
+		validator, err := fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pn.pkg.UniquePath, hctx.fnResults, opts, hctx.runtime)
 		if err != nil {
 			return err
 		}
+		run, err := shouldRun(ctx, &function, validator.Name(), input)
+		if err != nil {
+			return err
+		}
+		if !run {
+			continue
+		}
 		if _, err = validator.Filter(cloneResources(selectedResources)); err != nil {
 			return err
 		}
@@ -500,6 +897,105 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 	return nil
 }
 
+// runnerOptions returns the options to run the functions of the pipeline of
+// the package with.
+func (pn *pkgNode) runnerOptions(hctx *hydrationContext) (fnruntime.RunnerOptions, error) {
+	opts := hctx.runnerOptions
+	kf, err := pn.pkg.Kptfile()
+	if err != nil {
+		return opts, err
+	}
+	opts.ImageLocks = fnruntime.ImageLocks(kf)
+	return opts, nil
+}
+
+// validateExpressions validates the `when` expressions, and the field paths
+// and `match` expressions of the selectors of the functions of the pipeline.
+// They are validated here rather than by KptFile.Validate, so the Kptfile
+// API doesn't depend on the expression language.
+func validateExpressions(pl *kptfilev1.Pipeline) error {
+	if pl == nil {
+		return nil
+	}
+	for _, fns := range []struct {
+		fnType string
+		fns    []kptfilev1.Function
+	}{{"mutators", pl.Mutators}, {"validators", pl.Validators}} {
+		fnType := fns.fnType
+		for i, f := range fns.fns {
+			if f.When != "" {
+				if _, err := expr.Parse(f.When); err != nil {
+					return &kptfilev1.ValidateError{
+						Field:  fmt.Sprintf("pipeline.%s[%d].when", fnType, i),
+						Value:  f.When,
+						Reason: err.Error(),
+					}
+				}
+			}
+			for j := range f.Selectors {
+				if err := validateSelector(&f.Selectors[j], fmt.Sprintf("pipeline.%s[%d].selectors[%d]", fnType, i, j)); err != nil {
+					return err
+				}
+			}
+			for j := range f.Exclusions {
+				if err := validateSelector(&f.Exclusions[j], fmt.Sprintf("pipeline.%s[%d].exclude[%d]", fnType, i, j)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateSelector validates the field paths and the match expression of
+// the selector. field is the path of the selector in the Kptfile.
+func validateSelector(s *kptfilev1.Selector, field string) error {
+	for i, fs := range s.Fields {
+		if _, err := expr.ParsePath(fs.Path); err != nil {
+			return &kptfilev1.ValidateError{
+				Field:  fmt.Sprintf("%s.fields[%d].path", field, i),
+				Value:  fs.Path,
+				Reason: err.Error(),
+			}
+		}
+	}
+	if s.Match != "" {
+		if _, err := expr.Parse(s.Match); err != nil {
+			return &kptfilev1.ValidateError{
+				Field:  field + ".match",
+				Value:  s.Match,
+				Reason: err.Error(),
+			}
+		}
+	}
+	return nil
+}
+
+// shouldRun evaluates the `when` expression of the function against the
+// input resources, and returns false if the function should be skipped.
+// Functions without a `when` expression are always run.
+func shouldRun(ctx context.Context, function *kptfilev1.Function, name string, input []*yaml.RNode) (bool, error) {
+	if function.When == "" {
+		return true, nil
+	}
+	e, err := expr.Parse(function.When)
+	if err != nil {
+		return false, err
+	}
+	resources, err := expr.ResourceValues(input)
+	if err != nil {
+		return false, err
+	}
+	run, err := e.EvalBool(map[string]interface{}{"resources": resources})
+	if err != nil {
+		return false, fmt.Errorf("function %q: %w", name, err)
+	}
+	if !run {
+		printer.FromContextOrDie(ctx).Printf("[SKIPPED] %q: `when` condition is false\n", name)
+	}
+	return run, nil
+}
+
 func cloneResources(input []*yaml.RNode) (output []*yaml.RNode) {
 	for _, resource := range input {
 		output = append(output, resource.Copy())
@@ -582,7 +1078,7 @@ func pathRelToRoot(rootPkgPath, subPkgPath, resourcePath string) (relativePath s
 }
 
 // fnChain returns a slice of function runners given a list of functions defined in pipeline.
-func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePath, fns []kptfilev1.Function) ([]*fnruntime.FunctionRunner, error) {
+func fnChain(ctx context.Context, hctx *hydrationContext, pkgOpts fnruntime.RunnerOptions, pkgPath types.UniquePath, fns []kptfilev1.Function) ([]*fnruntime.FunctionRunner, error) {
 	var runners []*fnruntime.FunctionRunner
 	for i := range fns {
 		var err error
@@ -592,10 +1088,10 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 		if len(function.Selectors) > 0 || len(function.Exclusions) > 0 {
 			displayResourceCount = true
 		}
-		if function.Exec != "" && !hctx.runnerOptions.AllowExec {
+		if function.Exec != "" && !pkgOpts.AllowExec {
 			return nil, errAllowedExecNotSpecified
 		}
-		opts := hctx.runnerOptions
+		opts := pkgOpts
 		opts.SetPkgPathAnnotation = true
 		opts.DisplayResourceCount = displayResourceCount
 		runner, err = fnruntime.NewRunner(ctx, hctx.fileSystem, &function, pkgPath, hctx.fnResults, opts, hctx.runtime)
@@ -607,10 +1103,15 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 	return runners, nil
 }
 
-// trackInputFiles records file paths of input resources in the hydration context.
-func trackInputFiles(hctx *hydrationContext, relPath string, input []*yaml.RNode) error {
-	if hctx.inputFiles == nil {
-		hctx.inputFiles = sets.String{}
+// trackInputFiles records file paths of input resources of the current package.
+// The file paths are relative to the root package.
+func (pn *pkgNode) trackInputFiles(hctx *hydrationContext, input []*yaml.RNode) error {
+	relPath, err := pn.pkg.RelativePathTo(hctx.root.pkg)
+	if err != nil {
+		return err
+	}
+	if pn.inputFiles == nil {
+		pn.inputFiles = sets.String{}
 	}
 	for _, r := range input {
 		path, _, err := kioutil.GetFileAnnotations(r)
@@ -618,7 +1119,7 @@ func trackInputFiles(hctx *hydrationContext, relPath string, input []*yaml.RNode
 			return fmt.Errorf("path annotation missing: %w", err)
 		}
 		path = filepath.Join(relPath, filepath.Clean(path))
-		hctx.inputFiles.Insert(path)
+		pn.inputFiles.Insert(path)
 	}
 	return nil
 }
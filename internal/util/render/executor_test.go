@@ -15,12 +15,27 @@
 package render
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func TestPathRelToRoot(t *testing.T) {
@@ -227,3 +242,599 @@ metadata:
 		})
 	}
 }
+
+func TestRenderSources(t *testing.T) {
+	files := map[string]string{
+		"/bases/nginx/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: nginx
+`,
+		"/bases/nginx/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`,
+		"/envs/prod/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: prod
+pipeline:
+  sources:
+  - ../../bases/nginx
+  - .
+`,
+		"/envs/prod/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+`,
+	}
+	fsys := filesys.MakeFsInMemory()
+	for path, content := range files {
+		assert.NoError(t, fsys.MkdirAll(filepath.Dir(path)))
+		assert.NoError(t, fsys.WriteFile(path, []byte(content)))
+	}
+
+	// rendering must be idempotent.
+	for i := 0; i < 2; i++ {
+		r := &Renderer{
+			PkgPath:    "/envs/prod",
+			FileSystem: fsys,
+		}
+		_, err := r.Execute(fake.CtxWithDefaultPrinter())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		got, err := fsys.ReadFile("/envs/prod/deployment.yaml")
+		assert.NoError(t, err)
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  annotations:
+    internal.kpt.dev/source-package: '../../bases/nginx'
+`, string(got))
+	}
+
+	// source package must not be modified.
+	got, err := fsys.ReadFile("/bases/nginx/deployment.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, files["/bases/nginx/deployment.yaml"], string(got))
+}
+
+func TestRenderSourcesCycle(t *testing.T) {
+	files := map[string]string{
+		"/a/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: a
+pipeline:
+  sources:
+  - ../b
+`,
+		"/b/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: b
+pipeline:
+  sources:
+  - ../a
+`,
+	}
+	fsys := filesys.MakeFsInMemory()
+	for path, content := range files {
+		assert.NoError(t, fsys.MkdirAll(filepath.Dir(path)))
+		assert.NoError(t, fsys.WriteFile(path, []byte(content)))
+	}
+	r := &Renderer{
+		PkgPath:    "/a",
+		FileSystem: fsys,
+	}
+	_, err := r.Execute(fake.CtxWithDefaultPrinter())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cycle detected in pkg dependencies")
+	}
+}
+
+func TestRenderWhen(t *testing.T) {
+	testCases := map[string]struct {
+		pipeline    string
+		expected    []string
+		notExpected []string
+		stderr      []string
+		expectErr   string
+	}{
+		"functions are skipped if when is false": {
+			pipeline: `
+  mutators:
+  - image: prod
+    when: resources.exists(r, r.kind == 'ConfigMap' && r.metadata.name == 'env' && r.data.env == 'prod')
+  - image: dev
+    when: resources.exists(r, r.kind == 'ConfigMap' && r.metadata.name == 'env' && r.data.env == 'dev')
+  - image: always
+`,
+			expected:    []string{"rendered-by/prod: 'true'", "rendered-by/always: 'true'"},
+			notExpected: []string{"rendered-by/dev"},
+			stderr:      []string{"[SKIPPED] \"dev\": `when` condition is false", "[PASS] \"prod\""},
+		},
+		"when is evaluated against the output of previous functions": {
+			pipeline: `
+  mutators:
+  - image: first
+  - image: second
+    when: resources.all(r, has(r.metadata.annotations) && 'rendered-by/first' in r.metadata.annotations)
+`,
+			expected: []string{"rendered-by/first: 'true'", "rendered-by/second: 'true'"},
+		},
+		"validators": {
+			pipeline: `
+  validators:
+  - image: check
+    when: size(resources) > 5
+`,
+			stderr: []string{"[SKIPPED] \"check\": `when` condition is false"},
+		},
+		"when must evaluate to a bool": {
+			pipeline: `
+  mutators:
+  - image: count
+    when: size(resources)
+`,
+			expectErr: "must evaluate to a bool, got int",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			fsys := filesys.MakeFsInMemory()
+			files := map[string]string{
+				"/pkg/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:` + tc.pipeline,
+				"/pkg/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+data:
+  env: prod
+`,
+			}
+			for path, content := range files {
+				assert.NoError(t, fsys.MkdirAll(filepath.Dir(path)))
+				assert.NoError(t, fsys.WriteFile(path, []byte(content)))
+			}
+			var opts fnruntime.RunnerOptions
+			opts.InitDefaults()
+			opts.ResolveToImage = func(_ context.Context, image string) (string, error) {
+				return image, nil
+			}
+			out := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			r := &Renderer{
+				PkgPath:       "/pkg",
+				FileSystem:    fsys,
+				Runtime:       &fakeRuntime{},
+				RunnerOptions: opts,
+				Output:        out,
+			}
+			ctx := printer.WithContext(context.Background(), printer.New(nil, stderr))
+			_, err := r.Execute(ctx)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, e := range tc.expected {
+				assert.Contains(t, out.String(), e)
+			}
+			for _, e := range tc.notExpected {
+				assert.NotContains(t, out.String(), e)
+			}
+			for _, e := range tc.stderr {
+				assert.Contains(t, stderr.String(), e)
+			}
+		})
+	}
+}
+
+func TestRenderDryRun(t *testing.T) {
+	files := map[string]string{
+		"/pkg/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  mutators:
+  - image: delete-configmaps
+`,
+		"/pkg/resources.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 3
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: env
+data:
+  env: prod
+`,
+		"/pkg/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+		"/pkg/README.md": "# pkg\n",
+	}
+	fsys := filesys.MakeFsInMemory()
+	for path, content := range files {
+		assert.NoError(t, fsys.MkdirAll(filepath.Dir(path)))
+		assert.NoError(t, fsys.WriteFile(path, []byte(content)))
+	}
+	var opts fnruntime.RunnerOptions
+	opts.InitDefaults()
+	opts.ResolveToImage = func(_ context.Context, image string) (string, error) {
+		return image, nil
+	}
+	r := &Renderer{
+		PkgPath:       "/pkg",
+		FileSystem:    fsys,
+		Runtime:       &fakeRuntime{},
+		RunnerOptions: opts,
+		DryRun:        true,
+	}
+	_, err := r.Execute(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the package is not modified
+	for path, content := range files {
+		got, err := fsys.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(got))
+	}
+
+	expected := []FileChange{
+		{
+			Path:   "Kptfile",
+			Status: Modified,
+			Resources: []ResourceChange{
+				{
+					APIVersion: "kpt.dev/v1",
+					Kind:       "Kptfile",
+					Name:       "pkg",
+					Status:     Modified,
+					Diff: `@@ -2,6 +2,8 @@
+ kind: Kptfile
+ metadata:
+   name: pkg
++  annotations:
++    rendered-by/delete-configmaps: 'true'
+ pipeline:
+   mutators:
+   - image: delete-configmaps
+`,
+				},
+			},
+		},
+		{
+			Path:   "configmap.yaml",
+			Status: Deleted,
+			Resources: []ResourceChange{
+				{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Name:       "config",
+					Status:     Deleted,
+					Diff: `@@ -1,4 +0,0 @@
+-apiVersion: v1
+-kind: ConfigMap
+-metadata:
+-  name: config
+`,
+				},
+			},
+		},
+		{
+			Path:   "resources.yaml",
+			Status: Modified,
+			Resources: []ResourceChange{
+				{
+					APIVersion: "apps/v1",
+					Kind:       "Deployment",
+					Name:       "nginx",
+					Status:     Modified,
+					Diff: `@@ -2,5 +2,7 @@
+ kind: Deployment
+ metadata:
+   name: nginx
++  annotations:
++    rendered-by/delete-configmaps: 'true'
+ spec:
+   replicas: 3
+`,
+				},
+				{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Name:       "env",
+					Status:     Deleted,
+					Diff: `@@ -1,6 +0,0 @@
+-apiVersion: v1
+-kind: ConfigMap
+-metadata:
+-  name: env
+-data:
+-  env: prod
+`,
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, r.Changes)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteChanges(out, r.Changes, DiffFormatUnified))
+	assert.Contains(t, out.String(), "--- a/configmap.yaml\tv1/ConfigMap/config\n+++ /dev/null\tv1/ConfigMap/config\n")
+	assert.Contains(t, out.String(), "--- a/resources.yaml\tapps/v1/Deployment/nginx\n+++ b/resources.yaml\tapps/v1/Deployment/nginx\n")
+
+	out.Reset()
+	assert.NoError(t, WriteChanges(out, r.Changes, DiffFormatJSON))
+	var decoded struct {
+		Files []FileChange `json:"files"`
+	}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, expected, decoded.Files)
+}
+
+func TestRenderDryRun_noChanges(t *testing.T) {
+	fsys := filesys.MakeFsInMemory()
+	assert.NoError(t, fsys.MkdirAll("/pkg"))
+	assert.NoError(t, fsys.WriteFile("/pkg/Kptfile", []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+`)))
+	r := &Renderer{
+		PkgPath:    "/pkg",
+		FileSystem: fsys,
+		DryRun:     true,
+	}
+	_, err := r.Execute(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, r.Changes)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, WriteChanges(out, r.Changes, DiffFormatJSON))
+	assert.Equal(t, "{\n  \"files\": []\n}\n", out.String())
+}
+
+// fakeRuntime is a function runtime that annotates the resources with the
+// image of the function, deletes ConfigMaps if the image is
+// delete-configmaps, and records the maximum number of functions
+// running at the same time.
+type fakeRuntime struct {
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (r *fakeRuntime) GetRunner(_ context.Context, f *kptfilev1.Function) (fn.FunctionRunner, error) {
+	return &fakeRunner{runtime: r, image: f.Image}, nil
+}
+
+type fakeRunner struct {
+	runtime *fakeRuntime
+	image   string
+}
+
+func (r *fakeRunner) Run(in io.Reader, out io.Writer) error {
+	r.runtime.mu.Lock()
+	r.runtime.running++
+	if r.runtime.running > r.runtime.max {
+		r.runtime.max = r.runtime.running
+	}
+	r.runtime.mu.Unlock()
+	defer func() {
+		r.runtime.mu.Lock()
+		r.runtime.running--
+		r.runtime.mu.Unlock()
+	}()
+	// give the other packages a chance to run their functions.
+	time.Sleep(50 * time.Millisecond)
+
+	rw := &kio.ByteReadWriter{Reader: in, Writer: out, KeepReaderAnnotations: true}
+	nodes, err := rw.Read()
+	if err != nil {
+		return err
+	}
+	var output []*yaml.RNode
+	for _, n := range nodes {
+		if r.image == "delete-configmaps" && n.GetKind() == "ConfigMap" {
+			continue
+		}
+		if err := n.PipeE(yaml.SetAnnotation("rendered-by/"+r.image, "true")); err != nil {
+			return err
+		}
+		output = append(output, n)
+	}
+	return rw.Write(output)
+}
+
+func TestRenderConcurrency(t *testing.T) {
+	files := map[string]string{
+		"/root/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+  - image: root
+`,
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		files["/root/"+name+"/Kptfile"] = fmt.Sprintf(`apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: %s
+pipeline:
+  mutators:
+  - image: %s
+`, name, name)
+		files["/root/"+name+"/configmap.yaml"] = fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+`, name)
+	}
+	// e depends on its sibling a, so it is rendered after the other packages.
+	files["/root/e/Kptfile"] = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: e
+pipeline:
+  sources:
+  - ../a
+  - .
+  mutators:
+  - image: e
+`
+	files["/root/d/nested/Kptfile"] = `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: nested
+pipeline:
+  mutators:
+  - image: nested
+`
+
+	type renderOutput struct {
+		resources string
+		stderr    string
+		results   []fnresult.Result
+		maxFns    int
+	}
+	render := func(concurrency int) renderOutput {
+		fsys := filesys.MakeFsInMemory()
+		for path, content := range files {
+			assert.NoError(t, fsys.MkdirAll(filepath.Dir(path)))
+			assert.NoError(t, fsys.WriteFile(path, []byte(content)))
+		}
+		var opts fnruntime.RunnerOptions
+		opts.InitDefaults()
+		opts.ResolveToImage = func(_ context.Context, image string) (string, error) {
+			return image, nil
+		}
+		runtime := &fakeRuntime{}
+		out := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		r := &Renderer{
+			PkgPath:       "/root",
+			FileSystem:    fsys,
+			Runtime:       runtime,
+			RunnerOptions: opts,
+			Output:        out,
+			Concurrency:   concurrency,
+		}
+		ctx := printer.WithContext(context.Background(), printer.New(nil, stderr))
+		results, err := r.Execute(ctx)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return renderOutput{
+			resources: out.String(),
+			// durations of the functions differ between runs.
+			stderr:  regexp.MustCompile(` in .*\n`).ReplaceAllString(stderr.String(), "\n"),
+			results: results.Items,
+			maxFns:  runtime.max,
+		}
+	}
+
+	sequential := render(1)
+	assert.Equal(t, 1, sequential.maxFns)
+	assert.Contains(t, sequential.resources, "rendered-by/e: 'true'")
+
+	concurrent := render(4)
+	assert.Greater(t, concurrent.maxFns, 1)
+	assert.LessOrEqual(t, concurrent.maxFns, 4)
+	assert.Equal(t, sequential.resources, concurrent.resources)
+	assert.Equal(t, sequential.stderr, concurrent.stderr)
+	assert.Equal(t, sequential.results, concurrent.results)
+}
+
+func TestValidateExpressions(t *testing.T) {
+	testCases := map[string]struct {
+		pipeline      *kptfilev1.Pipeline
+		expectedField string
+	}{
+		"valid expressions": {
+			pipeline: &kptfilev1.Pipeline{
+				Mutators: []kptfilev1.Function{{
+					Image: "image",
+					When:  "resources.exists(r, r.kind == 'ConfigMap' && r.data.env == 'prod')",
+					Selectors: []kptfilev1.Selector{{
+						Fields: []kptfilev1.FieldSelector{{Path: "spec.template.spec.containers[*].image", Value: "nginx:*"}},
+						Match:  "spec.replicas > 1",
+					}},
+				}},
+			},
+		},
+		"invalid when expression": {
+			pipeline: &kptfilev1.Pipeline{
+				Validators: []kptfilev1.Function{{
+					Image: "image",
+					When:  "resources.exists(r, r.kind = 'ConfigMap')",
+				}},
+			},
+			expectedField: "pipeline.validators[0].when",
+		},
+		"invalid field selector path": {
+			pipeline: &kptfilev1.Pipeline{
+				Mutators: []kptfilev1.Function{{
+					Image: "image",
+					Selectors: []kptfilev1.Selector{{
+						Fields: []kptfilev1.FieldSelector{{Path: "spec.containers[*.image"}},
+					}},
+				}},
+			},
+			expectedField: "pipeline.mutators[0].selectors[0].fields[0].path",
+		},
+		"invalid exclusion match expression": {
+			pipeline: &kptfilev1.Pipeline{
+				Mutators: []kptfilev1.Function{
+					{Image: "image"},
+					{Image: "image", Exclusions: []kptfilev1.Selector{{Match: "spec.replicas >"}}},
+				},
+			},
+			expectedField: "pipeline.mutators[1].exclude[0].match",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			err := validateExpressions(tc.pipeline)
+			if tc.expectedField == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var validateErr *kptfilev1.ValidateError
+			if assert.ErrorAs(t, err, &validateErr) {
+				assert.Equal(t, tc.expectedField, validateErr.Field)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// FunctionMerge is the update strategy merging the changes into the local
+// package like the resource-merge strategy, but delegating the merge of the
+// resources to the KRM function in the mergeFunction field of the upstream.
+const FunctionMerge kptfilev1.UpdateStrategyType = "function-merge"
+
+var errAllowedExecNotSpecified = fmt.Errorf("must run with `--allow-exec` option to allow running function binaries")
+
+// FunctionMergeUpdater updates a package like ResourceMergeUpdater, but
+// merges the resources with the merge function of the package. The function
+// receives the resources of the original, updated and local package, with
+// their package in the config.kubernetes.io/merge-source annotation
+// (original, updated or dest), and returns the resources of the local
+// package.
+type FunctionMergeUpdater struct{}
+
+func (u FunctionMergeUpdater) Update(options Options) error {
+	const op errors.Op = "update.Update"
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, options.LocalPath)
+	if err != nil {
+		return errors.E(op, types.UniquePath(options.LocalPath), err)
+	}
+	if kf.Upstream == nil || kf.Upstream.MergeFunction == nil {
+		return errors.E(op, types.UniquePath(options.LocalPath),
+			fmt.Errorf("the %s update strategy requires the mergeFunction field of the upstream", FunctionMerge))
+	}
+	if options.Ctx == nil {
+		return errors.E(op, types.UniquePath(options.LocalPath), errors.MissingParam,
+			fmt.Errorf("the %s update strategy requires a context", FunctionMerge))
+	}
+	opts := options.FnRunnerOptions
+	if kf.Upstream.MergeFunction.Exec != "" && !opts.AllowExec {
+		return errors.E(op, types.UniquePath(options.LocalPath), errAllowedExecNotSpecified)
+	}
+	if opts.ResolveToImage == nil {
+		opts.InitDefaults()
+	}
+	f := *kf.Upstream.MergeFunction
+	runner, err := fnruntime.NewRunner(options.Ctx, filesys.FileSystemOrOnDisk{}, &f,
+		types.UniquePath(options.LocalPath), &fnresult.ResultList{}, opts, nil)
+	if err != nil {
+		return errors.E(op, types.UniquePath(options.LocalPath), err)
+	}
+	return ResourceMergeUpdater{mergeFilter: runner}.Update(options)
+}
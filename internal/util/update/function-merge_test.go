@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update_test
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	. "github.com/GoogleContainerTools/kpt/internal/util/update"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// keepLocalReplicas takes the updated resources, but keeps the local replicas.
+const keepLocalReplicas = `
+def source(r):
+  return r["metadata"]["annotations"]["config.kubernetes.io/merge-source"]
+
+def merge(items):
+  updated = {}
+  for r in items:
+    if source(r) == "updated":
+      updated[r["kind"] + "/" + r["metadata"]["name"]] = r
+  merged = []
+  for r in [r for r in items if source(r) == "dest"]:
+    u = updated.get(r["kind"] + "/" + r["metadata"]["name"])
+    if u != None:
+      u["spec"]["replicas"] = r["spec"]["replicas"]
+      u["metadata"]["annotations"] = r["metadata"]["annotations"]
+      r = u
+    merged.append(r)
+  ctx.resource_list["items"] = merged
+
+merge(ctx.resource_list["items"])
+`
+
+func TestUpdate_FunctionMerge(t *testing.T) {
+	testCases := map[string]struct {
+		mergeFunction *kptfilev1.Function
+		expected      *pkgbuilder.RootPkg
+		expectedErr   string
+	}{
+		"merges the resources with the merge function": {
+			mergeFunction: &kptfilev1.Function{
+				Starlark: &kptfilev1.StarlarkFunction{Source: keepLocalReplicas},
+			},
+			expected: pkgbuilder.NewRootPkg().
+				WithResource(pkgbuilder.DeploymentResource,
+					pkgbuilder.SetFieldPath("5", "spec", "replicas"),
+					pkgbuilder.SetFieldPath("baz", "spec", "foo")),
+		},
+		"fails without a merge function": {
+			expectedErr: "the function-merge update strategy requires the mergeFunction field of the upstream",
+		},
+		"fails with an exec merge function if exec is not allowed": {
+			mergeFunction: &kptfilev1.Function{Exec: "merge"},
+			expectedErr:   "must run with `--allow-exec` option to allow running function binaries",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			repos := testutil.EmptyReposInfo
+			origin := pkgbuilder.NewRootPkg().
+				WithResource(pkgbuilder.DeploymentResource).
+				ExpandPkg(t, repos)
+			local := pkgbuilder.NewRootPkg().
+				WithKptfile(
+					pkgbuilder.NewKptfile().
+						WithUpstream(kptRepo, "/", "master", string(FunctionMerge)),
+				).
+				WithResource(pkgbuilder.DeploymentResource,
+					pkgbuilder.SetFieldPath("5", "spec", "replicas")).
+				ExpandPkg(t, repos)
+			updated := pkgbuilder.NewRootPkg().
+				WithResource(pkgbuilder.DeploymentResource,
+					pkgbuilder.SetFieldPath("7", "spec", "replicas"),
+					pkgbuilder.SetFieldPath("baz", "spec", "foo")).
+				ExpandPkg(t, repos)
+
+			kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, local)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			kf.Upstream.MergeFunction = tc.mergeFunction
+			if !assert.NoError(t, kptfileutil.WriteFile(local, kf)) {
+				t.FailNow()
+			}
+
+			err = FunctionMergeUpdater{}.Update(Options{
+				RelPackagePath: "/",
+				OriginPath:     origin,
+				LocalPath:      local,
+				UpdatedPath:    updated,
+				IsRoot:         true,
+				Ctx:            fake.CtxWithDefaultPrinter(),
+			})
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			expected := tc.expected.
+				WithKptfile(
+					pkgbuilder.NewKptfile().
+						WithUpstream(kptRepo, "/", "master", string(FunctionMerge)),
+				).
+				ExpandPkg(t, repos)
+			if !assert.NoError(t, kptfileutil.WriteFile(expected, kf)) {
+				t.FailNow()
+			}
+			testutil.KptfileAwarePkgEqual(t, local, expected, false)
+		})
+	}
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	_, err := kptfilev1.ToUpdateStrategy(string(FunctionMerge))
+	assert.NoError(t, err)
+	assert.Contains(t, kptfilev1.UpdateStrategiesAsStrings(), string(FunctionMerge))
+
+	err = RegisterStrategy(FunctionMerge, func() Updater { return FunctionMergeUpdater{} })
+	assert.EqualError(t, err, `update strategy "function-merge" is already registered`)
+	err = RegisterStrategy(kptfilev1.ResourceMerge, func() Updater { return ResourceMergeUpdater{} })
+	assert.EqualError(t, err, `update strategy "resource-merge" is already registered`)
+}
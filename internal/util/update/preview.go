@@ -0,0 +1,427 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ChangeStatus describes how an update changes a resource, field, function
+// or file.
+type ChangeStatus string
+
+const (
+	Added    ChangeStatus = "Added"
+	Modified ChangeStatus = "Modified"
+	Deleted  ChangeStatus = "Deleted"
+)
+
+// Formats a preview can be written in.
+const (
+	PreviewFormatText = "text"
+	PreviewFormatJSON = "json"
+)
+
+// Preview describes the changes an update makes to a package.
+type Preview struct {
+	// Resources are the changed resources, including the Kptfiles.
+	Resources []ResourceChange `json:"resources"`
+
+	// Pipeline are the changed functions of the pipelines of the Kptfiles.
+	Pipeline []PipelineChange `json:"pipeline"`
+
+	// Files are the changed files that don't contain KRM resources.
+	Files []FileChange `json:"files"`
+}
+
+// ResourceChange describes how an update changes a resource.
+type ResourceChange struct {
+	// Path is the slash separated path of the file of the resource relative
+	// to the root package.
+	Path       string       `json:"path"`
+	APIVersion string       `json:"apiVersion"`
+	Kind       string       `json:"kind"`
+	Namespace  string       `json:"namespace,omitempty"`
+	Name       string       `json:"name"`
+	Status     ChangeStatus `json:"status"`
+
+	// Fields are the changed fields of a Modified resource.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// String returns the identifier of the resource, e.g. apps/v1/Deployment/default/nginx.
+func (c ResourceChange) String() string {
+	parts := []string{c.APIVersion, c.Kind}
+	if c.Namespace != "" {
+		parts = append(parts, c.Namespace)
+	}
+	return strings.Join(append(parts, c.Name), "/")
+}
+
+// FieldChange describes how an update changes a field of a resource.
+type FieldChange struct {
+	// Field is the path of the field, e.g.
+	// spec.template.spec.containers[name=nginx].image.
+	Field  string       `json:"field"`
+	Status ChangeStatus `json:"status"`
+	Before string       `json:"before,omitempty"`
+	After  string       `json:"after,omitempty"`
+}
+
+// PipelineChange describes how an update changes a function of the pipeline
+// of a Kptfile.
+type PipelineChange struct {
+	// Package is the slash separated path of the package relative to the
+	// root package.
+	Package string `json:"package"`
+	// List is either mutators or validators.
+	List string `json:"list"`
+	// Function is the name of the function, or its image or exec if it has
+	// no name.
+	Function string       `json:"function"`
+	Status   ChangeStatus `json:"status"`
+}
+
+// FileChange describes how an update changes a file that doesn't contain KRM
+// resources.
+type FileChange struct {
+	// Path is the slash separated path of the file relative to the root
+	// package.
+	Path   string       `json:"path"`
+	Status ChangeStatus `json:"status"`
+}
+
+// Preview runs the update in a scratch copy of the package and returns the
+// changes it makes, without modifying the package.
+func (u *Command) Preview(ctx context.Context) (*Preview, error) {
+	const op errors.Op = "update.Preview"
+	if u.Pkg == nil {
+		return nil, errors.E(op, errors.MissingParam, "pkg must be provided")
+	}
+	localPath := u.Pkg.UniquePath.String()
+
+	dir, err := os.MkdirTemp("", "kpt-update-preview-")
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	defer os.RemoveAll(dir)
+	// the scratch copy keeps the name of the package, which is used by the
+	// update.
+	scratchPath := filepath.Join(dir, filepath.Base(localPath))
+	if err := copyutil.CopyDir(localPath, scratchPath); err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	p, err := pkg.New(filesys.FileSystemOrOnDisk{}, scratchPath)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+
+	scratch := *u
+	scratch.Pkg = p
+	if err := scratch.Run(ctx); err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	u.cachedUpstreamRepos = scratch.cachedUpstreamRepos
+
+	preview, err := diffPackages(localPath, scratchPath)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	return preview, nil
+}
+
+// diffPackages returns the changes from the package at before to the package
+// at after.
+func diffPackages(before, after string) (*Preview, error) {
+	preview := &Preview{}
+
+	beforeResources, err := readPreviewResources(before)
+	if err != nil {
+		return nil, err
+	}
+	afterResources, err := readPreviewResources(after)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range sortedKeys(beforeResources, afterResources) {
+		b, a := beforeResources[key], afterResources[key]
+		node := a
+		if node == nil {
+			node = b
+		}
+		rc := newResourceChange(node)
+		switch {
+		case b == nil:
+			rc.Status = Added
+		case a == nil:
+			rc.Status = Deleted
+		default:
+			rc.Status = Modified
+			rc.Fields = diffFields(b, a)
+			if len(rc.Fields) == 0 {
+				continue
+			}
+		}
+		preview.Resources = append(preview.Resources, rc)
+		if rc.Kind == kptfilev1.KptFileKind {
+			preview.Pipeline = append(preview.Pipeline, diffPipelines(path.Dir(rc.Path), b, a)...)
+		}
+	}
+
+	_, beforeFiles, err := getSubDirsAndNonKrmFiles(before)
+	if err != nil {
+		return nil, err
+	}
+	_, afterFiles, err := getSubDirsAndNonKrmFiles(after)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range sortedKeys(beforeFiles, afterFiles) {
+		fc := FileChange{Path: filepath.ToSlash(strings.TrimPrefix(file, string(filepath.Separator)))}
+		switch {
+		case !beforeFiles.Has(file):
+			fc.Status = Added
+		case !afterFiles.Has(file):
+			fc.Status = Deleted
+		default:
+			same, err := compareFiles(filepath.Join(before, file), filepath.Join(after, file))
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				continue
+			}
+			fc.Status = Modified
+		}
+		preview.Files = append(preview.Files, fc)
+	}
+	return preview, nil
+}
+
+// readPreviewResources reads the resources of a package and its subpackages,
+// including the Kptfiles, keyed by file and identity.
+func readPreviewResources(root string) (map[string]*yaml.RNode, error) {
+	nodes, err := (&kio.LocalPackageReader{
+		PackagePath:        root,
+		MatchFilesGlob:     krmFilesGlob,
+		IncludeSubpackages: true,
+		PackageFileName:    kptfilev1.KptFileName,
+		PreserveSeqIndent:  true,
+		WrapBareSeqNode:    true,
+	}).Read()
+	if err != nil {
+		return nil, err
+	}
+	resources := map[string]*yaml.RNode{}
+	for _, node := range nodes {
+		rc := newResourceChange(node)
+		resources[rc.Path+"|"+rc.String()] = node
+	}
+	return resources, nil
+}
+
+func newResourceChange(node *yaml.RNode) ResourceChange {
+	p, _, _ := kioutil.GetFileAnnotations(node)
+	return ResourceChange{
+		Path:       filepath.ToSlash(p),
+		APIVersion: node.GetApiVersion(),
+		Kind:       node.GetKind(),
+		Namespace:  node.GetNamespace(),
+		Name:       node.GetName(),
+	}
+}
+
+// diffFields returns the changed fields from before to after.
+func diffFields(before, after *yaml.RNode) []FieldChange {
+	b, a := merge.FieldValues(before), merge.FieldValues(after)
+	var changes []FieldChange
+	for _, field := range sortedKeys(b, a) {
+		bv, inBefore := b[field]
+		av, inAfter := a[field]
+		fc := FieldChange{Field: field, Before: bv, After: av}
+		switch {
+		case !inBefore:
+			fc.Status = Added
+		case !inAfter:
+			fc.Status = Deleted
+		case bv != av:
+			fc.Status = Modified
+		default:
+			continue
+		}
+		changes = append(changes, fc)
+	}
+	return changes
+}
+
+// diffPipelines returns the changed functions from the pipeline of the
+// Kptfile before to the one of after. Either may be nil.
+func diffPipelines(pkgPath string, before, after *yaml.RNode) []PipelineChange {
+	var changes []PipelineChange
+	for _, list := range []string{"mutators", "validators"} {
+		b, a := pipelineFunctions(before, list), pipelineFunctions(after, list)
+		for _, fn := range sortedKeys(b, a) {
+			pc := PipelineChange{Package: pkgPath, List: list, Function: fn}
+			bf, inBefore := b[fn]
+			af, inAfter := a[fn]
+			switch {
+			case !inBefore:
+				pc.Status = Added
+			case !inAfter:
+				pc.Status = Deleted
+			case bf != af:
+				pc.Status = Modified
+			default:
+				continue
+			}
+			changes = append(changes, pc)
+		}
+	}
+	return changes
+}
+
+// pipelineFunctions returns the functions of a list of the pipeline of a
+// Kptfile, keyed by name, image or exec.
+func pipelineFunctions(kf *yaml.RNode, list string) map[string]string {
+	functions := map[string]string{}
+	if kf == nil {
+		return functions
+	}
+	fns, err := kf.Pipe(yaml.Lookup("pipeline", list))
+	if err != nil || fns == nil {
+		return functions
+	}
+	elements, err := fns.Elements()
+	if err != nil {
+		return functions
+	}
+	for _, e := range elements {
+		var id string
+		for _, field := range []string{"name", "image", "exec"} {
+			if id = yaml.GetValue(e.Field(field).Value); id != "" {
+				break
+			}
+		}
+		if id == "" {
+			id = "starlark"
+		}
+		// functions that appear several times in the list are
+		// distinguished by their occurrence.
+		for key, i := id, 2; ; i++ {
+			if _, found := functions[key]; !found {
+				id = key
+				break
+			}
+			key = fmt.Sprintf("%s#%d", id, i)
+		}
+		functions[id] = e.MustString()
+	}
+	return functions
+}
+
+func sortedKeys[V any](maps ...map[string]V) []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WritePreview writes the preview in the given format, which is either
+// PreviewFormatText or PreviewFormatJSON.
+func WritePreview(w io.Writer, p *Preview, format string) error {
+	switch format {
+	case PreviewFormatJSON:
+		out := *p
+		if out.Resources == nil {
+			out.Resources = []ResourceChange{}
+		}
+		if out.Pipeline == nil {
+			out.Pipeline = []PipelineChange{}
+		}
+		if out.Files == nil {
+			out.Files = []FileChange{}
+		}
+		b, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	case PreviewFormatText, "":
+	default:
+		return fmt.Errorf("unknown preview format %q, must be one of %s, %s", format, PreviewFormatText, PreviewFormatJSON)
+	}
+
+	var b strings.Builder
+	if len(p.Resources) == 0 && len(p.Pipeline) == 0 && len(p.Files) == 0 {
+		b.WriteString("No changes.\n")
+	}
+	if len(p.Resources) > 0 {
+		b.WriteString("Resources:\n")
+		for _, rc := range p.Resources {
+			fmt.Fprintf(&b, "  %s %s (%s)\n", rc.Status, rc, rc.Path)
+			for _, fc := range rc.Fields {
+				switch fc.Status {
+				case Added:
+					fmt.Fprintf(&b, "    %s %s: %q\n", fc.Status, fc.Field, fc.After)
+				case Deleted:
+					fmt.Fprintf(&b, "    %s %s: %q\n", fc.Status, fc.Field, fc.Before)
+				default:
+					fmt.Fprintf(&b, "    %s %s: %q -> %q\n", fc.Status, fc.Field, fc.Before, fc.After)
+				}
+			}
+		}
+	}
+	if len(p.Pipeline) > 0 {
+		b.WriteString("Pipeline:\n")
+		for _, pc := range p.Pipeline {
+			fmt.Fprintf(&b, "  %s %s %s (%s)\n", pc.Status, strings.TrimSuffix(pc.List, "s"), pc.Function, pc.Package)
+		}
+	}
+	if len(p.Files) > 0 {
+		b.WriteString("Files:\n")
+		for _, fc := range p.Files {
+			fmt.Fprintf(&b, "  %s %s\n", fc.Status, fc.Path)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -16,6 +16,7 @@ package update
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,6 +24,7 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	pkgdiff "github.com/GoogleContainerTools/kpt/internal/util/diff"
 	"github.com/GoogleContainerTools/kpt/internal/util/merge"
@@ -36,7 +38,11 @@ import (
 
 // ResourceMergeUpdater updates a package by fetching the original and updated source
 // packages, and performing a 3-way merge of the Resources.
-type ResourceMergeUpdater struct{}
+type ResourceMergeUpdater struct {
+	// mergeFilter merges the resources instead of the 3-way merge if set,
+	// see merge.Merge3.Filter.
+	mergeFilter kio.Filter
+}
 
 func (u ResourceMergeUpdater) Update(options Options) error {
 	const op errors.Op = "update.Update"
@@ -63,29 +69,74 @@ func (u ResourceMergeUpdater) Update(options Options) error {
 		return errors.E(op, types.UniquePath(options.LocalPath), err)
 	}
 
-	// Update each package and subpackage. Parent package is updated before
-	// subpackages to make sure auto-setters can work correctly.
+	// With ConflictFail, the conflicts of all the packages are reported
+	// before any of them is updated, so the update is first run in a
+	// staging copy of the package.
+	if options.ConflictMode == merge.ConflictFail {
+		if err := u.checkConflicts(options, subPkgPaths); err != nil {
+			return errors.E(op, types.UniquePath(options.LocalPath), err)
+		}
+	}
+	return u.updatePackages(options.Ctx, options, options.LocalPath, subPkgPaths, false)
+}
+
+// checkConflicts runs the update of the package and its subpackages in a
+// staging copy of the local package, and returns a ConflictError with the
+// conflicts of all the packages if any.
+func (u ResourceMergeUpdater) checkConflicts(options Options, subPkgPaths []string) error {
+	const op errors.Op = "update.checkConflicts"
+	dir, err := os.MkdirTemp("", "kpt-update-")
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer os.RemoveAll(dir)
+	stagingPath := filepath.Join(dir, filepath.Base(options.LocalPath))
+	if err := copyutil.CopyDir(options.LocalPath, stagingPath); err != nil {
+		return errors.E(op, err)
+	}
+	// the staging run doesn't print the conflicts of the non KRM files.
+	return u.updatePackages(nil, options, stagingPath, subPkgPaths, true)
+}
+
+// updatePackages updates the package in localPath and its subpackages.
+// Parent package is updated before subpackages to make sure auto-setters
+// can work correctly. With collectConflicts, the subpackages are updated
+// even if a package has conflicts, and the conflicts of all the packages
+// are returned in a single ConflictError.
+func (u ResourceMergeUpdater) updatePackages(ctx context.Context, options Options, localPath string, subPkgPaths []string,
+	collectConflicts bool) error {
+	const op errors.Op = "update.updatePackages"
+	conflicts := &merge.ConflictError{}
 	for _, subPkgPath := range append([]string{"."}, subPkgPaths...) {
 		isRootPkg := false
 		if subPkgPath == "." && options.IsRoot {
 			isRootPkg = true
 		}
-		localSubPkgPath := filepath.Join(options.LocalPath, subPkgPath)
+		localSubPkgPath := filepath.Join(localPath, subPkgPath)
 		updatedSubPkgPath := filepath.Join(options.UpdatedPath, subPkgPath)
 		originalSubPkgPath := filepath.Join(options.OriginPath, subPkgPath)
 
-		err := u.updatePackage(subPkgPath, localSubPkgPath, updatedSubPkgPath, originalSubPkgPath, isRootPkg)
+		err := u.updatePackage(ctx, subPkgPath, localSubPkgPath, updatedSubPkgPath, originalSubPkgPath, isRootPkg, options.ConflictMode)
+		var conflictErr *merge.ConflictError
+		if collectConflicts && errors.As(err, &conflictErr) {
+			conflicts.Add(subPkgPath, conflictErr)
+			continue
+		}
 		if err != nil {
 			return errors.E(op, types.UniquePath(localSubPkgPath), err)
 		}
 	}
+	if len(conflicts.Conflicts) > 0 {
+		return conflicts
+	}
 	return nil
 }
 
 // updatePackage updates the package in the location specified by localPath
 // using the provided paths to the updated version of the package and the
 // original version of the package.
-func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath, originalPath string, isRootPkg bool) error {
+func (u ResourceMergeUpdater) updatePackage(ctx context.Context, subPkgPath, localPath, updatedPath, originalPath string, isRootPkg bool,
+	conflictMode merge.ConflictMode) error {
 	const op errors.Op = "update.updatePackage"
 	localExists, err := pkgutil.Exists(localPath)
 	if err != nil {
@@ -135,7 +186,7 @@ func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath,
 			}
 		}
 	default:
-		if err := u.mergePackage(localPath, updatedPath, originalPath, subPkgPath, isRootPkg); err != nil {
+		if err := u.mergePackage(ctx, localPath, updatedPath, originalPath, subPkgPath, isRootPkg, conflictMode); err != nil {
 			return errors.E(op, types.UniquePath(localPath), err)
 		}
 	}
@@ -143,14 +194,28 @@ func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath,
 }
 
 // mergePackage merge a package. It does a 3-way merge by using the provided
-// paths to the local, updated and original versions of the package.
-func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath, _ string, isRootPkg bool) error {
+// paths to the local, updated and original versions of the package. The
+// non KRM files with conflicting changes are reported through the printer of
+// ctx, if any.
+func (u ResourceMergeUpdater) mergePackage(ctx context.Context, localPath, updatedPath, originalPath, subPkgPath string, isRootPkg bool,
+	conflictMode merge.ConflictMode) error {
 	const op errors.Op = "update.mergePackage"
-	if err := kptfileutil.UpdateKptfile(localPath, updatedPath, originalPath, !isRootPkg); err != nil {
-		return errors.E(op, types.UniquePath(localPath), err)
+	// With ConflictFail, the conflicts of the non KRM files are found before
+	// the resources are merged, so the merge fails with them before the
+	// package is written.
+	var fileConflicts []merge.Conflict
+	if conflictMode == merge.ConflictFail {
+		plan, err := planNonKRMFiles(updatedPath, originalPath, localPath, conflictMode)
+		if err != nil {
+			return errors.E(op, types.UniquePath(localPath), err)
+		}
+		fileConflicts = plan.conflictError().Conflicts
 	}
 
 	// merge the Resources: original + updated + dest => dest
+	// The resources are merged before the non KRM files and the Kptfile are
+	// updated, so the package is left untouched if the merge fails on
+	// conflicts.
 	err := merge.Merge3{
 		OriginalPath: originalPath,
 		UpdatedPath:  updatedPath,
@@ -158,37 +223,159 @@ func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath,
 		// TODO: Write a test to ensure this is set
 		MergeOnPath:        true,
 		IncludeSubPackages: false,
+		ConflictMode:       conflictMode,
+		Filter:             u.mergeFilter,
+		Conflicts:          fileConflicts,
 	}.Merge()
 	if err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
 
-	if err := ReplaceNonKRMFiles(updatedPath, originalPath, localPath); err != nil {
+	// the non KRM files are updated before the Kptfile, so the upstream of
+	// the package isn't updated if they fail to be updated.
+	conflicts, err := ReplaceNonKRMFiles(updatedPath, originalPath, localPath, conflictMode)
+	if err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
+
+	if err := kptfileutil.UpdateKptfile(localPath, updatedPath, originalPath, !isRootPkg); err != nil {
+		return errors.E(op, types.UniquePath(localPath), err)
+	}
+
+	if ctx != nil {
+		pr := printer.FromContextOrDie(ctx)
+		for _, file := range conflicts {
+			file = filepath.Join(subPkgPath, file)
+			if conflictMode == merge.ConflictAnnotate {
+				pr.Printf("Conflicting changes to %q were merged with conflict markers.\n", file)
+			} else {
+				pr.Printf("Conflicting changes to %q were not merged, the local file is unchanged.\n", file)
+			}
+		}
+	}
 	return nil
 }
 
-// replaceNonKRMFiles replaces the non KRM files in localDir with the corresponding files in updatedDir,
-// it also deletes non KRM files and sub dirs which are present in localDir and not in updatedDir
-func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
+// ReplaceNonKRMFiles replaces the non KRM files in localDir with the corresponding files in updatedDir,
+// it also deletes non KRM files and sub dirs which are present in localDir and not in updatedDir.
+// The changes to the text files modified locally are merged line by line, and the files with
+// conflicting changes are returned. A file with conflicting changes is left unchanged, unless
+// the conflicts are annotated: the conflicting lines are then kept from both between conflict
+// markers. With ConflictFail, a ConflictError listing the files is returned before any file is
+// changed.
+func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string, conflictMode merge.ConflictMode) ([]string, error) {
 	const op errors.Op = "update.ReplaceNonKRMFiles"
+	plan, err := planNonKRMFiles(updatedDir, originalDir, localDir, conflictMode)
+	if err != nil {
+		return nil, errors.E(op, types.UniquePath(localDir), err)
+	}
+	if conflictMode == merge.ConflictFail && len(plan.conflicts) > 0 {
+		return nil, errors.E(op, types.UniquePath(localDir), plan.conflictError())
+	}
+
+	// remove the files not modified locally and deleted from updated upstream
+	for _, file := range plan.deleted {
+		if err = os.Remove(filepath.Join(localDir, file)); err != nil {
+			return nil, errors.E(op, types.UniquePath(localDir), err)
+		}
+	}
+
+	// make sure local has all sub-dirs present in updated
+	for _, dir := range plan.updatedSubDirs.List() {
+		if err = os.MkdirAll(filepath.Join(localDir, dir), 0700); err != nil {
+			return nil, errors.E(op, types.UniquePath(localDir), err)
+		}
+	}
+
+	// replace all non KRM files in local with the ones in updated, and
+	// write the changes merged into the locally modified files
+	for _, file := range plan.replaced {
+		err = copyutil.SyncFile(filepath.Join(updatedDir, file), filepath.Join(localDir, file))
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(localDir), err)
+		}
+	}
+	for _, m := range plan.merged {
+		localPath := filepath.Join(localDir, m.file)
+		info, err := os.Stat(localPath)
+		if err != nil {
+			return nil, errors.E(op, errors.IO, types.UniquePath(localDir), err)
+		}
+		if err := os.WriteFile(localPath, m.content, info.Mode().Perm()); err != nil {
+			return nil, errors.E(op, errors.IO, types.UniquePath(localDir), err)
+		}
+	}
+
+	// delete all the empty dirs in local which are not in updated
+	for _, dir := range plan.localSubDirs.List() {
+		if !plan.updatedSubDirs.Has(dir) && plan.originalSubDirs.Has(dir) {
+			// removes only empty dirs
+			os.Remove(filepath.Join(localDir, dir))
+		}
+	}
+
+	return plan.conflicts, nil
+}
+
+// nonKRMFilesPlan are the changes made by ReplaceNonKRMFiles to the non KRM
+// files of a local package.
+type nonKRMFilesPlan struct {
+	updatedSubDirs  sets.String
+	originalSubDirs sets.String
+	localSubDirs    sets.String
+	// deleted are the files removed from local.
+	deleted []string
+	// replaced are the files replaced with their updated version.
+	replaced []string
+	// merged are the locally modified files the updated changes are merged
+	// into.
+	merged []mergedFile
+	// conflicts are the merged files with conflicting changes.
+	conflicts []string
+}
+
+// mergedFile is the merged content of a locally modified file.
+type mergedFile struct {
+	file    string
+	content []byte
+}
+
+// conflictError returns a ConflictError listing the files with conflicting
+// changes.
+func (p *nonKRMFilesPlan) conflictError() *merge.ConflictError {
+	e := &merge.ConflictError{}
+	for _, file := range p.conflicts {
+		e.Conflicts = append(e.Conflicts, merge.Conflict{File: file})
+	}
+	return e
+}
+
+// planNonKRMFiles returns the changes ReplaceNonKRMFiles makes to the non
+// KRM files of localDir, without changing them.
+func planNonKRMFiles(updatedDir, originalDir, localDir string, conflictMode merge.ConflictMode) (*nonKRMFilesPlan, error) {
+	const op errors.Op = "update.planNonKRMFiles"
 	updatedSubDirs, updatedFiles, err := getSubDirsAndNonKrmFiles(updatedDir)
 	if err != nil {
-		return errors.E(op, types.UniquePath(localDir), err)
+		return nil, errors.E(op, types.UniquePath(localDir), err)
 	}
 
 	originalSubDirs, originalFiles, err := getSubDirsAndNonKrmFiles(originalDir)
 	if err != nil {
-		return errors.E(op, types.UniquePath(localDir), err)
+		return nil, errors.E(op, types.UniquePath(localDir), err)
 	}
 
 	localSubDirs, localFiles, err := getSubDirsAndNonKrmFiles(localDir)
 	if err != nil {
-		return errors.E(op, types.UniquePath(localDir), err)
+		return nil, errors.E(op, types.UniquePath(localDir), err)
+	}
+	plan := &nonKRMFilesPlan{
+		updatedSubDirs:  updatedSubDirs,
+		originalSubDirs: originalSubDirs,
+		localSubDirs:    localSubDirs,
 	}
 
-	// identify all non KRM files modified locally, to leave them untouched
+	// identify all non KRM files modified locally, to merge the updated
+	// changes into them
 	locallyModifiedFiles := sets.String{}
 	for _, file := range localFiles.List() {
 		if !originalFiles.Has(file) {
@@ -198,7 +385,7 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 		}
 		same, err := compareFiles(filepath.Join(originalDir, file), filepath.Join(localDir, file))
 		if err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
+			return nil, errors.E(op, types.UniquePath(localDir), err)
 		}
 		if !same {
 			// local file has been modified
@@ -208,40 +395,30 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 
 		// remove the file from local if it is not modified and is deleted from updated upstream
 		if !updatedFiles.Has(file) {
-			if err = os.Remove(filepath.Join(localDir, file)); err != nil {
-				return errors.E(op, types.UniquePath(localDir), err)
-			}
+			plan.deleted = append(plan.deleted, file)
 		}
 	}
 
-	// make sure local has all sub-dirs present in updated
-	for _, dir := range updatedSubDirs.List() {
-		if err = os.MkdirAll(filepath.Join(localDir, dir), 0700); err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
-		}
-	}
-
-	// replace all non KRM files in local with the ones in updated
 	for _, file := range updatedFiles.List() {
-		if locallyModifiedFiles.Has(file) {
-			// skip syncing locally modified files
+		if !locallyModifiedFiles.Has(file) {
+			plan.replaced = append(plan.replaced, file)
 			continue
 		}
-		err = copyutil.SyncFile(filepath.Join(updatedDir, file), filepath.Join(localDir, file))
+		merged, conflict, err := mergeNonKRMFile(file, updatedDir, originalDir, localDir, originalFiles.Has(file))
 		if err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
+			return nil, errors.E(op, types.UniquePath(localDir), err)
 		}
-	}
-
-	// delete all the empty dirs in local which are not in updated
-	for _, dir := range localSubDirs.List() {
-		if !updatedSubDirs.Has(dir) && originalSubDirs.Has(dir) {
-			// removes only empty dirs
-			os.Remove(filepath.Join(localDir, dir))
+		// the locally modified files with conflicting changes are only
+		// changed if the conflicts are annotated, local changes are never
+		// lost.
+		if merged != nil && (!conflict || conflictMode == merge.ConflictAnnotate) {
+			plan.merged = append(plan.merged, mergedFile{file: file, content: merged})
+		}
+		if conflict {
+			plan.conflicts = append(plan.conflicts, strings.TrimPrefix(file, string(filepath.Separator)))
 		}
 	}
-
-	return nil
+	return plan, nil
 }
 
 // getSubDirsAndNonKrmFiles returns the list of all non git sub dirs and, non git+non KRM files
@@ -312,3 +489,34 @@ func compareFiles(src, dst string) (bool, error) {
 	}
 	return false, nil
 }
+
+// mergeNonKRMFile merges the changes to file from originalDir to updatedDir
+// into the locally modified file in localDir, see mergeText. It returns nil
+// if the file is kept as is, because it is unchanged in updatedDir or
+// binary. The file is merged from an empty file if it is not in
+// originalDir. It also returns true if the changes conflict.
+func mergeNonKRMFile(file, updatedDir, originalDir, localDir string, inOriginal bool) ([]byte, bool, error) {
+	const op errors.Op = "update.mergeNonKRMFile"
+	var original []byte
+	if inOriginal {
+		var err error
+		if original, err = os.ReadFile(filepath.Join(originalDir, file)); err != nil {
+			return nil, false, errors.E(op, errors.IO, err)
+		}
+	}
+	updated, err := os.ReadFile(filepath.Join(updatedDir, file))
+	if err != nil {
+		return nil, false, errors.E(op, errors.IO, err)
+	}
+	local, err := os.ReadFile(filepath.Join(localDir, file))
+	if err != nil {
+		return nil, false, errors.E(op, errors.IO, err)
+	}
+	if (inOriginal && bytes.Equal(original, updated)) || bytes.Equal(local, updated) ||
+		isBinary(original) || isBinary(local) || isBinary(updated) {
+		return nil, false, nil
+	}
+
+	merged, conflict := mergeText(original, local, updated)
+	return merged, conflict, nil
+}
@@ -19,8 +19,10 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
 	. "github.com/GoogleContainerTools/kpt/internal/util/update"
 	"github.com/stretchr/testify/assert"
 )
@@ -300,3 +302,88 @@ func TestUpdate_ResourceMerge(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdate_ResourceMerge_conflictFail(t *testing.T) {
+	kptfile := pkgbuilder.NewKptfile().
+		WithUpstream(kptRepo, "/", "master", "resource-merge").
+		WithUpstreamLock(kptRepo, "/", "master", "abc123")
+	newPkg := func(kptfile *pkgbuilder.Kptfile, rootReplicas, subReplicas string, withSecret bool, readme string) *pkgbuilder.RootPkg {
+		p := pkgbuilder.NewRootPkg().
+			WithKptfile(kptfile).
+			WithResource(pkgbuilder.DeploymentResource, pkgbuilder.SetFieldPath(rootReplicas, "spec", "replicas")).
+			WithSubPackages(
+				pkgbuilder.NewSubPkg("foo").
+					WithKptfile().
+					WithResource(pkgbuilder.DeploymentResource, pkgbuilder.SetFieldPath(subReplicas, "spec", "replicas")),
+			)
+		if withSecret {
+			p = p.WithResource(pkgbuilder.SecretResource)
+		}
+		if readme != "" {
+			p = p.WithFile("README.md", readme)
+		}
+		return p
+	}
+
+	repos := testutil.EmptyReposInfo
+	origin := newPkg(nil, "1", "1", false, "a\nb\n").ExpandPkg(t, repos)
+	updated := newPkg(nil, "2", "2", true, "a\nupstream b\n").ExpandPkg(t, repos)
+	// the conflicts of the root package and the subpackage are reported
+	local := newPkg(kptfile, "3", "3", false, "a\nb\n").ExpandPkg(t, repos)
+	err := (&ResourceMergeUpdater{}).Update(Options{
+		RelPackagePath: "/",
+		OriginPath:     origin,
+		LocalPath:      local,
+		UpdatedPath:    updated,
+		IsRoot:         true,
+		ConflictMode:   merge.ConflictFail,
+	})
+	var conflictErr *merge.ConflictError
+	if assert.True(t, errors.As(err, &conflictErr)) {
+		var files []string
+		for _, c := range conflictErr.Conflicts {
+			files = append(files, c.File)
+		}
+		assert.Equal(t, []string{"deployment.yaml", filepath.Join("foo", "deployment.yaml")}, files)
+	}
+
+	// no package is updated if a subpackage has conflicts
+	local = newPkg(kptfile, "1", "3", false, "a\nb\n").ExpandPkg(t, repos)
+	err = (&ResourceMergeUpdater{}).Update(Options{
+		RelPackagePath: "/",
+		OriginPath:     origin,
+		LocalPath:      local,
+		UpdatedPath:    updated,
+		IsRoot:         true,
+		ConflictMode:   merge.ConflictFail,
+	})
+	if assert.True(t, errors.As(err, &conflictErr)) {
+		var files []string
+		for _, c := range conflictErr.Conflicts {
+			files = append(files, c.File)
+		}
+		assert.Equal(t, []string{filepath.Join("foo", "deployment.yaml")}, files)
+	}
+	expected := newPkg(kptfile, "1", "3", false, "a\nb\n").ExpandPkg(t, repos)
+	testutil.KptfileAwarePkgEqual(t, local, expected, false)
+
+	// no file is updated if a non KRM file has conflicting changes
+	local = newPkg(kptfile, "1", "1", false, "a\nlocal b\n").ExpandPkg(t, repos)
+	err = (&ResourceMergeUpdater{}).Update(Options{
+		RelPackagePath: "/",
+		OriginPath:     origin,
+		LocalPath:      local,
+		UpdatedPath:    updated,
+		IsRoot:         true,
+		ConflictMode:   merge.ConflictFail,
+	})
+	if assert.True(t, errors.As(err, &conflictErr)) {
+		var files []string
+		for _, c := range conflictErr.Conflicts {
+			files = append(files, c.File)
+		}
+		assert.Equal(t, []string{"README.md"}, files)
+	}
+	expected = newPkg(kptfile, "1", "1", false, "a\nlocal b\n").ExpandPkg(t, repos)
+	testutil.KptfileAwarePkgEqual(t, local, expected, false)
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// binaryCheckSize is the number of leading bytes of a file inspected by
+// isBinary, like git does.
+const binaryCheckSize = 8000
+
+// isBinary returns true if the content looks like a binary file, i.e. if
+// it has a NUL byte in its first binaryCheckSize bytes.
+func isBinary(b []byte) bool {
+	if len(b) > binaryCheckSize {
+		b = b[:binaryCheckSize]
+	}
+	return bytes.IndexByte(b, 0) >= 0
+}
+
+// mergeText does a line-based 3-way merge of the changes from original to
+// local and from original to updated. The lines changed both locally and in
+// updated to different values are kept from both, between git-style conflict
+// markers. It returns the merged content and whether it has conflicts.
+func mergeText(original, local, updated []byte) ([]byte, bool) {
+	o, l, u := splitLines(original), splitLines(local), splitLines(updated)
+	lMatches, uMatches := matchLines(o, l), matchLines(o, u)
+
+	var merged []string
+	conflict := false
+	oi, li, ui := 0, 0, 0
+	for oi < len(o) || li < len(l) || ui < len(u) {
+		// copy the lines unchanged in both local and updated
+		stable := 0
+		for oi+stable < len(o) && lMatches[oi+stable] == li+stable && uMatches[oi+stable] == ui+stable {
+			stable++
+		}
+		if stable > 0 {
+			merged = append(merged, o[oi:oi+stable]...)
+			oi, li, ui = oi+stable, li+stable, ui+stable
+			continue
+		}
+
+		// the changed chunk ends at the next original line kept in both
+		oj, lj, uj := len(o), len(l), len(u)
+		for i := oi; i < len(o); i++ {
+			if lMatches[i] >= 0 && uMatches[i] >= 0 {
+				oj, lj, uj = i, lMatches[i], uMatches[i]
+				break
+			}
+		}
+		oChunk, lChunk, uChunk := o[oi:oj], l[li:lj], u[ui:uj]
+		switch {
+		case linesEqual(oChunk, lChunk):
+			merged = append(merged, uChunk...)
+		case linesEqual(oChunk, uChunk), linesEqual(lChunk, uChunk):
+			merged = append(merged, lChunk...)
+		default:
+			conflict = true
+			merged = append(merged, merge.ConflictMarkerLocal+"\n")
+			merged = appendTerminated(merged, lChunk)
+			merged = append(merged, merge.ConflictMarkerSep+"\n")
+			merged = appendTerminated(merged, uChunk)
+			merged = append(merged, merge.ConflictMarkerUpstream+"\n")
+		}
+		oi, li, ui = oj, lj, uj
+	}
+	return []byte(strings.Join(merged, "")), conflict
+}
+
+// splitLines splits the content into lines, keeping their line endings.
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns, for each line of a, the index of the matching line of
+// b or -1 if it doesn't match. The matches are in increasing order.
+func matchLines(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+	for _, block := range m.GetMatchingBlocks() {
+		for i := 0; i < block.Size; i++ {
+			matches[block.A+i] = block.B + i
+		}
+	}
+	return matches
+}
+
+func linesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendTerminated appends the lines to merged, terminating the last one
+// with a newline so it isn't joined with the following conflict marker.
+func appendTerminated(merged, lines []string) []string {
+	merged = append(merged, lines...)
+	if n := len(merged); len(lines) > 0 && !strings.HasSuffix(merged[n-1], "\n") {
+		merged[n-1] += "\n"
+	}
+	return merged
+}
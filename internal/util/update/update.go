@@ -24,19 +24,23 @@ import (
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/ociutil"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
 	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/stack"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/kustomize/kyaml/sets"
 )
 
 // PkgNotGitRepoError is the error type returned if the package being updated is not inside
@@ -79,6 +83,17 @@ type Options struct {
 	// updated and origin were fetched based on the information in the
 	// Kptfile from this package.
 	IsRoot bool
+
+	// ConflictMode controls how the resource-merge strategy handles the
+	// fields changed both locally and in upstream to different values.
+	ConflictMode merge.ConflictMode
+
+	// Ctx is the context of the update, used by the updaters running
+	// functions.
+	Ctx context.Context
+
+	// FnRunnerOptions are the options of the functions run by the updaters.
+	FnRunnerOptions fnruntime.RunnerOptions
 }
 
 // Updater updates a local package
@@ -92,6 +107,27 @@ var strategies = map[kptfilev1.UpdateStrategyType]func() Updater{
 	kptfilev1.ResourceMerge:      func() Updater { return ResourceMergeUpdater{} },
 }
 
+func init() {
+	if err := RegisterStrategy(FunctionMerge, func() Updater { return FunctionMergeUpdater{} }); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterStrategy registers a custom update strategy implemented by the
+// Updaters returned by newUpdater, so packages can declare it in the
+// updateStrategy field of their upstream. It is not safe for concurrent use
+// and is meant to be called from an init function.
+func RegisterStrategy(strategy kptfilev1.UpdateStrategyType, newUpdater func() Updater) error {
+	if newUpdater == nil {
+		return fmt.Errorf("updater of update strategy %q must not be nil", strategy)
+	}
+	if err := kptfilev1.RegisterUpdateStrategy(strategy); err != nil {
+		return err
+	}
+	strategies[strategy] = newUpdater
+	return nil
+}
+
 // Command updates the contents of a local package to a different version.
 type Command struct {
 	// Pkg captures information about the package that should be updated.
@@ -103,6 +139,14 @@ type Command struct {
 	// Strategy is the update strategy to use
 	Strategy kptfilev1.UpdateStrategyType
 
+	// ConflictMode controls how the resource-merge strategy handles the
+	// fields changed both locally and in upstream to different values.
+	ConflictMode merge.ConflictMode
+
+	// FnRunnerOptions are the options of the functions run by the
+	// function-merge strategy.
+	FnRunnerOptions fnruntime.RunnerOptions
+
 	// cachedUpstreamRepos is an upstream repo already fetched for a given repoSpec CloneRef
 	cachedUpstreamRepos map[string]*gitutil.GitUpstreamRepo
 }
@@ -121,13 +165,24 @@ func (u *Command) Run(ctx context.Context) error {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
 
-	if rootKf.Upstream == nil || rootKf.Upstream.Git == nil {
+	if rootKf.Upstream == nil || (rootKf.Upstream.Git == nil && rootKf.Upstream.Oci == nil) {
 		return errors.E(op, u.Pkg.UniquePath,
 			fmt.Errorf("package must have an upstream reference"))
 	}
-	originalRootKfRef := rootKf.Upstream.Git.Ref
-	if u.Ref != "" {
-		rootKf.Upstream.Git.Ref = u.Ref
+	var originalRootKfRef string
+	if o := rootKf.Upstream.Oci; o != nil {
+		if u.Ref != "" {
+			image, err := ociutil.ImageWithRef(o.Image, u.Ref)
+			if err != nil {
+				return errors.E(op, u.Pkg.UniquePath, err)
+			}
+			o.Image = image
+		}
+	} else {
+		originalRootKfRef = rootKf.Upstream.Git.Ref
+		if u.Ref != "" {
+			rootKf.Upstream.Git.Ref = u.Ref
+		}
 	}
 	if u.Strategy != "" {
 		rootKf.Upstream.UpdateStrategy = u.Strategy
@@ -154,6 +209,13 @@ func (u *Command) Run(ctx context.Context) error {
 			return errors.E(op, p.UniquePath, err)
 		}
 
+		// the upstreams of declared remote subpackages follow the
+		// declarations in the updated Kptfile.
+		declared, err := u.updateDeclaredSubpackages(ctx, p)
+		if err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+
 		subPkgs, err := p.DirectSubpackages()
 		if err != nil {
 			return errors.E(op, p.UniquePath, err)
@@ -164,10 +226,10 @@ func (u *Command) Run(ctx context.Context) error {
 				return errors.E(op, p.UniquePath, err)
 			}
 
-			if subKf.Upstream != nil && subKf.Upstream.Git != nil {
+			if subKf.Upstream != nil && (subKf.Upstream.Git != nil || subKf.Upstream.Oci != nil) {
 				// update subpackage kf ref/strategy if current pkg is a subpkg of root pkg or is root pkg
 				// and if original root pkg ref matches the subpkg ref
-				if shouldUpdateSubPkgRef(subKf, rootKf, originalRootKfRef) {
+				if !declared.Has(subPkg.UniquePath.String()) && shouldUpdateSubPkgRef(subKf, rootKf, originalRootKfRef) {
 					updateSubKf(subKf, u.Ref, u.Strategy)
 					err = kptfileutil.WriteFile(subPkg.UniquePath.String(), subKf)
 					if err != nil {
@@ -184,9 +246,64 @@ func (u *Command) Run(ctx context.Context) error {
 	if err := addmergecomment.Process(string(u.Pkg.UniquePath)); err != nil {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
+
+	if u.ConflictMode == merge.ConflictAnnotate {
+		conflicts, err := merge.PackageConflicts(u.Pkg.UniquePath.String())
+		if err != nil {
+			return errors.E(op, u.Pkg.UniquePath, err)
+		}
+		if len(conflicts) > 0 {
+			pr.Printf("\n%d resource(s) have conflicting fields annotated with %q:\n", len(conflicts), merge.ConflictAnnotation)
+			for _, c := range conflicts {
+				pr.Printf("  %s (%s)\n", c.Resource, c.File)
+			}
+			pr.Printf("Resolve them and run `kpt pkg resolve` to remove the annotations.\n")
+		}
+	}
 	return nil
 }
 
+// updateDeclaredSubpackages makes sure the remote subpackages declared in the
+// Kptfile of the given package exist locally and reference the declared
+// upstream. Declared subpackages that don't exist locally are fetched. It
+// returns the paths of all declared remote subpackages.
+func (u Command) updateDeclaredSubpackages(ctx context.Context, p *pkg.Pkg) (sets.String, error) {
+	const op errors.Op = "update.updateDeclaredSubpackages"
+	pr := printer.FromContextOrDie(ctx)
+
+	initialized, err := pkgutil.InitDeclaredSubpackages(p.UniquePath.String())
+	if err != nil {
+		return nil, errors.E(op, p.UniquePath, err)
+	}
+	for _, path := range initialized {
+		subPkg, err := pkg.New(filesys.FileSystemOrOnDisk{}, path)
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(path), err)
+		}
+		subKf, err := subPkg.Kptfile()
+		if err != nil {
+			return nil, errors.E(op, subPkg.UniquePath, err)
+		}
+		pr.Printf("Adding package %q declared in Kptfile.\n", packageName(path))
+		pr.Printf("Fetching %s@%s\n", subKf.Upstream.Git.Repo, subKf.Upstream.Git.Ref)
+		if err := (&fetch.Command{Pkg: subPkg}).Run(ctx); err != nil {
+			return nil, errors.E(op, subPkg.UniquePath, err)
+		}
+	}
+
+	kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, p.UniquePath.String())
+	if err != nil {
+		return nil, errors.E(op, p.UniquePath, err)
+	}
+	declared := sets.String{}
+	for _, sp := range kf.Subpackages {
+		if sp.Upstream != nil {
+			declared.Insert(filepath.Join(p.UniquePath.String(), sp.LocalDir))
+		}
+	}
+	return declared, nil
+}
+
 // GetCachedUpstreamRepos returns repos cached during update
 func (u Command) GetCachedUpstreamRepos() map[string]*gitutil.GitUpstreamRepo {
 	return u.cachedUpstreamRepos
@@ -206,6 +323,9 @@ func updateSubKf(subKf *kptfilev1.KptFile, ref string, strategy kptfilev1.Update
 // shouldUpdateSubPkgRef checks if subpkg ref should be updated.
 // This is true if pkg has the same upstream repo, upstream directory is within or equal to root pkg directory and original root pkg ref matches the subpkg ref.
 func shouldUpdateSubPkgRef(subKf, rootKf *kptfilev1.KptFile, originalRootKfRef string) bool {
+	if subKf.Upstream.Git == nil || rootKf.Upstream.Git == nil {
+		return false
+	}
 	return subKf.Upstream.Git.Repo == rootKf.Upstream.Git.Repo &&
 		subKf.Upstream.Git.Ref == originalRootKfRef &&
 		strings.HasPrefix(path.Clean(subKf.Upstream.Git.Directory), path.Clean(rootKf.Upstream.Git.Directory))
@@ -257,29 +377,15 @@ func (u Command) updateRootPackage(ctx context.Context, p *pkg.Pkg) error {
 	pr := printer.FromContextOrDie(ctx)
 	pr.PrintPackage(p, !(p == u.Pkg))
 
-	g := kf.Upstream.Git
-	updated := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
-	pr.Printf("Fetching upstream from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
-	cloner := fetch.NewCloner(updated, fetch.WithCachedRepo(u.cachedUpstreamRepos))
-	if err := cloner.ClonerUsingGitExec(ctx); err != nil {
+	updated, err := u.fetchUpstream(ctx, kf)
+	if err != nil {
 		return errors.E(op, p.UniquePath, err)
 	}
 	defer os.RemoveAll(updated.AbsPath())
 
-	var origin repoClone
-	if kf.UpstreamLock != nil {
-		gLock := kf.UpstreamLock.Git
-		originRepoSpec := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
-		pr.Printf("Fetching origin from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
-		if err := fetch.NewCloner(originRepoSpec, fetch.WithCachedRepo(u.cachedUpstreamRepos)).ClonerUsingGitExec(ctx); err != nil {
-			return errors.E(op, p.UniquePath, err)
-		}
-		origin = originRepoSpec
-	} else {
-		origin, err = newNilRepoClone()
-		if err != nil {
-			return errors.E(op, p.UniquePath, err)
-		}
+	origin, err := u.fetchOrigin(ctx, kf)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
 	}
 	defer os.RemoveAll(origin.AbsPath())
 
@@ -311,12 +417,78 @@ func (u Command) updateRootPackage(ctx context.Context, p *pkg.Pkg) error {
 		}
 	}
 
-	if err := kptfileutil.UpdateUpstreamLockFromGit(p.UniquePath.String(), updated); err != nil {
+	switch updated := updated.(type) {
+	case *fetch.Puller:
+		err = kptfileutil.UpdateUpstreamLockFromOci(p.UniquePath.String(), updated.Image, updated.Digest)
+	case *git.RepoSpec:
+		err = kptfileutil.UpdateUpstreamLockFromGit(p.UniquePath.String(), updated)
+	}
+	if err != nil {
 		return errors.E(op, p.UniquePath, err)
 	}
 	return nil
 }
 
+// fetchUpstream fetches the upstream package referenced in the Kptfile,
+// either from git or from an OCI registry.
+func (u Command) fetchUpstream(ctx context.Context, kf *kptfilev1.KptFile) (repoClone, error) {
+	pr := printer.FromContextOrDie(ctx)
+
+	if o := kf.Upstream.Oci; o != nil {
+		pr.Printf("Fetching upstream from %s\n", o.Image)
+		puller := fetch.NewPuller(o.Image)
+		if err := puller.Pull(ctx); err != nil {
+			return nil, err
+		}
+		return puller, nil
+	}
+
+	g := kf.Upstream.Git
+	updated := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
+	pr.Printf("Fetching upstream from %s@%s\n", g.Repo, g.Ref)
+	cloner := fetch.NewCloner(updated, fetch.WithCachedRepo(u.cachedUpstreamRepos))
+	if err := cloner.ClonerUsingGitExec(ctx); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// fetchOrigin fetches the version of the upstream package referenced in the
+// upstreamLock of the Kptfile. If the package has never been fetched, origin
+// is an empty directory.
+func (u Command) fetchOrigin(ctx context.Context, kf *kptfilev1.KptFile) (repoClone, error) {
+	pr := printer.FromContextOrDie(ctx)
+
+	switch {
+	case kf.UpstreamLock == nil:
+		return newNilRepoClone()
+	case kf.UpstreamLock.Oci != nil:
+		oLock := kf.UpstreamLock.Oci
+		image, err := ociutil.ImageWithRef(oLock.Image, oLock.Digest)
+		if err != nil {
+			return nil, err
+		}
+		pr.Printf("Fetching origin from %s\n", oLock.Image)
+		puller := fetch.NewPuller(image)
+		if err := puller.Pull(ctx); err != nil {
+			return nil, err
+		}
+		return puller, nil
+	default:
+		gLock := kf.UpstreamLock.Git
+		originRepoSpec := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
+		if g := kf.Upstream.Git; g != nil {
+			pr.Printf("Fetching origin from %s@%s\n", g.Repo, g.Ref)
+		} else {
+			pr.Printf("Fetching origin from %s@%s\n", gLock.Repo, gLock.Ref)
+		}
+		if err := fetch.NewCloner(originRepoSpec, fetch.WithCachedRepo(u.cachedUpstreamRepos)).ClonerUsingGitExec(ctx); err != nil {
+			return nil, err
+		}
+		return originRepoSpec, nil
+	}
+}
+
 // updatePackage takes care of updating a single package. The absolute paths to
 // the local, updated and origin packages are provided, as well as the path to the
 // package relative to the root.
@@ -461,15 +633,18 @@ func (u Command) mergePackage(ctx context.Context, localPath, updatedPath, origi
 	updater, found := strategies[pkgKf.Upstream.UpdateStrategy]
 	if !found {
 		return errors.E(op, types.UniquePath(localPath),
-			fmt.Errorf("unrecognized update strategy %s", u.Strategy))
+			fmt.Errorf("unrecognized update strategy %s", pkgKf.Upstream.UpdateStrategy))
 	}
 	pr.Printf("Updating package %q with strategy %q.\n", packageName(localPath), pkgKf.Upstream.UpdateStrategy)
 	if err := updater().Update(Options{
-		RelPackagePath: relPath,
-		LocalPath:      localPath,
-		UpdatedPath:    updatedPath,
-		OriginPath:     originPath,
-		IsRoot:         isRootPkg,
+		RelPackagePath:  relPath,
+		LocalPath:       localPath,
+		UpdatedPath:     updatedPath,
+		OriginPath:      originPath,
+		IsRoot:          isRootPkg,
+		ConflictMode:    u.ConflictMode,
+		Ctx:             ctx,
+		FnRunnerOptions: u.FnRunnerOptions,
 	}); err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
@@ -22,11 +22,14 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
 	. "github.com/GoogleContainerTools/kpt/internal/util/update"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
@@ -884,6 +887,67 @@ func TestCommand_Run_toTagRef(t *testing.T) {
 	}
 }
 
+// TestCommand_Run_oci updates a package fetched from an OCI registry to a
+// different tag of the image.
+func TestCommand_Run_oci(t *testing.T) {
+	for i := range kptfilev1.UpdateStrategies {
+		strategy := kptfilev1.UpdateStrategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			dataPath, err := testutil.GetTestDataPath()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			image := testutil.StartOciRegistry(t) + "/blueprints/" + testPackageName
+			testutil.PushOciPackage(t, image+":v1", filepath.Join(dataPath, testutil.Dataset1))
+			digest := testutil.PushOciPackage(t, image+":v2", filepath.Join(dataPath, testutil.Dataset2))
+
+			w, clean := testutil.SetupWorkspace(t)
+			defer clean()
+			localPath := filepath.Join(w.WorkspaceDirectory, testPackageName)
+			if !assert.NoError(t, (get.Command{
+				Oci:            &kptfilev1.Oci{Image: image + ":v1"},
+				Destination:    localPath,
+				UpdateStrategy: strategy,
+			}).Run(fake.CtxWithDefaultPrinter())) {
+				t.FailNow()
+			}
+
+			// Update the local package
+			if !assert.NoError(t, (&Command{
+				Pkg:      pkgtest.CreatePkgOrFail(t, localPath),
+				Strategy: strategy,
+				Ref:      "v2",
+			}).Run(fake.CtxWithDefaultPrinter())) {
+				t.FailNow()
+			}
+
+			// Expect the local package to have Dataset2
+			diff, err := testutil.Diff(filepath.Join(dataPath, testutil.Dataset2), localPath, true)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Empty(t, diff.Difference(testutil.KptfileSet).List())
+
+			kf, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, localPath)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, &kptfilev1.Upstream{
+				Type:           kptfilev1.OciOrigin,
+				Oci:            &kptfilev1.Oci{Image: image + ":v2"},
+				UpdateStrategy: strategy,
+			}, kf.Upstream)
+			assert.Equal(t, &kptfilev1.UpstreamLock{
+				Type: kptfilev1.OciOrigin,
+				Oci: &kptfilev1.OciLock{
+					Image:  image + ":v2",
+					Digest: digest,
+				},
+			}, kf.UpstreamLock)
+		})
+	}
+}
+
 // TestCommand_ResourceMerge_NonKRMUpdates tests if the local non KRM files are updated
 func TestCommand_ResourceMerge_NonKRMUpdates(t *testing.T) {
 	strategies := []kptfilev1.UpdateStrategyType{kptfilev1.ResourceMerge}
@@ -2155,6 +2219,126 @@ func TestRun_remote_subpackages(t *testing.T) {
 						WithResource(pkgbuilder.ConfigMapResource),
 				),
 		},
+		"declared remote subpackages are updated based on the declaration in upstream": {
+			reposChanges: map[string][]testutil.Content{
+				testutil.Upstream: {
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(
+								pkgbuilder.NewKptfile().
+									WithSubpackages(pkgbuilder.RemoteSubpackage{
+										RepoRef:   "foo",
+										Directory: "/",
+										Ref:       masterBranch,
+										LocalDir:  "foo",
+									}),
+							),
+						Branch: masterBranch,
+					},
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(
+								pkgbuilder.NewKptfile().
+									WithSubpackages(pkgbuilder.RemoteSubpackage{
+										RepoRef:   "foo",
+										Directory: "/",
+										Ref:       "v1.0",
+										LocalDir:  "foo",
+									}),
+							).
+							WithResource(pkgbuilder.DeploymentResource),
+					},
+				},
+				"foo": {
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile().
+							WithResource(pkgbuilder.DeploymentResource),
+						Branch: masterBranch,
+					},
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile().
+							WithResource(pkgbuilder.ConfigMapResource),
+						Tag: "v1.0",
+					},
+				},
+			},
+			expectedLocal: pkgbuilder.NewRootPkg().
+				WithKptfile(
+					pkgbuilder.NewKptfile().
+						WithUpstreamRef("upstream", "/", masterBranch, "resource-merge").
+						WithUpstreamLockRef("upstream", "/", masterBranch, 1).
+						WithSubpackages(pkgbuilder.RemoteSubpackage{
+							RepoRef:   "foo",
+							Directory: "/",
+							Ref:       "v1.0",
+							LocalDir:  "foo",
+						}),
+				).
+				WithResource(pkgbuilder.DeploymentResource).
+				WithSubPackages(
+					pkgbuilder.NewSubPkg("foo").
+						WithKptfile(
+							pkgbuilder.NewKptfile().
+								WithUpstreamRef("foo", "/", "v1.0", "resource-merge").
+								WithUpstreamLockRef("foo", "/", "v1.0", 1),
+						).
+						WithResource(pkgbuilder.ConfigMapResource),
+				),
+		},
+		"remote subpackage declared in upstream is fetched": {
+			reposChanges: map[string][]testutil.Content{
+				testutil.Upstream: {
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(),
+						Branch: masterBranch,
+					},
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile(
+								pkgbuilder.NewKptfile().
+									WithSubpackages(pkgbuilder.RemoteSubpackage{
+										RepoRef:   "foo",
+										Directory: "/",
+										Ref:       masterBranch,
+										LocalDir:  "foo",
+									}),
+							),
+					},
+				},
+				"foo": {
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithKptfile().
+							WithResource(pkgbuilder.DeploymentResource),
+						Branch: masterBranch,
+					},
+				},
+			},
+			expectedLocal: pkgbuilder.NewRootPkg().
+				WithKptfile(
+					pkgbuilder.NewKptfile().
+						WithUpstreamRef("upstream", "/", masterBranch, "resource-merge").
+						WithUpstreamLockRef("upstream", "/", masterBranch, 1).
+						WithSubpackages(pkgbuilder.RemoteSubpackage{
+							RepoRef:   "foo",
+							Directory: "/",
+							Ref:       masterBranch,
+							LocalDir:  "foo",
+						}),
+				).
+				WithSubPackages(
+					pkgbuilder.NewSubPkg("foo").
+						WithKptfile(
+							pkgbuilder.NewKptfile().
+								WithUpstreamRef("foo", "/", masterBranch, "resource-merge").
+								WithUpstreamLockRef("foo", "/", masterBranch, 0),
+						).
+						WithResource(pkgbuilder.DeploymentResource),
+				),
+		},
 		"subpackage with changes can not be updated with fast-forward strategy": {
 			reposChanges: map[string][]testutil.Content{
 				testutil.Upstream: {
@@ -3869,10 +4053,131 @@ func TestReplaceNonKRMFiles(t *testing.T) {
 			// expectedLocal.
 			err = os.WriteFile(filepath.Join(updated, "new.yaml"), []byte("a: b"), 0600)
 			assert.NoError(t, err)
-			err = ReplaceNonKRMFiles(updated, original, local)
+			conflicts, err := ReplaceNonKRMFiles(updated, original, local, merge.ConflictUseUpstream)
 			assert.NoError(t, err)
+			assert.Empty(t, conflicts)
 			tg := testutil.TestGitRepo{}
 			tg.AssertEqual(t, local, expectedLocal, false)
 		})
 	}
 }
+
+// TestReplaceNonKRMFiles_merge tests if the changes to the non KRM files
+// modified locally are merged line by line.
+func TestReplaceNonKRMFiles_merge(t *testing.T) {
+	testCases := map[string]struct {
+		original          string
+		local             string
+		updated           string
+		conflictMode      merge.ConflictMode
+		expected          string
+		expectedConflicts []string
+		expectedErr       string
+	}{
+		"upstream unchanged": {
+			original: "a\nb\nc\n",
+			local:    "a\nB\nc\n",
+			updated:  "a\nb\nc\n",
+			expected: "a\nB\nc\n",
+		},
+		"non-overlapping changes": {
+			original: "title\n\nintro\n\nusage\n\nlicense\n",
+			local:    "title\n\nlocal intro\n\nusage\n\nlicense\n",
+			updated:  "title\n\nintro\n\nusage\nmore usage\n\nnew license\n",
+			expected: "title\n\nlocal intro\n\nusage\nmore usage\n\nnew license\n",
+		},
+		"same changes": {
+			original: "a\nb\nc\n",
+			local:    "a\nB\nc\nd\n",
+			updated:  "a\nB\nc\n",
+			expected: "a\nB\nc\nd\n",
+		},
+		"lines deleted in upstream": {
+			original: "a\nb\nc\nd\n",
+			local:    "A\nb\nc\nd\n",
+			updated:  "a\nb\n",
+			expected: "A\nb\n",
+		},
+		"conflicting changes": {
+			original:          "a\nb\nc\nd\n",
+			local:             "a\nlocal b\nc\nD\n",
+			updated:           "A\nupstream b\nc\nd\n",
+			conflictMode:      merge.ConflictUseUpstream,
+			expected:          "a\nlocal b\nc\nD\n",
+			expectedConflicts: []string{"README.md"},
+		},
+		"conflicting changes without conflict mode": {
+			original:          "a\nb\nc\n",
+			local:             "a\nlocal b\nc\n",
+			updated:           "a\nupstream b\nc\n",
+			expected:          "a\nlocal b\nc\n",
+			expectedConflicts: []string{"README.md"},
+		},
+		"conflicting changes annotated": {
+			original:          "a\nb\nc\n",
+			local:             "a\nlocal b\nc\n",
+			updated:           "a\nupstream b\nc",
+			conflictMode:      merge.ConflictAnnotate,
+			expected:          "a\n<<<<<<< local\nlocal b\nc\n=======\nupstream b\nc\n>>>>>>> upstream\n",
+			expectedConflicts: []string{"README.md"},
+		},
+		"conflicting changes fail": {
+			original:     "a\nb\nc\n",
+			local:        "a\nlocal b\nc\n",
+			updated:      "a\nupstream b\nc\n",
+			conflictMode: merge.ConflictFail,
+			expected:     "a\nlocal b\nc\n",
+			expectedErr:  "README.md: lines changed both locally and in upstream",
+		},
+		"added in both": {
+			local:             "local\n",
+			updated:           "upstream\n",
+			conflictMode:      merge.ConflictUseUpstream,
+			expected:          "local\n",
+			expectedConflicts: []string{"README.md"},
+		},
+		"added in both annotated": {
+			local:             "local\n",
+			updated:           "upstream\n",
+			conflictMode:      merge.ConflictAnnotate,
+			expected:          "<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\n",
+			expectedConflicts: []string{"README.md"},
+		},
+		"binary file": {
+			original: "a\x00b\n",
+			local:    "a\x00B\n",
+			updated:  "A\x00b\n",
+			expected: "a\x00B\n",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			updated := t.TempDir()
+			original := t.TempDir()
+			local := t.TempDir()
+			if tc.original != "" {
+				err := os.WriteFile(filepath.Join(original, "README.md"), []byte(tc.original), 0600)
+				assert.NoError(t, err)
+			}
+			err := os.WriteFile(filepath.Join(local, "README.md"), []byte(tc.local), 0600)
+			assert.NoError(t, err)
+			err = os.WriteFile(filepath.Join(updated, "README.md"), []byte(tc.updated), 0600)
+			assert.NoError(t, err)
+
+			conflicts, err := ReplaceNonKRMFiles(updated, original, local, tc.conflictMode)
+			if tc.expectedErr != "" {
+				var conflictErr *merge.ConflictError
+				assert.True(t, errors.As(err, &conflictErr))
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			} else if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedConflicts, conflicts)
+			b, err := os.ReadFile(filepath.Join(local, "README.md"))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+}
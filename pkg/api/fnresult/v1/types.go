@@ -29,6 +29,9 @@ type Result struct {
 	// If user provides an executable file with commands, ExecPath should
 	// contain the entire input string.
 	ExecPath string `yaml:"exec,omitempty"`
+	// Starlark is the path of the script of a starlark function, or
+	// "inline" if its source is inline.
+	Starlark string `yaml:"starlark,omitempty"`
 	// TODO(droot): This is required for making structured results subpackage aware.
 	// Enable this once test harness supports filepath based assertions.
 	// Pkg is OS specific Absolute path to the package.
@@ -67,6 +67,10 @@ type KptFile struct {
 	// UpstreamLock is a resolved locator for the last fetch of the package.
 	UpstreamLock *UpstreamLock `yaml:"upstreamLock,omitempty" json:"upstreamLock,omitempty"`
 
+	// Subpackages declares the subpackages of the package. Remote subpackages
+	// are fetched and updated together with the package.
+	Subpackages []Subpackage `yaml:"subpackages,omitempty" json:"subpackages,omitempty"`
+
 	// Info contains metadata such as license, documentation, etc.
 	Info *PackageInfo `yaml:"info,omitempty" json:"info,omitempty"`
 
@@ -85,6 +89,9 @@ type OriginType string
 const (
 	// GitOrigin specifies a package as having been cloned from a git repository.
 	GitOrigin OriginType = "git"
+
+	// OciOrigin specifies a package as having been pulled from an OCI registry.
+	OciOrigin OriginType = "oci"
 )
 
 // UpdateStrategyType defines the strategy for updating a package from upstream.
@@ -92,18 +99,15 @@ type UpdateStrategyType string
 
 // ToUpdateStrategy takes a string representing an update strategy and will
 // return the strategy as an UpdateStrategyType. If the provided string does
-// not match any known update strategies, an error will be returned.
+// not match any known or registered update strategies, an error will be
+// returned.
 func ToUpdateStrategy(strategy string) (UpdateStrategyType, error) {
-	switch strategy {
-	case string(ResourceMerge):
-		return ResourceMerge, nil
-	case string(FastForward):
-		return FastForward, nil
-	case string(ForceDeleteReplace):
-		return ForceDeleteReplace, nil
-	default:
-		return "", fmt.Errorf("unknown update strategy %q", strategy)
+	for _, s := range append(UpdateStrategies, customUpdateStrategies...) {
+		if string(s) == strategy {
+			return s, nil
+		}
 	}
+	return "", fmt.Errorf("unknown update strategy %q", strategy)
 }
 
 const (
@@ -117,17 +121,36 @@ const (
 	ForceDeleteReplace UpdateStrategyType = "force-delete-replace"
 )
 
-// UpdateStrategies is a slice with all the supported update strategies.
+// UpdateStrategies is a slice with all the built-in update strategies.
 var UpdateStrategies = []UpdateStrategyType{
 	ResourceMerge,
 	FastForward,
 	ForceDeleteReplace,
 }
 
-// UpdateStrategiesAsStrings returns a list of update strategies as strings.
+// customUpdateStrategies are the update strategies added with
+// RegisterUpdateStrategy.
+var customUpdateStrategies []UpdateStrategyType
+
+// RegisterUpdateStrategy adds a custom update strategy, so it is accepted by
+// ToUpdateStrategy. It is not safe for concurrent use and is meant to be
+// called from an init function.
+func RegisterUpdateStrategy(strategy UpdateStrategyType) error {
+	if strategy == "" {
+		return fmt.Errorf("update strategy must not be empty")
+	}
+	if _, err := ToUpdateStrategy(string(strategy)); err == nil {
+		return fmt.Errorf("update strategy %q is already registered", strategy)
+	}
+	customUpdateStrategies = append(customUpdateStrategies, strategy)
+	return nil
+}
+
+// UpdateStrategiesAsStrings returns a list of the built-in and custom update
+// strategies as strings.
 func UpdateStrategiesAsStrings() []string {
 	var strs []string
-	for _, s := range UpdateStrategies {
+	for _, s := range append(UpdateStrategies, customUpdateStrategies...) {
 		strs = append(strs, string(s))
 	}
 	return strs
@@ -141,8 +164,16 @@ type Upstream struct {
 	// Git is the locator for a package stored on Git.
 	Git *Git `yaml:"git,omitempty" json:"git,omitempty"`
 
+	// Oci is the locator for a package stored in an OCI registry.
+	Oci *Oci `yaml:"oci,omitempty" json:"oci,omitempty"`
+
 	// UpdateStrategy declares how a package will be updated from upstream.
 	UpdateStrategy UpdateStrategyType `yaml:"updateStrategy,omitempty" json:"updateStrategy,omitempty"`
+
+	// MergeFunction is the KRM function merging the resources with the
+	// function-merge update strategy. It receives the resources of the
+	// original, updated and local package and returns the merged ones.
+	MergeFunction *Function `yaml:"mergeFunction,omitempty" json:"mergeFunction,omitempty"`
 }
 
 // Git is the user-specified locator for a package on Git.
@@ -166,6 +197,9 @@ type UpstreamLock struct {
 
 	// Git is the resolved locator for a package on Git.
 	Git *GitLock `yaml:"git,omitempty" json:"git,omitempty"`
+
+	// Oci is the resolved locator for a package in an OCI registry.
+	Oci *OciLock `yaml:"oci,omitempty" json:"oci,omitempty"`
 }
 
 // GitLock is the resolved locator for a package on Git.
@@ -187,6 +221,24 @@ type GitLock struct {
 	Commit string `yaml:"commit,omitempty" json:"commit,omitempty"`
 }
 
+// Oci is the user-specified locator for a package in an OCI registry.
+type Oci struct {
+	// Image is the image holding the package, referenced by tag or digest.
+	// e.g. 'us-docker.pkg.dev/my-project/blueprints/nginx:v1'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+}
+
+// OciLock is the resolved locator for a package in an OCI registry.
+type OciLock struct {
+	// Image is the image that was pulled.
+	// e.g. 'us-docker.pkg.dev/my-project/blueprints/nginx:v1'
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+
+	// Digest is the digest of the image for the last fetch of the package.
+	// This is set by kpt for bookkeeping purposes.
+	Digest string `yaml:"digest,omitempty" json:"digest,omitempty"`
+}
+
 // PackageInfo contains optional information about the package such as license, documentation, etc.
 // These fields are not consumed by any functionality in kpt and are simply passed through.
 // Note that like any other KRM resource, humans and automation can also use `metadata.labels` and
@@ -246,7 +298,7 @@ type Pipeline struct {
 	// - When using './*': Subpackages are resolved in alphanumerical order before package resources.
 	//
 	// When omitted, defaults to './*'.
-	// Sources []string `yaml:"sources,omitempty"`
+	Sources []string `yaml:"sources,omitempty" json:"sources,omitempty"`
 
 	// Following fields define the sequence of functions in the pipeline.
 	// Input of the first function is the resolved sources.
@@ -261,6 +313,25 @@ type Pipeline struct {
 	Validators []Function `yaml:"validators,omitempty" json:"validators,omitempty"`
 }
 
+const (
+	// SourceCurrentPkg refers to the resources of the current package
+	// excluding its subpackages.
+	SourceCurrentPkg = "."
+
+	// SourceAllSubPkgs refers to the resources of the current package and
+	// all of its resolved subpackages.
+	SourceAllSubPkgs = "./*"
+)
+
+// ResolvedSources returns the sources of the pipeline, defaulting to './*'
+// when none are specified.
+func (p *Pipeline) ResolvedSources() []string {
+	if p == nil || len(p.Sources) == 0 {
+		return []string{SourceAllSubPkgs}
+	}
+	return p.Sources
+}
+
 // String returns the string representation of Pipeline struct
 // The string returned is the struct content in Go default format.
 func (p *Pipeline) String() string {
@@ -301,6 +372,19 @@ type Function struct {
 	// 	 exec: /usr/local/bin/my-custom-fn
 	Exec string `yaml:"exec,omitempty" json:"exec,omitempty"`
 
+	// `Starlark` specifies a Starlark script that is run in-process, without
+	// a container image or executable. The script is either inline:
+	//
+	//	starlark:
+	//	  source: |
+	//	    ...
+	//
+	// or read from a file in the package directory:
+	//
+	//	starlark:
+	//	  scriptPath: scripts/set-owner.star
+	Starlark *StarlarkFunction `yaml:"starlark,omitempty" json:"starlark,omitempty"`
+
 	// `ConfigPath` specifies a slash-delimited relative path to a file in the current directory
 	// containing a KRM resource used as the function config. This resource is
 	// excluded when resolving 'sources', and as a result cannot be operated on
@@ -321,6 +405,65 @@ type Function struct {
 	// `Exclude` are used to specify resources on which the function should NOT be executed.
 	// If not specified, all resources selected by `Selectors` are selected.
 	Exclusions []Selector `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+
+	// `When` is an expression that is evaluated against the input resources
+	// before the function is executed. The function is skipped if it evaluates
+	// to false. The input resources are available as the `resources` list, e.g.
+	//
+	//	when: resources.exists(r, r.kind == 'ConfigMap' && r.data.env == 'prod')
+	//
+	// The expression uses the Common Expression Language (CEL). If not
+	// specified, the function is always executed.
+	When string `yaml:"when,omitempty" json:"when,omitempty"`
+
+	// `Timeout` is the maximum duration the function is allowed to run,
+	// e.g. 10m. Defaults to 5 minutes.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// `Limits` are the compute resources the function container is limited to.
+	// If not specified, the function container is not limited.
+	Limits *FunctionLimits `yaml:"limits,omitempty" json:"limits,omitempty"`
+
+	// `Network` requests network access for the function container. Network
+	// access must also be allowed by the user running the function, e.g.
+	// with `kpt fn render --allow-network-for`.
+	Network bool `yaml:"network,omitempty" json:"network,omitempty"`
+
+	// `Mounts` are directories or files of the package that are mounted
+	// read-only into the function container. Mounts must also be allowed by
+	// the user running the function, e.g. with `kpt fn render --allow-mount-for`.
+	Mounts []FunctionMount `yaml:"mounts,omitempty" json:"mounts,omitempty"`
+}
+
+// StarlarkFunction is a Starlark script run as a function. The script can
+// read and modify the function input `ResourceList`, bound to
+// ctx.resource_list, and report results with ctx.resource_list["results"].
+type StarlarkFunction struct {
+	// `Source` is the inline source of the script.
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+
+	// `ScriptPath` specifies a slash-delimited relative path to a file in the
+	// current directory containing the script.
+	ScriptPath string `yaml:"scriptPath,omitempty" json:"scriptPath,omitempty"`
+}
+
+// FunctionLimits are the compute resources a function container is limited to.
+type FunctionLimits struct {
+	// Memory is the maximum amount of memory, e.g. 512Mi.
+	Memory string `yaml:"memory,omitempty" json:"memory,omitempty"`
+	// CPU is the maximum number of CPUs, e.g. 500m or 2.
+	CPU string `yaml:"cpu,omitempty" json:"cpu,omitempty"`
+}
+
+// FunctionMount is a directory or file of the package mounted read-only into
+// a function container.
+type FunctionMount struct {
+	// Src is the slash-delimited path of the directory or file relative to
+	// the package directory.
+	Src string `yaml:"src" json:"src"`
+	// Dst is the absolute path the directory or file is mounted at in the
+	// container.
+	Dst string `yaml:"dst" json:"dst"`
 }
 
 // Selector specifies the selection criteria
@@ -338,6 +481,26 @@ type Selector struct {
 	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
 	// Annotations on the target resources
 	Annotations map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+	// Fields of the target resources. A resource matches if it matches all
+	// of the field selectors.
+	Fields []FieldSelector `yaml:"fields,omitempty" json:"fields,omitempty"`
+	// Match is an expression the target resources must satisfy, e.g.
+	// `spec.replicas > 1`. The fields of the resource are available as
+	// variables, and the expression uses the Common Expression Language
+	// (CEL). Resources which don't have a field the expression selects
+	// don't match.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+}
+
+// FieldSelector selects resources by the value of a field.
+type FieldSelector struct {
+	// Path of the field, e.g. spec.template.spec.containers[*].image.
+	// `[*]` selects all elements of a list or all values of a map.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+	// Value is a glob pattern the value of the field must match, e.g.
+	// `nginx:*`. If the path selects multiple fields, at least one of them
+	// must match. If not specified, the field must be set.
+	Value string `yaml:"value,omitempty" json:"value,omitempty"`
 }
 
 // IsEmpty returns true of none of the selection criteria is specified
@@ -347,7 +510,9 @@ func (s Selector) IsEmpty() bool {
 		s.Name == "" &&
 		s.Kind == "" &&
 		len(s.Labels) == 0 &&
-		len(s.Annotations) == 0
+		len(s.Annotations) == 0 &&
+		len(s.Fields) == 0 &&
+		s.Match == ""
 }
 
 // Inventory encapsulates the parameters for the inventory resource applied to a cluster.
@@ -370,6 +535,20 @@ func (i Inventory) IsValid() bool {
 
 type Status struct {
 	Conditions []Condition `yaml:"conditions,omitempty" json:"conditions,omitempty"`
+
+	// ImageLocks are the digests the function images of the pipeline are
+	// locked to by `kpt fn lock`. Locked images are run by their digest.
+	ImageLocks []ImageLock `yaml:"imageLocks,omitempty" json:"imageLocks,omitempty"`
+}
+
+// ImageLock is the digest a function image is locked to.
+type ImageLock struct {
+	// Image is the function image as specified in the pipeline, with the
+	// default registry prepended if it was omitted.
+	Image string `yaml:"image" json:"image"`
+
+	// Digest is the digest of the image, e.g. sha256:4f4f...
+	Digest string `yaml:"digest" json:"digest"`
 }
 
 type Condition struct {
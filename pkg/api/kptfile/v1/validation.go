@@ -16,11 +16,14 @@ package v1
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/types"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"sigs.k8s.io/kustomize/api/konfig"
 	kustomizetypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
@@ -38,6 +41,9 @@ func (kf *KptFile) Validate(fsys filesys.FileSystem, pkgPath types.UniquePath) e
 	if err := kf.Pipeline.validate(fsys, pkgPath); err != nil {
 		return fmt.Errorf("invalid pipeline: %w", err)
 	}
+	if err := validateSubpackages(kf.Subpackages); err != nil {
+		return fmt.Errorf("invalid subpackages: %w", err)
+	}
 	// TODO: validate other fields
 	return nil
 }
@@ -49,6 +55,9 @@ func (p *Pipeline) validate(fsys filesys.FileSystem, pkgPath types.UniquePath) e
 	if p == nil {
 		return nil
 	}
+	if err := p.validateSources(); err != nil {
+		return err
+	}
 	for i := range p.Mutators {
 		f := p.Mutators[i]
 		err := f.validate(fsys, "mutators", i, pkgPath)
@@ -66,17 +75,145 @@ func (p *Pipeline) validate(fsys filesys.FileSystem, pkgPath types.UniquePath) e
 	return nil
 }
 
+// validateSubpackages validates the declared subpackages. The localDir of
+// a subpackage must be a unique immediate subdirectory of the package, and
+// remote subpackages must specify a git repo, directory and ref.
+func validateSubpackages(subpkgs []Subpackage) error {
+	seen := map[string]bool{}
+	for i, sp := range subpkgs {
+		field := fmt.Sprintf("subpackages[%d]", i)
+		if sp.LocalDir == "" || sp.LocalDir == "." || sp.LocalDir == ".." ||
+			strings.ContainsAny(sp.LocalDir, `/\`) {
+			return &ValidateError{
+				Field:  field + ".localDir",
+				Value:  sp.LocalDir,
+				Reason: "localDir must be the name of an immediate subdirectory",
+			}
+		}
+		if seen[sp.LocalDir] {
+			return &ValidateError{
+				Field:  field + ".localDir",
+				Value:  sp.LocalDir,
+				Reason: "localDir must be unique across all subpackages",
+			}
+		}
+		seen[sp.LocalDir] = true
+		if sp.Upstream == nil {
+			continue
+		}
+		if sp.Upstream.Type != "" && sp.Upstream.Type != GitOrigin {
+			return &ValidateError{
+				Field:  field + ".upstream.type",
+				Value:  string(sp.Upstream.Type),
+				Reason: "unsupported upstream type",
+			}
+		}
+		g := sp.Upstream.Git
+		if g == nil || g.Repo == "" || g.Directory == "" || g.Ref == "" {
+			return &ValidateError{
+				Field:  field + ".upstream.git",
+				Reason: "must specify `repo`, `directory` and `ref`",
+			}
+		}
+		if sp.Upstream.UpdateStrategy != "" {
+			if _, err := ToUpdateStrategy(string(sp.Upstream.UpdateStrategy)); err != nil {
+				return &ValidateError{
+					Field:  field + ".upstream.updateStrategy",
+					Value:  string(sp.Upstream.UpdateStrategy),
+					Reason: err.Error(),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateSources validates the sources of the pipeline. A source must either
+// be one of the special values '.' and './*' or a slash-separated relative
+// path to a package. A source may be specified at most once.
+func (p *Pipeline) validateSources() error {
+	seen := map[string]bool{}
+	for i, src := range p.Sources {
+		field := fmt.Sprintf("pipeline.sources[%d]", i)
+		if strings.TrimSpace(src) == "" {
+			return &ValidateError{
+				Field:  field,
+				Reason: "source must not be empty",
+			}
+		}
+		if src != SourceCurrentPkg && src != SourceAllSubPkgs {
+			if path.IsAbs(src) || filepath.IsAbs(src) {
+				return &ValidateError{
+					Field:  field,
+					Value:  src,
+					Reason: "source must be a relative package path",
+				}
+			}
+			if strings.Contains(src, "*") {
+				return &ValidateError{
+					Field:  field,
+					Value:  src,
+					Reason: "wildcards are only supported as './*'",
+				}
+			}
+			if path.Clean(src) == SourceCurrentPkg {
+				return &ValidateError{
+					Field:  field,
+					Value:  src,
+					Reason: fmt.Sprintf("use %q to refer to the current package", SourceCurrentPkg),
+				}
+			}
+		}
+		key := src
+		if src != SourceAllSubPkgs {
+			key = path.Clean(src)
+		}
+		if seen[key] {
+			return &ValidateError{
+				Field:  field,
+				Value:  src,
+				Reason: "source must not be specified more than once",
+			}
+		}
+		seen[key] = true
+	}
+	if !seen[SourceAllSubPkgs] {
+		return nil
+	}
+	// './*' already includes the current package and all the packages
+	// nested inside it.
+	for i, src := range p.Sources {
+		if src == SourceAllSubPkgs {
+			continue
+		}
+		if src = path.Clean(src); src != ".." && !strings.HasPrefix(src, "../") {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.sources[%d]", i),
+				Value:  p.Sources[i],
+				Reason: fmt.Sprintf("source is already included by %q", SourceAllSubPkgs),
+			}
+		}
+	}
+	return nil
+}
+
 func (f *Function) validate(fsys filesys.FileSystem, fnType string, idx int, pkgPath types.UniquePath) error {
-	if f.Image == "" && f.Exec == "" {
+	specified := 0
+	for _, set := range []bool{f.Image != "", f.Exec != "", f.Starlark != nil} {
+		if set {
+			specified++
+		}
+	}
+	if specified == 0 {
 		return &ValidateError{
 			Field:  fmt.Sprintf("pipeline.%s[%d]", fnType, idx),
-			Reason: "must specify a functon (`image` or `exec`) to execute",
+			Reason: "must specify a functon (`image`, `exec` or `starlark`) to execute",
 		}
 	}
-	if f.Image != "" && f.Exec != "" {
+	if specified > 1 {
 		return &ValidateError{
 			Field:  fmt.Sprintf("pipeline.%s[%d]", fnType, idx),
-			Reason: "must not specify both `image` and `exec` at the same time",
+			Reason: "must specify only one of `image`, `exec` and `starlark`",
 		}
 	}
 	if f.Image != "" {
@@ -90,6 +227,11 @@ func (f *Function) validate(fsys filesys.FileSystem, fnType string, idx int, pkg
 		}
 	}
 	// TODO(droot): validate the exec
+	if f.Starlark != nil {
+		if err := f.Starlark.validate(fsys, fmt.Sprintf("pipeline.%s[%d].starlark", fnType, idx), pkgPath); err != nil {
+			return err
+		}
+	}
 
 	if len(f.ConfigMap) != 0 && f.ConfigPath != "" {
 		return &ValidateError{
@@ -98,6 +240,10 @@ func (f *Function) validate(fsys filesys.FileSystem, fnType string, idx int, pkg
 		}
 	}
 
+	if err := f.validateLimits(fmt.Sprintf("pipeline.%s[%d]", fnType, idx)); err != nil {
+		return err
+	}
+
 	if f.ConfigPath != "" {
 		if err := validateFnConfigPathSyntax(f.ConfigPath); err != nil {
 			return &ValidateError{
@@ -117,6 +263,103 @@ func (f *Function) validate(fsys filesys.FileSystem, fnType string, idx int, pkg
 	return nil
 }
 
+func (s *StarlarkFunction) validate(fsys filesys.FileSystem, field string, pkgPath types.UniquePath) error {
+	if (s.Source == "") == (s.ScriptPath == "") {
+		return &ValidateError{
+			Field:  field,
+			Reason: "must specify either `source` or `scriptPath`",
+		}
+	}
+	if s.ScriptPath == "" {
+		return nil
+	}
+	if err := validateFnConfigPathSyntax(s.ScriptPath); err != nil {
+		return &ValidateError{
+			Field:  field + ".scriptPath",
+			Value:  s.ScriptPath,
+			Reason: err.Error(),
+		}
+	}
+	if !fsys.Exists(filepath.Join(string(pkgPath), s.ScriptPath)) {
+		return &ValidateError{
+			Field:  field + ".scriptPath",
+			Value:  s.ScriptPath,
+			Reason: "script must exist in the current package",
+		}
+	}
+	return nil
+}
+
+// validateLimits validates the timeout, limits, network and mounts of the
+// function. field is the path of the function in the Kptfile.
+func (f *Function) validateLimits(field string) error {
+	if f.Timeout != "" {
+		if d, err := time.ParseDuration(f.Timeout); err != nil || d <= 0 {
+			return &ValidateError{
+				Field:  field + ".timeout",
+				Value:  f.Timeout,
+				Reason: "must be a positive duration, e.g. 10m",
+			}
+		}
+	}
+	if (f.Exec != "" || f.Starlark != nil) && (f.Limits != nil || f.Network || len(f.Mounts) > 0) {
+		return &ValidateError{
+			Field:  field,
+			Reason: "`limits`, `network` and `mounts` are only supported for container functions (`image`)",
+		}
+	}
+	if f.Limits != nil {
+		for _, l := range []struct{ name, q string }{{"memory", f.Limits.Memory}, {"cpu", f.Limits.CPU}} {
+			name, q := l.name, l.q
+			if q == "" {
+				continue
+			}
+			if v, err := resource.ParseQuantity(q); err != nil || v.Sign() <= 0 {
+				return &ValidateError{
+					Field:  field + ".limits." + name,
+					Value:  q,
+					Reason: "must be a positive quantity, e.g. 512Mi for memory or 500m for cpu",
+				}
+			}
+		}
+	}
+	for i, m := range f.Mounts {
+		if err := validateFnMountSrc(m.Src); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("%s.mounts[%d].src", field, i),
+				Value:  m.Src,
+				Reason: err.Error(),
+			}
+		}
+		if !path.IsAbs(m.Dst) {
+			return &ValidateError{
+				Field:  fmt.Sprintf("%s.mounts[%d].dst", field, i),
+				Value:  m.Dst,
+				Reason: "path must be absolute",
+			}
+		}
+	}
+	return nil
+}
+
+// validateFnMountSrc validates that the source of a mount is a path inside
+// the package directory.
+func validateFnMountSrc(p string) error {
+	if strings.TrimSpace(p) == "" {
+		return fmt.Errorf("path must not be empty")
+	}
+	p = filepath.Clean(p)
+	if filepath.IsAbs(p) {
+		return fmt.Errorf("path must be relative")
+	}
+	if p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		// mounting files outside the package would expose them to functions
+		// the package author doesn't control.
+		return fmt.Errorf("path must not be outside the package directory")
+	}
+	return nil
+}
+
 // ValidateFunctionImageURL validates the function name.
 // According to Docker implementation
 // https://github.com/docker/distribution/blob/master/reference/reference.go. A valid
@@ -93,6 +93,174 @@ func TestKptfileValidate(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "pipeline: valid limits and permissions",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Validators: []Function{
+						{
+							Image:   "image",
+							Timeout: "10m",
+							Limits:  &FunctionLimits{Memory: "512Mi", CPU: "500m"},
+							Network: true,
+							Mounts:  []FunctionMount{{Src: "schemas", Dst: "/schemas"}},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: invalid timeout",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:   "image",
+							Timeout: "-1m",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: invalid memory limit",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Limits: &FunctionLimits{Memory: "lots"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: network for exec function",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Exec:    "fn",
+							Network: true,
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: inline starlark",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkFunction{Source: "print('hello')"},
+							Timeout:  "1m",
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: starlark and image",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:    "image",
+							Starlark: &StarlarkFunction{Source: "print('hello')"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: starlark with source and script path",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkFunction{Source: "print('hello')", ScriptPath: "fn.star"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: starlark script outside of the package",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkFunction{ScriptPath: "../fn.star"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: missing starlark script",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkFunction{ScriptPath: "missing.star"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: network for starlark function",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkFunction{Source: "print('hello')"},
+							Network:  true,
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: mount outside of the package",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Mounts: []FunctionMount{{Src: "../secrets", Dst: "/secrets"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: relative mount destination",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:  "image",
+							Mounts: []FunctionMount{{Src: "schemas", Dst: "schemas"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
 		{
 			name: "pipeline: absolute config path",
 			kptfile: KptFile{
@@ -149,6 +317,122 @@ func TestKptfileValidate(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "subpackages: valid remote subpackage",
+			kptfile: KptFile{
+				Subpackages: []Subpackage{
+					{
+						LocalDir: "foo",
+						Upstream: &Upstream{
+							Git: &Git{Repo: "https://github.com/foo/bar", Directory: "/foo", Ref: "v1"},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "subpackages: localDir is not an immediate subdirectory",
+			kptfile: KptFile{
+				Subpackages: []Subpackage{
+					{
+						LocalDir: "foo/bar",
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "subpackages: duplicate localDir",
+			kptfile: KptFile{
+				Subpackages: []Subpackage{
+					{
+						LocalDir: "foo",
+					},
+					{
+						LocalDir: "foo",
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "subpackages: remote subpackage without ref",
+			kptfile: KptFile{
+				Subpackages: []Subpackage{
+					{
+						LocalDir: "foo",
+						Upstream: &Upstream{
+							Git: &Git{Repo: "https://github.com/foo/bar", Directory: "/foo"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: valid sources",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{"../base", "./overlay", "."},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: absolute source",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{"/base"},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: duplicate sources",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{"../base", "../base/"},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: unsupported wildcard source",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{"../*"},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: both current package and all subpackages",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{".", "./*"},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: all subpackages and a nested package",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{"./*", "./base"},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: all subpackages and a package outside",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Sources: []string{"../base", "./*"},
+				},
+			},
+			valid: true,
+		},
 	}
 
 	for _, c := range cases {
@@ -224,6 +224,31 @@ func UpdateUpstreamLockFromGit(path string, spec *git.RepoSpec) error {
 	return nil
 }
 
+// UpdateUpstreamLockFromOci updates the upstreamLock of the package specified
+// by path with the image and digest of the package image that was pulled.
+func UpdateUpstreamLockFromOci(path, image, digest string) error {
+	const op errors.Op = "kptfileutil.UpdateUpstreamLockFromOci"
+	// read KptFile pulled with the package if it exists
+	kpgfile, err := pkg.ReadKptfile(filesys.FileSystemOrOnDisk{}, path)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+
+	// populate the image values so we know where the package came from
+	kpgfile.UpstreamLock = &kptfilev1.UpstreamLock{
+		Type: kptfilev1.OciOrigin,
+		Oci: &kptfilev1.OciLock{
+			Image:  image,
+			Digest: digest,
+		},
+	}
+	err = WriteFile(path, kpgfile)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+	return nil
+}
+
 // merge merges the Kptfiles from various sources and updates localKf with output
 // please refer to https://github.com/GoogleContainerTools/kpt/blob/main/docs/design-docs/03-pipeline-merge.md
 // for related design
@@ -270,6 +295,7 @@ func merge(localKf, updatedKf, originalKf *kptfilev1.KptFile) error {
 	localKf.Labels = mergedKf.Labels
 	localKf.Info = mergedKf.Info
 	localKf.Pipeline = mergedKf.Pipeline
+	localKf.Subpackages = mergedKf.Subpackages
 	localKf.Inventory = mergedKf.Inventory
 	localKf.Status = mergedKf.Status
 	return nil
@@ -302,9 +328,12 @@ func shouldAddFnKeyUtil(fns []kptfilev1.Function) bool {
 			return false
 		}
 		var key string
-		if fn.Exec != "" {
+		switch {
+		case fn.Exec != "":
 			key = fn.Exec
-		} else {
+		case fn.Starlark != nil:
+			key = starlarkKey(fn.Starlark)
+		default:
 			key = strings.Split(fn.Image, ":")[0]
 		}
 		if keySet.Has(key) {
@@ -331,6 +360,15 @@ func addNameForMerge(kfs ...*kptfilev1.KptFile) {
 	}
 }
 
+// starlarkKey returns the fall back mergeKey of a starlark function, which is
+// its script path. All inline scripts share the same key.
+func starlarkKey(s *kptfilev1.StarlarkFunction) string {
+	if s.ScriptPath != "" {
+		return "starlark:" + s.ScriptPath
+	}
+	return "starlark"
+}
+
 // addName adds name field to the input function if empty
 // name is nothing but image name in this case as we use it as fall back mergeKey
 func addName(fn kptfilev1.Function) kptfilev1.Function {
@@ -338,9 +376,12 @@ func addName(fn kptfilev1.Function) kptfilev1.Function {
 		return fn
 	}
 	var key string
-	if fn.Exec != "" {
+	switch {
+	case fn.Exec != "":
 		key = fn.Exec
-	} else {
+	case fn.Starlark != nil:
+		key = starlarkKey(fn.Starlark)
+	default:
 		parts := strings.Split(fn.Image, ":")
 		if len(parts) > 0 {
 			key = parts[0]
@@ -79,6 +79,15 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 		}
 	}
 
+	module, err := os.ReadFile(wasmFile)
+	if err != nil {
+		return fmt.Errorf("failed to read from file %v: %w", wasmFile, err)
+	}
+	wasmPlatform, err := ModulePlatform(module)
+	if err != nil {
+		return fmt.Errorf("unable to parse %v: %w", wasmFile, err)
+	}
+
 	// Compress the wasm file.
 	tarReader, err := wasmFileToTar(wasmFile)
 	if err != nil {
@@ -119,11 +128,8 @@ func (r *Client) PushWasm(ctx context.Context, wasmFile string, imageName string
 		}
 	}
 
-	wasmPlatform := v1.Platform{
-		Architecture: "wasm",
-		OS:           "js",
-	}
-	index = mutate.RemoveManifests(index, match.Platforms(wasmPlatform))
+	// An image has a single wasm module, either for js or WASI.
+	index = mutate.RemoveManifests(index, match.Platforms(JSPlatform, WasiPlatform))
 	index = mutate.AppendManifests(index, mutate.IndexAddendum{
 		Add: img,
 		Descriptor: v1.Descriptor{
@@ -181,12 +187,8 @@ func (r *Client) LoadWasm(ctx context.Context, imageName string) (io.ReadCloser,
 		options := []remote.Option{
 			remote.WithContext(ctx),
 			remote.WithAuthFromKeychain(gcrane.Keychain),
-			remote.WithPlatform(v1.Platform{
-				Architecture: "wasm",
-				OS:           "js",
-			}),
 		}
-		ociImage, err := remote.Image(ref, options...)
+		ociImage, err := remoteWasmImage(ref, options...)
 		if err != nil {
 			return nil, fmt.Errorf("unable to get remote image: %w", err)
 		}
@@ -215,6 +217,32 @@ func (r *Client) LoadWasm(ctx context.Context, imageName string) (io.ReadCloser,
 	return wasmBytesReader, nil
 }
 
+// remoteWasmImage returns the image of the wasm module of ref, which is
+// either a js or a WASI module.
+func remoteWasmImage(ref name.Reference, options ...remote.Option) (v1.Image, error) {
+	desc, err := remote.Get(ref, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !desc.MediaType.IsIndex() {
+		return desc.Image()
+	}
+	index, err := desc.ImageIndex()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range manifest.Manifests {
+		if m.Platform != nil && (m.Platform.Equals(JSPlatform) || m.Platform.Equals(WasiPlatform)) {
+			return index.Image(m.Digest)
+		}
+	}
+	return nil, fmt.Errorf("no wasm module found in image index %v", ref)
+}
+
 type tarReadCloser struct {
 	*tar.Reader
 	closer io.Closer
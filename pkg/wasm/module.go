@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+const (
+	// WasiModule is the module of the functions imported by WASI preview1
+	// modules.
+	WasiModule = "wasi_snapshot_preview1"
+
+	importSectionID = 2
+)
+
+var (
+	// JSPlatform is the platform of the wasm modules compiled by Go with
+	// GOOS=js, which are run with a JS glue code.
+	JSPlatform = v1.Platform{Architecture: "wasm", OS: "js"}
+
+	// WasiPlatform is the platform of the WASI preview1 modules, which read
+	// the ResourceList from stdin and write it to stdout.
+	WasiPlatform = v1.Platform{Architecture: "wasm", OS: "wasip1"}
+
+	wasmMagic = []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+)
+
+// ModulePlatform returns the platform of the wasm module, WasiPlatform if it
+// imports WASI preview1 functions and JSPlatform otherwise.
+func ModulePlatform(module []byte) (v1.Platform, error) {
+	imports, err := importedModules(module)
+	if err != nil {
+		return v1.Platform{}, err
+	}
+	for _, m := range imports {
+		if m == WasiModule {
+			return WasiPlatform, nil
+		}
+	}
+	return JSPlatform, nil
+}
+
+// importedModules returns the modules of the imports of a wasm module in the
+// binary format.
+func importedModules(module []byte) ([]string, error) {
+	if !bytes.HasPrefix(module, wasmMagic) {
+		return nil, fmt.Errorf("not a wasm module")
+	}
+	d := &decoder{b: module[len(wasmMagic):]}
+	for len(d.b) > 0 && d.err == nil {
+		id := d.byte()
+		section := d.bytes()
+		if id != importSectionID {
+			continue
+		}
+		d = &decoder{b: section}
+		var modules []string
+		for n := d.uint(); n > 0 && d.err == nil; n-- {
+			modules = append(modules, string(d.bytes()))
+			d.bytes() // name
+			switch kind := d.byte(); kind {
+			case 0x00: // function
+				d.uint()
+			case 0x01: // table
+				d.byte()
+				d.limits()
+			case 0x02: // memory
+				d.limits()
+			case 0x03: // global
+				d.byte()
+				d.byte()
+			case 0x04: // tag
+				d.byte()
+				d.uint()
+			default:
+				if d.err == nil {
+					d.err = fmt.Errorf("unknown import kind %#x", kind)
+				}
+			}
+		}
+		return modules, d.err
+	}
+	return nil, d.err
+}
+
+// decoder decodes the values of the wasm binary format. It records the first
+// error and returns zero values afterwards.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.b) == 0 {
+		d.err = fmt.Errorf("unexpected end of wasm module")
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+// uint decodes an unsigned LEB128 integer.
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = fmt.Errorf("invalid integer in wasm module")
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// bytes decodes a vector of bytes prefixed by its length.
+func (d *decoder) bytes() []byte {
+	n := d.uint()
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.b)) < n {
+		d.err = fmt.Errorf("unexpected end of wasm module")
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) limits() {
+	if flags := d.byte(); flags&0x01 != 0 {
+		d.uint()
+		d.uint()
+		return
+	}
+	d.uint()
+}
@@ -0,0 +1,152 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evaluator_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/porch/func/evaluator"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The function runtime of kpt encodes the messages of the FunctionEvaluator
+// service without the generated code, these tests check it is compatible
+// with the generated code.
+
+type upperEvaluator struct {
+	evaluator.UnimplementedFunctionEvaluatorServer
+}
+
+func (upperEvaluator) EvaluateFunction(_ context.Context, req *evaluator.EvaluateFunctionRequest) (*evaluator.EvaluateFunctionResponse, error) {
+	return &evaluator.EvaluateFunctionResponse{
+		ResourceList: bytes.ToUpper(req.ResourceList),
+		Log:          []byte("evaluated " + req.Image),
+	}, nil
+}
+
+func TestKptClient(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	evaluator.RegisterFunctionEvaluatorServer(server, upperEvaluator{})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	rt, err := fnruntime.NewGRPCRuntime(lis.Addr().String())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer rt.Close()
+	stderr := &bytes.Buffer{}
+	ctx := printer.WithContext(context.Background(), printer.New(nil, stderr))
+	runner, err := rt.GetRunner(ctx, &kptfilev1.Function{Image: "example.com/upper:v1"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, runner.Run(strings.NewReader("kind: foo"), out)) {
+		t.FailNow()
+	}
+	assert.Equal(t, "KIND: FOO", out.String())
+	assert.Contains(t, stderr.String(), "evaluated example.com/upper:v1")
+}
+
+// upperScript writes its input in upper case. It fails with exit code 2 if
+// the input contains "fail", after writing its input unchanged.
+const upperScript = `#!/bin/sh
+input=$(cat)
+case "$input" in
+  *fail*) printf '%s' "$input"; echo "failed" >&2; exit 2 ;;
+esac
+printf '%s' "$input" | tr a-z A-Z
+echo "converted" >&2
+`
+
+func TestKptServer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test requires a shell")
+	}
+	script := filepath.Join(t.TempDir(), "upper")
+	if err := os.WriteFile(script, []byte(upperScript), 0700); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := fnruntime.NewFnEvaluatorServer(&fnruntime.FnEvaluator{
+		Executables: map[string]string{"example.com/upper:v1": script},
+	})
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	cc, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	client := evaluator.NewFunctionEvaluatorClient(cc)
+	ctx := context.Background()
+
+	res, err := client.EvaluateFunction(ctx, &evaluator.EvaluateFunctionRequest{
+		ResourceList: []byte("kind: foo"),
+		Image:        "example.com/upper:v1",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "KIND: FOO", string(res.ResourceList))
+	assert.Equal(t, "converted\n", string(res.Log))
+
+	// the output, stderr and exit code of failed functions are in the
+	// details of the status.
+	_, err = client.EvaluateFunction(ctx, &evaluator.EvaluateFunctionRequest{
+		ResourceList: []byte("kind: fail"),
+		Image:        "example.com/upper:v1",
+	})
+	st, ok := status.FromError(err)
+	if !assert.True(t, ok) {
+		t.FailNow()
+	}
+	assert.Contains(t, st.Message(), `failed to evaluate function "example.com/upper:v1"`)
+	var details []interface{}
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *wrapperspb.BytesValue:
+			details = append(details, string(d.Value))
+		case *wrapperspb.StringValue:
+			details = append(details, d.Value)
+		case *wrapperspb.Int32Value:
+			details = append(details, d.Value)
+		}
+	}
+	assert.Equal(t, []interface{}{"kind: fail", "failed\n", int32(2)}, details)
+}
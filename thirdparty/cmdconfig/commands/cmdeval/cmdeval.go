@@ -69,6 +69,12 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 		"save the function and its arguments to Kptfile")
 	r.Command.Flags().StringVar(
 		&r.Exec, "exec", "", "run an executable as a function")
+	r.Command.Flags().BoolVar(
+		&r.RunnerOptions.EnableBuiltins, "enable-builtin-fns", false,
+		"run the function in-process instead of running its image, if it has a builtin implementation")
+	r.Command.Flags().BoolVar(
+		&r.RunnerOptions.SandboxExec, "sandbox-exec", false,
+		"run the executable in a sandbox with a scrubbed environment, a temporary working directory and a timeout")
 	r.Command.Flags().StringVar(
 		&r.FnConfigPath, "fn-config", "", "path to the function config file")
 	r.Command.Flags().BoolVarP(
@@ -92,6 +98,12 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 		return r.RunnerOptions.ImagePullPolicy.AllStrings(), cobra.ShellCompDirectiveDefault
 	})
 
+	r.Command.Flags().StringVar(
+		&r.FnRuntime, "fn-runtime", "", "run the function with the given runtime: grpc://HOST:PORT to use a function evaluator, record://DIR to record the function run in DIR, replay://DIR to replay it")
+
+	r.Command.Flags().BoolVar(
+		&r.FnCache, "fn-cache", false, "cache the result of the function, and reuse the cached result of previous runs with the same input")
+
 	r.Command.Flags().BoolVar(
 		&r.RunnerOptions.AllowWasm, "allow-alpha-wasm", false, "allow alpha wasm functions to be run. If true, you can specify a wasm image with --image flag or a path to a wasm file (must have the .wasm file extension) with --exec flag.")
 
@@ -151,6 +163,8 @@ type EvalFnRunner struct {
 	Mounts               []string
 	Env                  []string
 	AsCurrentUser        bool
+	FnCache              bool
+	FnRuntime            string
 	IncludeMetaResources bool
 	Ctx                  context.Context
 	Selector             kptfile.Selector
@@ -173,6 +187,14 @@ func (r *EvalFnRunner) InitDefaults() {
 }
 
 func (r *EvalFnRunner) runE(c *cobra.Command, _ []string) error {
+	if r.FnRuntime != "" {
+		runtime, closeRuntime, err := fnruntime.OpenFnRuntime(r.FnRuntime, &r.runFns.RunnerOptions)
+		if err != nil {
+			return err
+		}
+		defer closeRuntime()
+		r.runFns.Runtime = runtime
+	}
 	err := runner.HandleError(r.Ctx, r.runFns.Execute())
 	if err != nil {
 		return err
@@ -467,6 +489,21 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 	if r.Image == "" && r.Exec == "" {
 		return errors.Errorf("must specify --image or --exec")
 	}
+	if r.FnRuntime == "" {
+		r.FnRuntime = os.Getenv(fnruntime.FnRuntimeEnv)
+	}
+	if r.FnRuntime != "" {
+		if _, _, err := fnruntime.ParseFnRuntime(r.FnRuntime); err != nil {
+			return err
+		}
+	}
+	if r.FnCache {
+		fnCacheDir, err := fnruntime.DefaultFnCacheDir()
+		if err != nil {
+			return err
+		}
+		r.RunnerOptions.FnCacheDir = fnCacheDir
+	}
 	var dataItems []string
 	if c.ArgsLenAtDash() >= 0 {
 		dataItems = append(dataItems, args[c.ArgsLenAtDash():]...)
@@ -538,6 +575,15 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 				pkgAbsPath)
 		}
 	}
+	// the function policies of the repository of the current directory
+	// apply if the resources are read from stdin.
+	policyPath := path
+	if policyPath == "" {
+		policyPath = "."
+	}
+	if r.RunnerOptions.FnPolicies, err = fnruntime.LoadFnPolicies(policyPath); err != nil {
+		return err
+	}
 	r.parseSelectors()
 	r.runFns = runfn.RunFns{
 		Ctx:           r.Ctx,
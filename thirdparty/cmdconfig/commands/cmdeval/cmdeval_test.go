@@ -47,6 +47,7 @@ func TestRunFnCommand_preRunE(t *testing.T) {
 		output           io.Writer
 		fnConfigPath     string
 		network          bool
+		fnCache          bool
 		mount            []string
 	}{
 		{
@@ -117,6 +118,24 @@ metadata:
 data: {}
 kind: ConfigMap
 apiVersion: v1
+`,
+		},
+		{
+			name:    "fn cache enabled",
+			args:    []string{"eval", dir, "--image", "foo:bar", "--fn-cache"},
+			path:    dir,
+			fnCache: true,
+			expectedFn: &runtimeutil.FunctionSpec{
+				Container: runtimeutil.ContainerSpec{
+					Image: "gcr.io/kpt-fn/foo:bar",
+				},
+			},
+			expectedFnConfig: `
+metadata:
+  name: function-input
+data: {}
+kind: ConfigMap
+apiVersion: v1
 `,
 		},
 		{
@@ -399,6 +418,18 @@ apiVersion: v1
 				t.FailNow()
 			}
 
+			// check if the function results are cached, only with --fn-cache
+			expectedFnCacheDir := ""
+			if tt.fnCache {
+				var err error
+				if expectedFnCacheDir, err = fnruntime.DefaultFnCacheDir(); !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+			if !assert.Equal(t, expectedFnCacheDir, r.runFns.RunnerOptions.FnCacheDir) {
+				t.FailNow()
+			}
+
 			if !assert.Equal(t, toStorageMounts(tt.mount), r.runFns.StorageMounts) {
 				t.FailNow()
 			}
@@ -20,11 +20,13 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/printerutil"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	kptfile "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 )
 
 // RunFns runs the set of configuration functions in a local directory against
@@ -87,6 +89,10 @@ type RunFns struct {
 
 	RunnerOptions fnruntime.RunnerOptions
 
+	// Runtime is the function runtime the function image is run with, if
+	// any, instead of a container.
+	Runtime fn.FunctionRuntime
+
 	// ExecArgs are the arguments for exec commands
 	ExecArgs []string
 
@@ -329,6 +335,22 @@ func (r *RunFns) getFunctionConfig() (*yaml.RNode, error) {
 	return kptfile.GetValidatedFnConfigFromPath(filesys.FileSystemOrOnDisk{}, "", r.FnConfigPath)
 }
 
+// runtimeRun returns the run function of f with the function runtime, or nil
+// if there is no function runtime or it doesn't run f.
+func (r *RunFns) runtimeRun(f *kptfile.Function) (func(io.Reader, io.Writer) error, error) {
+	if r.Runtime == nil {
+		return nil, nil
+	}
+	runner, err := r.Runtime.GetRunner(r.Ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("function runtime failed to evaluate function: %w", err)
+	}
+	if runner == nil {
+		return nil, nil
+	}
+	return runner.Run, nil
+}
+
 // defaultFnFilterProvider provides function filters
 func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig *yaml.RNode, currentUser currentUserFunc) (kio.Filter, error) {
 	if spec.Container.Image == "" && spec.Exec.Path == "" {
@@ -351,6 +373,8 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 		// Enable this once test harness supports filepath based assertions.
 		// Pkg: string(r.uniquePath),
 	}
+	// fixtureID identifies the function in the recorded function runs.
+	var fixtureID string
 	if spec.Container.Image != "" {
 		fnResult.Image = spec.Container.Image
 
@@ -358,16 +382,31 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 		if err != nil {
 			return nil, err
 		}
-		// If AllowWasm is true, we try to use the image field as a wasm image.
-		// TODO: we can be smarter here. If the image doesn't support wasm/js platform,
-		// it should fallback to run it as container fn.
-		if r.RunnerOptions.AllowWasm {
+		fixtureID = resolvedImage
+		if err := fnruntime.CheckFnPolicies(r.RunnerOptions.FnPolicies, resolvedImage, resolvedImage); err != nil {
+			return nil, err
+		}
+		fltr.Run, err = r.runtimeRun(&kptfile.Function{Image: resolvedImage})
+		if err != nil {
+			return nil, err
+		}
+		builtin, _ := builtins.Lookup(resolvedImage)
+		switch {
+		case fltr.Run != nil:
+			// the function is run by the function runtime.
+		case r.RunnerOptions.AllowWasm:
+			// If AllowWasm is true, we try to use the image field as a wasm image.
+			// TODO: we can be smarter here. If the image doesn't support wasm/js platform,
+			// it should fallback to run it as container fn.
 			wFn, err := fnruntime.NewWasmFn(fnruntime.NewOciLoader(filepath.Join(os.TempDir(), "kpt-fn-wasm"), resolvedImage))
 			if err != nil {
 				return nil, err
 			}
 			fltr.Run = wFn.Run
-		} else {
+		case r.RunnerOptions.EnableBuiltins && builtin != nil:
+			// run the builtin implementation of the function in-process.
+			fltr.Run = builtin.Run
+		default:
 			// TODO: Add a test for this behavior
 			uidgid, err := getUIDGID(r.AsCurrentUser, currentUser)
 			if err != nil {
@@ -387,29 +426,43 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 					AllowMount: true,
 				},
 			}
-			fltr.Run = c.Run
+			fltr.Run = r.RunnerOptions.WithFnCache(c, fnResult)
 		}
 	}
 
 	if spec.Exec.Path != "" {
 		fnResult.ExecPath = r.OriginalExec
+		fixtureID = r.OriginalExec
 
-		if r.RunnerOptions.AllowWasm && strings.HasSuffix(spec.Exec.Path, ".wasm") {
+		fltr.Run, err = r.runtimeRun(&kptfile.Function{Exec: r.OriginalExec})
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case fltr.Run != nil:
+			// the function is run by the function runtime.
+		case r.RunnerOptions.AllowWasm && strings.HasSuffix(spec.Exec.Path, ".wasm"):
 			wFn, err := fnruntime.NewWasmFn(&fnruntime.FsLoader{Filename: spec.Exec.Path})
 			if err != nil {
 				return nil, err
 			}
 			fltr.Run = wFn.Run
-		} else {
+		default:
 			e := &fnruntime.ExecFn{
 				Path:     spec.Exec.Path,
 				Args:     r.ExecArgs,
 				FnResult: fnResult,
+				Sandbox:  r.RunnerOptions.SandboxExec,
+				Ctx:      r.Ctx,
 			}
-			fltr.Run = e.Run
+			fltr.Run = r.RunnerOptions.WithFnCache(e, fnResult)
 		}
 	}
 
+	if r.RunnerOptions.FnRecorder != nil {
+		fltr.Run = r.RunnerOptions.FnRecorder.Wrap(fixtureID, fltr.Run, fnResult)
+	}
+
 	opts := r.RunnerOptions
 	if !r.Selector.IsEmpty() || !r.Exclusion.IsEmpty() {
 		opts.DisplayResourceCount = true
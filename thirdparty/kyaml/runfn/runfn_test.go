@@ -5,14 +5,18 @@ package runfn
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
 	v1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/GoogleContainerTools/kpt/pkg/fn"
 	"github.com/stretchr/testify/assert"
 
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
@@ -521,3 +525,121 @@ func TestRunFns_mergeContainerEnv(t *testing.T) {
 		})
 	}
 }
+
+// fakeRuntime is a function runtime recording the images of the functions
+// it runs.
+type fakeRuntime struct {
+	images []string
+}
+
+func (r *fakeRuntime) GetRunner(_ context.Context, f *v1.Function) (fn.FunctionRunner, error) {
+	r.images = append(r.images, f.Image)
+	return r, nil
+}
+
+func (r *fakeRuntime) Run(in io.Reader, out io.Writer) error {
+	_, err := io.Copy(out, in)
+	return err
+}
+
+func TestRunFns_defaultFnFilterProvider_fnPolicies(t *testing.T) {
+	policies := []*fnruntime.FnPolicy{{
+		Spec: fnruntime.FnPolicySpec{DeniedImages: []string{"gcr.io/kpt-fn/starlark:*"}},
+		Path: "fn-policy.yaml",
+	}}
+	testCases := map[string]struct {
+		image     string
+		expectErr string
+	}{
+		"allowed image": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"denied image": {
+			image: "gcr.io/kpt-fn/starlark:v0.4",
+			expectErr: `not allowed by function policy "fn-policy.yaml": ` +
+				`image matches denied image "gcr.io/kpt-fn/starlark:*"`,
+		},
+		"denied short image": {
+			image:     "starlark:v0.4",
+			expectErr: `image matches denied image "gcr.io/kpt-fn/starlark:*"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			runtime := &fakeRuntime{}
+			r := &RunFns{
+				Ctx:     fake.CtxWithDefaultPrinter(),
+				Runtime: runtime,
+			}
+			r.RunnerOptions.InitDefaults()
+			r.RunnerOptions.FnPolicies = policies
+			spec := runtimeutil.FunctionSpec{Container: runtimeutil.ContainerSpec{Image: tc.image}}
+			_, err := r.defaultFnFilterProvider(spec, nil, nil)
+			if tc.expectErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectErr)
+				}
+				assert.Empty(t, runtime.images)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tc.image}, runtime.images)
+		})
+	}
+}
+
+func TestRunFns_defaultFnFilterProvider_builtins(t *testing.T) {
+	testCases := map[string]struct {
+		enabled bool
+		image   string
+		builtin bool
+	}{
+		"builtins are not run by default": {
+			image:   "gcr.io/kpt-fn/set-labels:v0.1",
+			builtin: false,
+		},
+		"builtin is run if enabled": {
+			enabled: true,
+			image:   "gcr.io/kpt-fn/set-labels:v0.1",
+			builtin: true,
+		},
+		"short image is run by the builtin": {
+			enabled: true,
+			image:   "set-labels:v0.1",
+			builtin: true,
+		},
+		"image without builtin": {
+			enabled: true,
+			image:   "gcr.io/kpt-fn/set-labels:v0.2",
+			builtin: false,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			r := &RunFns{Ctx: fake.CtxWithDefaultPrinter(), fnResults: fnresult.NewResultList()}
+			r.RunnerOptions.InitDefaults()
+			r.RunnerOptions.EnableBuiltins = tc.enabled
+			// the image is not pulled, so it fails to run unless it is run
+			// by the builtin.
+			r.RunnerOptions.ImagePullPolicy = fnruntime.NeverPull
+			fnConfig := yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: labels\ndata:\n  app: foo\n")
+			spec := runtimeutil.FunctionSpec{Container: runtimeutil.ContainerSpec{Image: tc.image}}
+			fltr, err := r.defaultFnFilterProvider(spec, fnConfig, nil)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			out, err := fltr.Filter([]*yaml.RNode{yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")})
+			if !tc.builtin {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) && assert.Len(t, out, 1) {
+				assert.Equal(t, map[string]string{"app": "foo"}, out[0].GetLabels())
+			}
+		})
+	}
+}